@@ -0,0 +1,157 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Affinity is where a device sits in the host: its NUMA node, the CPUs
+// local to it and the network interface closest to it.
+type Affinity struct {
+	NUMANode   int    `json:"numa_node"` // -1 on hosts without NUMA
+	CPUs       string `json:"cpus"`      // local_cpulist, e.g. "0-23,48-71"
+	NearestNIC *NIC   `json:"nearest_nic,omitempty"`
+}
+
+// CPUList returns the CPUs of a as a sorted list of CPU numbers, e.g. for
+// sched_setaffinity.
+func (a *Affinity) CPUList() ([]int, error) {
+	return ParseCPUList(a.CPUs)
+}
+
+// NIC is a network controller on the PCI bus.
+type NIC struct {
+	Address string `json:"address"`
+	// Name is the RDMA device, e.g. "mlx5_0", or else the network
+	// interface, e.g. "eth2". It is empty when neither is set up.
+	Name string `json:"name"`
+	// Link is the PCIe path from the device to the NIC, e.g. LinkPIX.
+	Link LinkType `json:"link"`
+}
+
+// nicPathOrder ranks PCIe paths from nearest to farthest.
+var nicPathOrder = []LinkType{LinkPIX, LinkPXB, LinkPHB, LinkNode, LinkSys}
+
+// Affinity returns the NUMA node, local CPUs and nearest NIC of the device at
+// address, which may be in any form ParsePCIAddress accepts.
+func (e *PCIEnumerator) Affinity(address string) (*Affinity, error) {
+	dir := filepath.Join(e.Root, "sys", "bus", "pci", "devices")
+	return e.affinity(dir, address, e.nics(dir))
+}
+
+// FillAffinity sets the Affinity of each of gpus found in sysfs, and leaves
+// it nil for the others.
+func (e *PCIEnumerator) FillAffinity(gpus []GPUInfo) {
+	dir := filepath.Join(e.Root, "sys", "bus", "pci", "devices")
+	nics := e.nics(dir)
+	for i := range gpus {
+		gpus[i].Affinity, _ = e.affinity(dir, gpus[i].PCIBus, nics)
+	}
+}
+
+type nicPath struct {
+	nic  NIC
+	path pciPath
+}
+
+func (e *PCIEnumerator) affinity(dir, address string, nics []nicPath) (*Affinity, error) {
+	a, err := ParsePCIAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	device := filepath.Join(dir, a.String())
+	if _, err := os.Stat(device); err != nil {
+		return nil, err
+	}
+
+	affinity := &Affinity{NUMANode: -1, CPUs: readSysfs(device, "local_cpulist")}
+	if node, err := strconv.Atoi(readSysfs(device, "numa_node")); err == nil {
+		affinity.NUMANode = node
+	}
+
+	path := e.pciPath(dir, a.String())
+	best := len(nicPathOrder)
+	for _, n := range nics {
+		rank := pathRank(pciLinkType(path, n.path))
+		if rank < best {
+			best = rank
+			nic := n.nic
+			nic.Link = nicPathOrder[rank]
+			affinity.NearestNIC = &nic
+		}
+	}
+	return affinity, nil
+}
+
+func pathRank(t LinkType) int {
+	for i, o := range nicPathOrder {
+		if o == t {
+			return i
+		}
+	}
+	return len(nicPathOrder)
+}
+
+// nics lists the network controllers, PCI class 0x02, in address order.
+func (e *PCIEnumerator) nics(dir string) []nicPath {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var nics []nicPath
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !strings.HasPrefix(readSysfs(path, "class"), "0x02") {
+			continue
+		}
+		nics = append(nics, nicPath{
+			nic:  NIC{Address: entry.Name(), Name: nicName(path)},
+			path: e.pciPath(dir, entry.Name()),
+		})
+	}
+	sort.Slice(nics, func(i, j int) bool { return comparePCIAddresses(nics[i].nic.Address, nics[j].nic.Address) < 0 })
+	return nics
+}
+
+// nicName returns the first RDMA device of a NIC, which is what NCCL and
+// HCCL are configured with, or else its first network interface.
+func nicName(path string) string {
+	for _, sub := range []string{"infiniband", "net"} {
+		if entries, err := os.ReadDir(filepath.Join(path, sub)); err == nil && len(entries) > 0 {
+			return entries[0].Name()
+		}
+	}
+	return ""
+}
+
+// ParseCPUList parses a Linux CPU list such as "0-23,48-71" into sorted CPU
+// numbers.
+func ParseCPUList(list string) ([]int, error) {
+	cpus := []int{}
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return cpus, nil
+	}
+	for _, part := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(last); err != nil || hi < lo {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		for cpu := lo; cpu <= hi; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
@@ -0,0 +1,79 @@
+package gpu
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPCIEnumeratorAffinity(t *testing.T) {
+	e := &PCIEnumerator{Root: "testdata/pcitopo"}
+
+	affinity, err := e.Affinity("00000000:03:00.0")
+	assert.NoError(t, err)
+	assert.Equal(t, &Affinity{
+		NUMANode:   0,
+		CPUs:       "0-23,48-71",
+		NearestNIC: &NIC{Address: "0000:09:00.0", Name: "mlx5_0", Link: LinkPIX},
+	}, affinity)
+
+	// Behind several switches the same NIC is still the nearest.
+	affinity, err = e.Affinity("0000:08:00.0")
+	assert.NoError(t, err)
+	assert.Equal(t, LinkPXB, affinity.NearestNIC.Link)
+
+	// Another host bridge of the same node beats the other socket.
+	affinity, err = e.Affinity("0000:41:00.0")
+	assert.NoError(t, err)
+	assert.Equal(t, &NIC{Address: "0000:09:00.0", Name: "mlx5_0", Link: LinkNode}, affinity.NearestNIC)
+
+	affinity, err = e.Affinity("0000:81:00.0")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, affinity.NUMANode)
+	assert.Equal(t, &NIC{Address: "0000:82:00.0", Name: "eth2", Link: LinkPHB}, affinity.NearestNIC)
+
+	_, err = e.Affinity("0000:99:00.0")
+	assert.Error(t, err)
+	_, err = e.Affinity("N/A")
+	assert.Error(t, err)
+}
+
+func TestAffinityWithoutNUMA(t *testing.T) {
+	// The older fixture has neither numa_node, local_cpulist nor NICs.
+	affinity, err := (&PCIEnumerator{Root: "testdata/pcitree"}).Affinity("0000:17:00.0")
+	assert.NoError(t, err)
+	assert.Equal(t, &Affinity{NUMANode: -1}, affinity)
+}
+
+func TestFillAffinity(t *testing.T) {
+	gpus := []GPUInfo{{PCIBus: "0000:04:00.0"}, {PCIBus: "N/A"}}
+	(&PCIEnumerator{Root: "testdata/pcitopo"}).FillAffinity(gpus)
+	assert.Equal(t, "0-23,48-71", gpus[0].Affinity.CPUs)
+	assert.Nil(t, gpus[1].Affinity)
+}
+
+func TestDetectAllFillsAffinity(t *testing.T) {
+	list := &GPUInfoList{GPUInfos: []GPUInfo{{Num: 0, PCIBus: "0000:81:00.0"}}}
+	inv := DetectAll(context.Background(),
+		WithLoaders(&stubLoader{vendor: "Enflame", available: true, list: list}),
+		WithPCIEnumerator(&PCIEnumerator{Root: "testdata/pcitopo"}))
+
+	assert.Len(t, inv.GPUs, 1)
+	assert.Equal(t, "24-47,72-95", inv.GPUs[0].Affinity.CPUs)
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := ParseCPUList("8-10,2,4-5\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 4, 5, 8, 9, 10}, cpus)
+
+	cpus, err = (&Affinity{}).CPUList()
+	assert.NoError(t, err)
+	assert.Empty(t, cpus)
+
+	for _, list := range []string{"0-", "a", "5-3", "1,,2"} {
+		_, err := ParseCPUList(list)
+		assert.Error(t, err, list)
+	}
+}
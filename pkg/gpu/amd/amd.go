@@ -1,40 +1,224 @@
 package amd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
-	"os/exec"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 )
 
 func init() {
-	gpu.Register(&rocmSMICommand{})
+	gpu.Register(New())
 }
 
+// New returns the AMD loader. It reads the GPUs with amd-smi when amd-smi
+// runs, else with rocm-smi, and otherwise straight from amdgpu sysfs.
+func New() *amdGPU {
+	return &amdGPU{
+		amdsmi: &amdSMICommand{},
+		smi:    &rocmSMICommand{},
+		sysfs:  &sysfsLoader{root: "/"},
+	}
+}
+
+type amdGPU struct {
+	amdsmi *amdSMICommand
+	smi    *rocmSMICommand
+	sysfs  *sysfsLoader
+
+	mu     sync.Mutex
+	probed bool
+	chosen gpu.ContextGPUInfoLoader // backend found by the last probe, or nil
+}
+
+func (a *amdGPU) SetExecutor(e gpu.Executor) {
+	a.amdsmi.SetExecutor(e)
+	a.smi.SetExecutor(e)
+	a.mu.Lock()
+	a.probed = false
+	a.mu.Unlock()
+}
+
+// backend returns the first available backend, or nil, and remembers it so
+// that LoadContext after AvailableContext does not probe the tools again.
+func (a *amdGPU) backend(ctx context.Context) gpu.ContextGPUInfoLoader {
+	var chosen gpu.ContextGPUInfoLoader
+	for _, b := range a.backends() {
+		if b.AvailableContext(ctx) {
+			chosen = b
+			break
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.probed, a.chosen = true, chosen
+	return chosen
+}
+
+// backends returns the backends in order of preference.
+func (a *amdGPU) backends() []gpu.ContextGPUInfoLoader {
+	return []gpu.ContextGPUInfoLoader{a.amdsmi, a.smi, a.sysfs}
+}
+
+// backendsFrom returns b and the backends after it.
+func (a *amdGPU) backendsFrom(b gpu.ContextGPUInfoLoader) []gpu.ContextGPUInfoLoader {
+	backends := a.backends()
+	for i, backend := range backends {
+		if backend == b {
+			return backends[i:]
+		}
+	}
+	return nil
+}
+
+// cachedBackend returns the backend of the last probe, probing if there
+// was none.
+func (a *amdGPU) cachedBackend(ctx context.Context) gpu.ContextGPUInfoLoader {
+	a.mu.Lock()
+	probed, chosen := a.probed, a.chosen
+	a.mu.Unlock()
+	if probed {
+		return chosen
+	}
+	return a.backend(ctx)
+}
+
+func (a *amdGPU) Load() (*gpu.GPUInfoList, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.LoadContext(ctx)
+}
+
+func (a *amdGPU) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	b := a.cachedBackend(ctx)
+	if b == nil {
+		return nil, fmt.Errorf("no AMD GPU backend available")
+	}
+	// The tools are only looked up, so they may be installed but broken;
+	// the later backends are tried then. The tools' errors are kept so that
+	// DetectAll reports the result as partial.
+	var errs []error
+	for _, next := range a.backendsFrom(b) {
+		if len(errs) > 0 && !next.AvailableContext(ctx) {
+			continue
+		}
+		list, err := next.LoadContext(ctx)
+		if err == nil && len(errs) > 0 {
+			return list, &gpu.PartialError{Errs: errs}
+		}
+		if err == nil || ctx.Err() != nil {
+			return list, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (a *amdGPU) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.AvailableContext(ctx)
+}
+
+func (a *amdGPU) AvailableContext(ctx context.Context) bool {
+	return a.backend(ctx) != nil
+}
+
+func (a *amdGPU) Vendor() string {
+	return "AMD"
+}
+
+func (a *amdGPU) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.DriverInfoContext(ctx)
+}
+
+// DriverInfoContext asks amd-smi, else rocm-smi, which itself falls back to
+// sysfs.
+func (a *amdGPU) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	if info, err := a.amdsmi.DriverInfoContext(ctx); err == nil {
+		return info, nil
+	}
+	return a.smi.DriverInfoContext(ctx)
+}
+
+const rocmSMIPath = "/usr/bin/rocm-smi"
+
+// Files read for driver information rocm-smi does not report. The amdgpu
+// version file only exists for the DKMS-built driver shipped with ROCm.
+var (
+	amdgpuVersionFile = "/sys/module/amdgpu/version"
+	rocmVersionFile   = "/opt/rocm/.info/version"
+)
+
 type rocmSMICommand struct {
+	executor gpu.Executor
+	// noRAS is set once --showrasinfo failed or found nothing, so that
+	// later loads do not run rocm-smi a second time for nothing.
+	noRAS atomic.Bool
+}
+
+func (r *rocmSMICommand) SetExecutor(e gpu.Executor) {
+	r.executor = e
+	r.noRAS.Store(false)
+}
+
+func (r *rocmSMICommand) run(ctx context.Context, args ...string) (*gpu.Result, error) {
+	return gpu.ExecutorOrDefault(r.executor).Run(ctx, gpu.Command{
+		Path: rocmSMIPath,
+		Args: args,
+		Env: append(os.Environ(),
+			"PATH=/usr/bin:/usr/local/bin:/bin:/usr/sbin:/sbin", // 确保 python3 在 PATH 里
+			// 你还可以加其它环境变量，比如 PYTHONPATH
+			// "PYTHONPATH=/your/python/site-packages",
+		),
+	})
 }
 
 func (r *rocmSMICommand) Load() (*gpu.GPUInfoList, error) {
-	// rocm-smi -i --showmeminfo vram --showpower --showserial --showuse --showtemp --showproductname --json
-	smiCmd := exec.Command("/usr/bin/rocm-smi", "-i", "--showmeminfo", "vram", "--showpower", "--showserial", "--showuse", "--showtemp", "--showproductname", "--showbus", "--json")
-	smiCmd.Env = append(os.Environ(),
-		"PATH=/usr/bin:/usr/local/bin:/bin:/usr/sbin:/sbin", // 确保 python3 在 PATH 里
-		// 你还可以加其它环境变量，比如 PYTHONPATH
-		// "PYTHONPATH=/your/python/site-packages",
-	)
-	output, err := smiCmd.Output()
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return r.LoadContext(ctx)
+}
+
+func (r *rocmSMICommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	// rocm-smi -i --showmeminfo vram --showpower --showserial --showuse --showtemp --showproductname --showbus --showclocks --json
+	result, err := r.run(ctx, "-i", "--showmeminfo", "vram", "--showpower", "--showserial", "--showuse", "--showtemp", "--showproductname", "--showbus", "--showclocks", "--json")
 	if err != nil {
 		return nil, err
 	}
 
-	return r.parse(output)
+	list, err := r.parse(result.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	// Older rocm-smi and cards without RAS support fail --showrasinfo; their
+	// GPUs are left without ECC.
+	if !r.noRAS.Load() {
+		ras, err := r.run(ctx, "--showrasinfo", "all")
+		var eccs map[int]*gpu.ECCInfo
+		if err == nil {
+			eccs = parseShowRasInfo(ras.Stdout)
+		}
+		if len(eccs) == 0 && ctx.Err() == nil {
+			r.noRAS.Store(true)
+		}
+		fillRASInfo(list.GPUInfos, eccs)
+	}
+	return list, nil
 }
 
 func (r *rocmSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
-	var gpuInfoList gpu.GPUInfoList
 	// {
 	// 	"card0": {
 	// 		"Device ID": "0x747e",
@@ -51,48 +235,253 @@ func (r *rocmSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 	// 		"Card model": "0x7801",
 	// 		"Card vendor": "Advanced Micro Devices, Inc. [AMD/ATI]",
 	// 		"Card SKU": "EXT94393"
-	// 	}
+	// 	},
+	// 	"system": {"Driver version": "6.10.5"}
 	// }
-	var jsonData map[string]gpu.GPUInfo
-	if err := json.Unmarshal(output, &jsonData); err != nil {
+	out, err := decodeRocmSMI(output)
+	if err != nil {
 		return nil, err
 	}
-	gpuList := make([]gpu.GPUInfo, 0, len(jsonData))
-	for cardNum, gpuInfo := range jsonData {
-
-		num := strings.TrimPrefix(cardNum, "card")
-		numInt, err := strconv.Atoi(num)
-		if err != nil {
-			return nil, err
+	gpuList := make([]gpu.GPUInfo, 0, len(out.cards))
+	for num, fields := range out.cards {
+		gpuInfo := gpu.GPUInfo{Num: num}
+		for key, value := range fields {
+			if field := rocmSMIField(&gpuInfo, key); field != nil {
+				*field = value
+			}
 		}
-		gpuInfo.Num = numInt
+		gpuInfo.PCIBus = gpu.CanonicalPCIAddress(gpuInfo.PCIBus)
+		gpuInfo.Metrics = parseMetrics(gpuInfo)
+		gpuInfo.Clocks = rocmSMIClocks(fields)
+		fillNotAvailable(&gpuInfo)
 		gpuList = append(gpuList, gpuInfo)
 	}
-	gpuInfoList.GPUInfos = gpuList
+	sort.Slice(gpuList, func(i, j int) bool { return gpuList[i].Num < gpuList[j].Num })
 
-	return &gpuInfoList, nil
+	return &gpu.GPUInfoList{GPUInfos: gpuList}, nil
 }
 
-func (r *rocmSMICommand) Available() bool {
-	smiCmd := exec.Command("/usr/bin/rocm-smi")
-	smiCmd.Env = append(os.Environ(),
-		"PATH=/usr/bin:/usr/local/bin:/bin:/usr/sbin:/sbin", // 确保 python3 在 PATH 里
-		// 你还可以加其它环境变量，比如 PYTHONPATH
-		// "PYTHONPATH=/your/python/site-packages",
-	)
-	if err := smiCmd.Run(); err != nil {
-		return false
+// rocmSMIJSON is the output of rocm-smi --json: one section per card, keyed
+// "cardN", and sections such as "system" for everything else.
+type rocmSMIJSON struct {
+	cards    map[int]map[string]string
+	sections map[string]map[string]string
+}
+
+func decodeRocmSMI(output []byte) (rocmSMIJSON, error) {
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return rocmSMIJSON{}, fmt.Errorf("failed to parse rocm-smi output: %v", err)
+	}
+	out := rocmSMIJSON{
+		cards:    make(map[int]map[string]string),
+		sections: make(map[string]map[string]string),
 	}
+	for name, section := range raw {
+		fields := make(map[string]string, len(section))
+		for key, value := range section {
+			fields[key] = jsonText(value)
+		}
+		if num, err := strconv.Atoi(strings.TrimPrefix(name, "card")); err == nil && strings.HasPrefix(name, "card") {
+			out.cards[num] = fields
+		} else {
+			out.sections[name] = fields
+		}
+	}
+	return out, nil
+}
 
-	// rocminfoCmd := exec.Command("rocminfo")
+// jsonText returns a JSON string unquoted and any other value as written.
+// rocm-smi prints strings, but a few fields are numbers in some releases.
+func jsonText(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	return string(value)
+}
 
-	// if err := rocminfoCmd.Run(); err != nil {
-	// 	return false
-	// }
+// rocmSMIUnit matches the unit suffix some ROCm releases append to keys,
+// as in "Temperature (Sensor edge) (C)".
+var rocmSMIUnit = regexp.MustCompile(`\s*\((c|w|%|b)\)$`)
+
+// normalizeRocmSMIKey lower-cases key and strips its unit, so the key names
+// of different ROCm releases compare equal.
+func normalizeRocmSMIKey(key string) string {
+	return rocmSMIUnit.ReplaceAllString(strings.ToLower(strings.TrimSpace(key)), "")
+}
+
+// rocmSMIField returns the field of info for a rocm-smi key, or nil for keys
+// the loader does not read.
+func rocmSMIField(info *gpu.GPUInfo, key string) *string {
+	switch normalizeRocmSMIKey(key) {
+	case "device id":
+		return &info.DeviceID
+	case "device rev":
+		return &info.DeviceRev
+	case "temperature (sensor edge)":
+		return &info.TemperatureEdge
+	case "temperature (sensor junction)":
+		return &info.TemperatureJunction
+	case "temperature (sensor memory)":
+		return &info.TemperatureMemory
+	// MI300 reports the socket power instead of the average package power.
+	case "average graphics package power", "current socket graphics package power":
+		return &info.AverageGraphicsPackagePower
+	case "gpu use":
+		return &info.GPUUse
+	case "serial number":
+		return &info.SerialNumber
+	case "vram total memory":
+		return &info.VRAMTotalMemory
+	case "vram total used memory":
+		return &info.VRAMTotalUsedMemory
+	case "card series":
+		return &info.CardSeries
+	case "card model":
+		return &info.CardModel
+	case "card vendor":
+		return &info.CardVendor
+	case "card sku":
+		return &info.CardSKU
+	case "pci bus":
+		return &info.PCIBus
+	}
+	return nil
+}
+
+// rocmSMIClocks reads the current shader (sclk) and memory (mclk) clocks of
+// --showclocks, printed as "(1700Mhz)". rocm-smi does not report the
+// maximum clocks there. It returns nil for cards without either clock.
+func rocmSMIClocks(fields map[string]string) *gpu.Clocks {
+	clocks := map[string]gpu.FloatMetric{}
+	for key, value := range fields {
+		domain, ok := strings.CutSuffix(normalizeRocmSMIKey(key), " clock speed:")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSuffix(strings.ToLower(strings.Trim(value, "() ")), "mhz")
+		clocks[domain] = gpu.ParseFloatMetric(value)
+	}
+	sclk, hasSCLK := clocks["sclk"]
+	mclk, hasMCLK := clocks["mclk"]
+	if !hasSCLK && !hasMCLK {
+		return nil
+	}
+	return &gpu.Clocks{
+		Graphics:    sclk,
+		Memory:      mclk,
+		MaxGraphics: gpu.UnsupportedFloat(),
+		MaxMemory:   gpu.UnsupportedFloat(),
+	}
+}
+
+// parseMetrics converts the rocm-smi string readings into typed metrics.
+// rocm-smi reports "N/A" for sensors a card does not have.
+func parseMetrics(info gpu.GPUInfo) gpu.GPUMetrics {
+	return gpu.GPUMetrics{
+		TemperatureEdge:             gpu.ParseFloatMetric(info.TemperatureEdge),
+		TemperatureJunction:         gpu.ParseFloatMetric(info.TemperatureJunction),
+		TemperatureMemory:           gpu.ParseFloatMetric(info.TemperatureMemory),
+		AverageGraphicsPackagePower: gpu.ParseFloatMetric(info.AverageGraphicsPackagePower),
+		GPUUse:                      gpu.ParseFloatMetric(info.GPUUse),
+		VRAMTotalMemory:             gpu.ParseUintMetric(info.VRAMTotalMemory),
+		VRAMTotalUsedMemory:         gpu.ParseUintMetric(info.VRAMTotalUsedMemory),
+		// Not part of the rocm-smi query
+		PowerLimit: gpu.UnsupportedFloat(),
+		FanSpeed:   gpu.UnsupportedFloat(),
+	}
+}
 
-	return true
+// fillNotAvailable marks readings missing from the rocm-smi output as N/A.
+func fillNotAvailable(info *gpu.GPUInfo) {
+	for _, field := range []*string{
+		&info.TemperatureEdge,
+		&info.TemperatureJunction,
+		&info.TemperatureMemory,
+		&info.AverageGraphicsPackagePower,
+		&info.GPUUse,
+		&info.VRAMTotalMemory,
+		&info.VRAMTotalUsedMemory,
+	} {
+		if *field == "" {
+			*field = gpu.NotAvailable
+		}
+	}
+}
+
+func (r *rocmSMICommand) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return r.AvailableContext(ctx)
+}
+
+// AvailableContext only looks rocm-smi up. Running it takes seconds, as it
+// starts python3 and queries every card.
+func (r *rocmSMICommand) AvailableContext(ctx context.Context) bool {
+	_, err := gpu.ExecutorOrDefault(r.executor).LookPath(rocmSMIPath)
+	return err == nil
 }
 
 func (r *rocmSMICommand) Vendor() string {
 	return "AMD"
 }
+
+func (r *rocmSMICommand) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return r.DriverInfoContext(ctx)
+}
+
+// DriverInfoContext asks rocm-smi for the driver version and falls back to
+// the amdgpu module version in sysfs. The ROCm release, if installed, is
+// reported as LibVersion.
+func (r *rocmSMICommand) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	info := gpu.GPUDriverInfo{Vendor: r.Vendor()}
+
+	result, smiErr := r.run(ctx, "--showdriverversion", "--json")
+	if smiErr == nil {
+		info.Version, smiErr = parseDriverVersion(result.Stdout)
+		info.InstallPath = rocmSMIPath
+	}
+	if info.Version == "" {
+		version, err := readVersionFile(amdgpuVersionFile)
+		if err != nil {
+			return gpu.GPUDriverInfo{}, errors.Join(smiErr, err)
+		}
+		info.Version = version
+	}
+	if version, err := readVersionFile(rocmVersionFile); err == nil {
+		info.LibVersion = version
+	}
+	info.Installed = true
+	return info, nil
+}
+
+// parseDriverVersion parses the output of "rocm-smi --showdriverversion --json":
+//
+//	{"system": {"Driver version": "6.8.5"}}
+func parseDriverVersion(output []byte) (string, error) {
+	out, err := decodeRocmSMI(output)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range out.sections["system"] {
+		if normalizeRocmSMIKey(key) == "driver version" && value != "" {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("rocm-smi reported no driver version")
+}
+
+func readVersionFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(data))
+	if version == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return version, nil
+}
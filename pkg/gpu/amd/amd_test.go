@@ -1,8 +1,10 @@
 package amd
 
 import (
+	_ "embed"
 	"testing"
 
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,4 +30,167 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, "Advanced Micro Devices, Inc. [AMD/ATI]", gpuInfoList.GPUInfos[0].CardVendor)
 	assert.Equal(t, "EXT94393", gpuInfoList.GPUInfos[0].CardSKU)
 	assert.Equal(t, 0, gpuInfoList.GPUInfos[0].Num)
+
+	metrics := gpuInfoList.GPUInfos[0].Metrics
+	assert.Equal(t, gpu.Float(36), metrics.TemperatureEdge)
+	assert.Equal(t, gpu.Float(4), metrics.AverageGraphicsPackagePower)
+	assert.Equal(t, gpu.Uint(17163091968), metrics.VRAMTotalMemory)
+	assert.Equal(t, gpu.Uint(283090944), metrics.VRAMTotalUsedMemory)
+}
+
+func TestParseNAMetrics(t *testing.T) {
+	jsonData := `{"card0":{"Temperature (Sensor edge) (C)":"N/A","Average Graphics Package Power (W)":"N/A","GPU use (%)":"3"}}`
+	amd := &rocmSMICommand{}
+
+	gpuInfoList, err := amd.parse([]byte(jsonData))
+	assert.NoError(t, err)
+	metrics := gpuInfoList.GPUInfos[0].Metrics
+	assert.Equal(t, gpu.MetricUnsupported, metrics.TemperatureEdge.Status)
+	assert.Equal(t, gpu.MetricUnsupported, metrics.AverageGraphicsPackagePower.Status)
+	assert.Equal(t, gpu.MetricUnavailable, metrics.VRAMTotalMemory.Status)
+	assert.Equal(t, gpu.Float(3), metrics.GPUUse)
+	assert.Equal(t, "N/A", gpuInfoList.GPUInfos[0].VRAMTotalMemory)
+}
+
+func TestParseSkipsSystemSection(t *testing.T) {
+	jsonData := `{"card1":{"GPU use (%)":"7"},"system":{"Driver version":"6.10.5"},"card0":{"GPU use (%)":"3"},"card10":{"GPU use (%)":"1"}}`
+	amd := &rocmSMICommand{}
+
+	gpuInfoList, err := amd.parse([]byte(jsonData))
+	assert.NoError(t, err)
+	assert.Len(t, gpuInfoList.GPUInfos, 3)
+	for i, num := range []int{0, 1, 10} {
+		assert.Equal(t, num, gpuInfoList.GPUInfos[i].Num)
+	}
+	assert.Equal(t, gpu.Float(3), gpuInfoList.GPUInfos[0].Metrics.GPUUse)
+
+	_, err = amd.parse([]byte(`{"card0": "not a section"}`))
+	assert.Error(t, err)
+}
+
+func TestParseAlternateKeys(t *testing.T) {
+	// Key names of other ROCm releases: no units, different case, and the
+	// socket power of MI300.
+	jsonData := `{"card0":{"Temperature (Sensor edge)":"36.0","Temperature (Sensor junction)":"41.0","Current Socket Graphics Package Power (W)":"139.0","GPU use":"5","VRAM Total Memory":"17163091968","Card Series":"Aqua Vanjaram [Instinct MI300X]","Serial number":"692351001132","Unknown field":"x"}}`
+	amd := &rocmSMICommand{}
+
+	gpuInfoList, err := amd.parse([]byte(jsonData))
+	assert.NoError(t, err)
+	info := gpuInfoList.GPUInfos[0]
+	assert.Equal(t, "36.0", info.TemperatureEdge)
+	assert.Equal(t, "41.0", info.TemperatureJunction)
+	assert.Equal(t, "139.0", info.AverageGraphicsPackagePower)
+	assert.Equal(t, "5", info.GPUUse)
+	assert.Equal(t, "17163091968", info.VRAMTotalMemory)
+	assert.Equal(t, "Aqua Vanjaram [Instinct MI300X]", info.CardSeries)
+	assert.Equal(t, "692351001132", info.SerialNumber)
+	assert.Equal(t, gpu.Float(139), info.Metrics.AverageGraphicsPackagePower)
+}
+
+//go:embed testdata/rocm-smi.json
+var rocmSMIOutput []byte
+
+func TestLoadWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(nil, rocmSMIPath).
+		Stdout(rocmSMIOutput, rocmSMIPath, "-i", "--showmeminfo", "vram", "--showpower", "--showserial", "--showuse", "--showtemp", "--showproductname", "--showbus", "--showclocks", "--json")
+	amd := &rocmSMICommand{}
+	amd.SetExecutor(fake)
+
+	assert.True(t, amd.Available())
+	gpuInfoList, err := amd.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(gpuInfoList.GPUInfos))
+	byNum := map[int]gpu.GPUInfo{}
+	for _, info := range gpuInfoList.GPUInfos {
+		byNum[info.Num] = info
+	}
+	assert.Equal(t, "0000:83:00.0", byNum[1].PCIBus)
+	assert.Equal(t, gpu.Float(12), byNum[1].Metrics.GPUUse)
+	assert.Equal(t, gpu.MetricUnsupported, byNum[1].Metrics.AverageGraphicsPackagePower.Status)
+	assert.Nil(t, byNum[1].ECC, "rocm-smi without --showrasinfo")
+	assert.Equal(t, &gpu.Clocks{
+		Graphics:    gpu.Float(1700),
+		Memory:      gpu.Float(1600),
+		MaxGraphics: gpu.UnsupportedFloat(),
+		MaxMemory:   gpu.UnsupportedFloat(),
+	}, byNum[0].Clocks)
+	assert.Nil(t, byNum[1].Clocks)
+
+	// rocm-smi needs python3, so it is always run with a fixed PATH.
+	calls := fake.Calls()
+	assert.Contains(t, calls[len(calls)-1].Env, "PATH=/usr/bin:/usr/local/bin:/bin:/usr/sbin:/sbin")
 }
+
+func TestAvailableOnlyLooksUpRocmSMI(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Path(rocmSMIPath, rocmSMIPath)
+	amd := &rocmSMICommand{executor: fake}
+	assert.True(t, amd.Available())
+	assert.Empty(t, fake.Calls())
+
+	amd.SetExecutor(gpu.NewScriptedExecutor())
+	assert.False(t, amd.Available())
+}
+
+//go:embed testdata/rocm-smi-driver.json
+var rocmSMIDriverOutput []byte
+
+// useTestdataVersionFiles points the sysfs and ROCm version files at testdata.
+func useTestdataVersionFiles(t *testing.T) {
+	t.Helper()
+	oldModule, oldROCm := amdgpuVersionFile, rocmVersionFile
+	amdgpuVersionFile = "testdata/sys/module/amdgpu/version"
+	rocmVersionFile = "testdata/opt/rocm/.info/version"
+	t.Cleanup(func() { amdgpuVersionFile, rocmVersionFile = oldModule, oldROCm })
+}
+
+func TestDriverInfoFromRocmSMI(t *testing.T) {
+	useTestdataVersionFiles(t)
+	fake := gpu.NewScriptedExecutor().Stdout(rocmSMIDriverOutput, rocmSMIPath, "--showdriverversion", "--json")
+	amd := &rocmSMICommand{executor: fake}
+
+	info, err := amd.DriverInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, gpu.GPUDriverInfo{
+		Vendor:      "AMD",
+		Version:     "6.10.5",
+		Installed:   true,
+		InstallPath: rocmSMIPath,
+		LibVersion:  "6.3.1-48",
+	}, info)
+}
+
+func TestDriverInfoFallsBackToSysfs(t *testing.T) {
+	useTestdataVersionFiles(t)
+	amd := &rocmSMICommand{executor: gpu.NewScriptedExecutor()}
+
+	info, err := amd.DriverInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "6.10.5", info.Version)
+	assert.Equal(t, "", info.InstallPath)
+	assert.True(t, info.Installed)
+}
+
+func TestDriverInfoNotInstalled(t *testing.T) {
+	useTestdataVersionFiles(t)
+	amdgpuVersionFile = "testdata/sys/module/missing/version"
+	amd := &rocmSMICommand{executor: gpu.NewScriptedExecutor()}
+
+	_, err := amd.DriverInfo()
+	assert.Error(t, err)
+}
+
+func TestParseDriverVersionAlternateKey(t *testing.T) {
+	version, err := parseDriverVersion([]byte(`{"system": {"Driver Version": "6.8.5", "ROCm version": "6.3.1"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "6.8.5", version)
+}
+
+func TestParseDriverVersionWithoutSystem(t *testing.T) {
+	_, err := parseDriverVersion([]byte(`{"card0": {"Driver version": "6.10.5"}}`))
+	assert.ErrorContains(t, err, "no driver version")
+}
+
+var _ gpu.ContextGPUInfoLoader = &rocmSMICommand{}
+var _ gpu.ContextDriverGetter = &rocmSMICommand{}
+var _ gpu.ExecutorSetter = &rocmSMICommand{}
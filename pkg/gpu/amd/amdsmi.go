@@ -0,0 +1,407 @@
+package amd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+// amdSMIPaths are tried in order. ROCm installs amd-smi in /opt/rocm/bin
+// and only some packages link it into /usr/bin.
+var amdSMIPaths = []string{"amd-smi", "/opt/rocm/bin/amd-smi"}
+
+// amdSMICommand reads the GPUs with amd-smi, which replaces rocm-smi from
+// ROCm 6 on. On partitioned Instinct GPUs amd-smi lists every partition as
+// a GPU of its own, and so does the loader.
+type amdSMICommand struct {
+	executor gpu.Executor
+}
+
+func (a *amdSMICommand) SetExecutor(e gpu.Executor) {
+	a.executor = e
+}
+
+// lookPath returns the first amd-smi found, or "" if there is none.
+func (a *amdSMICommand) lookPath() string {
+	executor := gpu.ExecutorOrDefault(a.executor)
+	for _, p := range amdSMIPaths {
+		if path, err := executor.LookPath(p); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func (a *amdSMICommand) run(ctx context.Context, args ...string) (*gpu.Result, error) {
+	path := a.lookPath()
+	if path == "" {
+		return nil, fmt.Errorf("amd-smi not found")
+	}
+	return gpu.ExecutorOrDefault(a.executor).Run(ctx, gpu.Command{
+		Path: path,
+		Args: args,
+		// amd-smi is a python3 script like rocm-smi.
+		Env: append(os.Environ(), "PATH=/usr/bin:/usr/local/bin:/bin:/usr/sbin:/sbin"),
+	})
+}
+
+func (a *amdSMICommand) Load() (*gpu.GPUInfoList, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.LoadContext(ctx)
+}
+
+func (a *amdSMICommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	static, err := a.run(ctx, "static", "--json")
+	if err != nil {
+		return nil, err
+	}
+	metric, err := a.run(ctx, "metric", "--json")
+	if err != nil {
+		return nil, err
+	}
+	return parseAMDSMI(static.Stdout, metric.Stdout)
+}
+
+func (a *amdSMICommand) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.AvailableContext(ctx)
+}
+
+// AvailableContext only looks amd-smi up, as running it starts python3. An
+// amd-smi whose python library does not match the installed ROCm fails on
+// every command; the AMD loader then falls back to rocm-smi or sysfs.
+func (a *amdSMICommand) AvailableContext(ctx context.Context) bool {
+	return a.lookPath() != ""
+}
+
+func (a *amdSMICommand) Vendor() string {
+	return "AMD"
+}
+
+func (a *amdSMICommand) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.DriverInfoContext(ctx)
+}
+
+// DriverInfoContext reports the driver and VBIOS versions of the first GPU
+// in "amd-smi static --json". The ROCm release, if installed, is reported
+// as LibVersion.
+func (a *amdSMICommand) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	result, err := a.run(ctx, "static", "--json")
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	gpus, err := decodeAMDSMI[amdSMIStatic](result.Stdout)
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	if len(gpus) == 0 || gpus[0].Driver.Version == "" {
+		return gpu.GPUDriverInfo{}, fmt.Errorf("amd-smi reported no driver version")
+	}
+	info := gpu.GPUDriverInfo{
+		Vendor:          a.Vendor(),
+		Version:         gpus[0].Driver.Version,
+		FirmwareVersion: gpus[0].VBIOS.Version,
+		InstallPath:     a.lookPath(),
+		Installed:       true,
+	}
+	if version, err := readVersionFile(rocmVersionFile); err == nil {
+		info.LibVersion = version
+	}
+	return info, nil
+}
+
+// amdSMIValue is a reading in amd-smi JSON. Depending on the amd-smi
+// version and the field it is a bare number, a string such as "N/A", or an
+// object such as {"value": 42, "unit": "W"}.
+type amdSMIValue struct {
+	set   bool
+	value string
+	unit  string
+}
+
+func (v *amdSMIValue) UnmarshalJSON(data []byte) error {
+	var obj struct {
+		Value json.RawMessage `json:"value"`
+		Unit  string          `json:"unit"`
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		data = obj.Value
+	}
+	v.set = true
+	v.unit = obj.Unit
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v.value = s
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("unexpected amd-smi value %s", data)
+	}
+	v.value = n.String()
+	return nil
+}
+
+// float returns the reading; fields amd-smi leaves out are unsupported.
+func (v amdSMIValue) float() gpu.FloatMetric {
+	if !v.set {
+		return gpu.UnsupportedFloat()
+	}
+	return gpu.ParseFloatMetric(v.value)
+}
+
+// bytes returns a memory reading in bytes. amd-smi reports VRAM in MB,
+// meaning MiB.
+func (v amdSMIValue) bytes() gpu.UintMetric {
+	if !v.set {
+		return gpu.UnsupportedUint()
+	}
+	m := gpu.ParseUintMetric(v.value)
+	if !m.Available() {
+		return m
+	}
+	switch strings.ToUpper(v.unit) {
+	case "KB":
+		m.Value <<= 10
+	case "MB":
+		m.Value <<= 20
+	case "GB":
+		m.Value <<= 30
+	}
+	return m
+}
+
+func (v amdSMIValue) String() string {
+	return strings.TrimSpace(v.value)
+}
+
+// amdSMIStatic is one GPU of "amd-smi static --json".
+type amdSMIStatic struct {
+	GPU  int `json:"gpu"`
+	ASIC struct {
+		MarketName string `json:"market_name"`
+		VendorName string `json:"vendor_name"`
+		DeviceID   string `json:"device_id"`
+		RevID      string `json:"rev_id"`
+		ASICSerial string `json:"asic_serial"`
+	} `json:"asic"`
+	Bus struct {
+		BDF string `json:"bdf"`
+	} `json:"bus"`
+	VBIOS struct {
+		PartNumber string `json:"part_number"`
+		Version    string `json:"version"`
+	} `json:"vbios"`
+	Board struct {
+		ProductSerial string `json:"product_serial"`
+	} `json:"board"`
+	Driver struct {
+		Version string `json:"version"`
+	} `json:"driver"`
+	Limit struct {
+		MaxPower    amdSMIValue `json:"max_power"`
+		SocketPower amdSMIValue `json:"socket_power"`
+	} `json:"limit"`
+	RAS struct {
+		// A map of block name to state in current amd-smi; kept raw as
+		// older versions print a different shape.
+		ECCBlockState json.RawMessage `json:"ecc_block_state"`
+	} `json:"ras"`
+	Partition struct {
+		ComputePartition amdSMIValue `json:"compute_partition"`
+		MemoryPartition  amdSMIValue `json:"memory_partition"`
+		PartitionID      amdSMIValue `json:"partition_id"`
+	} `json:"partition"`
+}
+
+// amdSMIMetric is one GPU of "amd-smi metric --json".
+type amdSMIMetric struct {
+	GPU   int `json:"gpu"`
+	Usage struct {
+		GFXActivity amdSMIValue `json:"gfx_activity"`
+	} `json:"usage"`
+	Power struct {
+		SocketPower        amdSMIValue `json:"socket_power"`
+		AverageSocketPower amdSMIValue `json:"average_socket_power"`
+	} `json:"power"`
+	Temperature map[string]amdSMIValue `json:"temperature"`
+	ECC         struct {
+		TotalCorrectableCount   amdSMIValue `json:"total_correctable_count"`
+		TotalUncorrectableCount amdSMIValue `json:"total_uncorrectable_count"`
+	} `json:"ecc"`
+	XGMIErr amdSMIValue `json:"xgmi_err"`
+	// Clock is keyed by clock domain and instance, e.g. "gfx_0" for the
+	// first XCD and "mem_0".
+	Clock map[string]struct {
+		Clk    amdSMIValue `json:"clk"`
+		MaxClk amdSMIValue `json:"max_clk"`
+	} `json:"clock"`
+	MemUsage struct {
+		TotalVRAM amdSMIValue `json:"total_vram"`
+		UsedVRAM  amdSMIValue `json:"used_vram"`
+	} `json:"mem_usage"`
+}
+
+// decodeAMDSMI decodes the GPU list of amd-smi JSON output, which is a bare
+// list up to ROCm 6.3 and {"gpu_data": [...]} since.
+func decodeAMDSMI[T any](output []byte) ([]T, error) {
+	var gpus []T
+	if bytes.HasPrefix(bytes.TrimSpace(output), []byte("{")) {
+		var wrapped struct {
+			GPUData []T `json:"gpu_data"`
+		}
+		if err := json.Unmarshal(output, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to parse amd-smi output: %v", err)
+		}
+		gpus = wrapped.GPUData
+	} else if err := json.Unmarshal(output, &gpus); err != nil {
+		return nil, fmt.Errorf("failed to parse amd-smi output: %v", err)
+	}
+	return gpus, nil
+}
+
+// parseAMDSMI joins the static and metric output of amd-smi by GPU index.
+func parseAMDSMI(staticOutput, metricOutput []byte) (*gpu.GPUInfoList, error) {
+	statics, err := decodeAMDSMI[amdSMIStatic](staticOutput)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := decodeAMDSMI[amdSMIMetric](metricOutput)
+	if err != nil {
+		return nil, err
+	}
+	byGPU := make(map[int]amdSMIMetric, len(metrics))
+	for _, m := range metrics {
+		byGPU[m.GPU] = m
+	}
+
+	list := &gpu.GPUInfoList{GPUInfos: make([]gpu.GPUInfo, 0, len(statics))}
+	for _, s := range statics {
+		list.GPUInfos = append(list.GPUInfos, amdSMIInfo(s, byGPU[s.GPU]))
+	}
+	sort.Slice(list.GPUInfos, func(i, j int) bool { return list.GPUInfos[i].Num < list.GPUInfos[j].Num })
+	return list, nil
+}
+
+func amdSMIInfo(s amdSMIStatic, m amdSMIMetric) gpu.GPUInfo {
+	power := m.Power.SocketPower
+	if !power.float().Available() {
+		power = m.Power.AverageSocketPower
+	}
+	powerLimit := s.Limit.SocketPower
+	if !powerLimit.float().Available() {
+		powerLimit = s.Limit.MaxPower
+	}
+	metrics := gpu.GPUMetrics{
+		TemperatureEdge:             m.Temperature["edge"].float(),
+		TemperatureJunction:         m.Temperature["hotspot"].float(),
+		TemperatureMemory:           memoryTemperature(m.Temperature),
+		AverageGraphicsPackagePower: power.float(),
+		GPUUse:                      m.Usage.GFXActivity.float(),
+		VRAMTotalMemory:             m.MemUsage.TotalVRAM.bytes(),
+		VRAMTotalUsedMemory:         m.MemUsage.UsedVRAM.bytes(),
+		PowerLimit:                  powerLimit.float(),
+		FanSpeed:                    gpu.UnsupportedFloat(),
+	}
+
+	serial := s.Board.ProductSerial
+	if serial == "" || gpu.IsNotSupported(serial) {
+		serial = s.ASIC.ASICSerial
+	}
+	info := gpu.GPUInfo{
+		Num:                         s.GPU,
+		DeviceID:                    s.ASIC.DeviceID,
+		DeviceRev:                   s.ASIC.RevID,
+		TemperatureEdge:             formatFloat(metrics.TemperatureEdge),
+		TemperatureJunction:         formatFloat(metrics.TemperatureJunction),
+		TemperatureMemory:           formatFloat(metrics.TemperatureMemory),
+		AverageGraphicsPackagePower: formatFloat(metrics.AverageGraphicsPackagePower),
+		GPUUse:                      formatFloat(metrics.GPUUse),
+		SerialNumber:                serial,
+		VRAMTotalMemory:             metrics.VRAMTotalMemory.String(),
+		VRAMTotalUsedMemory:         metrics.VRAMTotalUsedMemory.String(),
+		CardSeries:                  s.ASIC.DeviceID,
+		CardModel:                   s.ASIC.MarketName,
+		CardVendor:                  s.ASIC.VendorName,
+		CardSKU:                     s.VBIOS.PartNumber,
+		PCIBus:                      gpu.CanonicalPCIAddress(s.Bus.BDF),
+		Metrics:                     metrics,
+	}
+
+	if m.ECC.TotalCorrectableCount.set || m.ECC.TotalUncorrectableCount.set {
+		info.ECC = &gpu.ECCInfo{
+			Mode: eccMode(s.RAS.ECCBlockState),
+			Volatile: gpu.ECCCounts{
+				Corrected:   gpu.ParseUintMetric(m.ECC.TotalCorrectableCount.String()),
+				Uncorrected: gpu.ParseUintMetric(m.ECC.TotalUncorrectableCount.String()),
+			},
+		}
+		info.Health = gpu.CheckHealth(info)
+	}
+	if compute := s.Partition.ComputePartition.String(); compute != "" && !gpu.IsNotSupported(compute) {
+		id, _ := strconv.Atoi(s.Partition.PartitionID.String())
+		info.Partition = &gpu.Partition{
+			ID:      id,
+			Compute: compute,
+			Memory:  s.Partition.MemoryPartition.String(),
+		}
+	}
+	if status := m.XGMIErr.String(); status != "" && !gpu.IsNotSupported(status) {
+		info.Interconnect = &gpu.Interconnect{Type: "XGMI", Status: status}
+	}
+	// GPUs with several XCDs report a clock for each; they run in lockstep,
+	// so the first stands for all of them.
+	gfx, hasGFX := m.Clock["gfx_0"]
+	mem, hasMem := m.Clock["mem_0"]
+	if hasGFX || hasMem {
+		info.Clocks = &gpu.Clocks{
+			Graphics:    gfx.Clk.float(),
+			Memory:      mem.Clk.float(),
+			MaxGraphics: gfx.MaxClk.float(),
+			MaxMemory:   mem.MaxClk.float(),
+		}
+	}
+	return info
+}
+
+// memoryTemperature returns the "mem" sensor, or the hottest HBM stack on
+// amd-smi versions that report them one by one as "hbm_0", "hbm_1", ...
+func memoryTemperature(temps map[string]amdSMIValue) gpu.FloatMetric {
+	if m := temps["mem"].float(); m.Available() {
+		return m
+	}
+	hottest := gpu.UnsupportedFloat()
+	for name, v := range temps {
+		if !strings.HasPrefix(name, "hbm_") {
+			continue
+		}
+		if m := v.float(); m.Available() && (!hottest.Available() || m.Value > hottest.Value) {
+			hottest = m
+		}
+	}
+	return hottest
+}
+
+// eccMode reports the ECC state of the UMC (memory controller) block:
+// "enabled", "disabled", or "" if amd-smi did not report it.
+func eccMode(blockState json.RawMessage) string {
+	var blocks map[string]string
+	if err := json.Unmarshal(blockState, &blocks); err != nil {
+		return ""
+	}
+	return gpu.ParseMode(blocks["UMC"])
+}
@@ -0,0 +1,131 @@
+package amd
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	//go:embed testdata/amd-smi-static.json
+	amdSMIStaticOutput []byte
+	//go:embed testdata/amd-smi-metric.json
+	amdSMIMetricOutput []byte
+)
+
+func TestParseAMDSMI(t *testing.T) {
+	list, err := parseAMDSMI(amdSMIStaticOutput, amdSMIMetricOutput)
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+
+	gpu0 := list.GPUInfos[0]
+	assert.Equal(t, 0, gpu0.Num)
+	assert.Equal(t, "0x74a1", gpu0.DeviceID)
+	assert.Equal(t, "AMD Instinct MI300X", gpu0.CardModel)
+	assert.Equal(t, "113-M3000100-102", gpu0.CardSKU)
+	assert.Equal(t, "692351001132", gpu0.SerialNumber)
+	assert.Equal(t, "0000:0c:00.0", gpu0.PCIBus)
+	assert.Equal(t, "N/A", gpu0.TemperatureEdge)
+	assert.Equal(t, "61.0", gpu0.TemperatureJunction)
+	assert.Equal(t, "51.0", gpu0.TemperatureMemory, "hottest HBM stack")
+	assert.Equal(t, "412.0", gpu0.AverageGraphicsPackagePower)
+	assert.Equal(t, "37.0", gpu0.GPUUse)
+	assert.Equal(t, gpu.GPUMetrics{
+		TemperatureEdge:             gpu.UnsupportedFloat(),
+		TemperatureJunction:         gpu.Float(61),
+		TemperatureMemory:           gpu.Float(51),
+		AverageGraphicsPackagePower: gpu.Float(412),
+		GPUUse:                      gpu.Float(37),
+		VRAMTotalMemory:             gpu.Uint(49136 << 20),
+		VRAMTotalUsedMemory:         gpu.Uint(283 << 20),
+		PowerLimit:                  gpu.Float(750),
+		FanSpeed:                    gpu.UnsupportedFloat(),
+	}, gpu0.Metrics)
+	assert.Equal(t, &gpu.ECCInfo{
+		Mode:     "enabled",
+		Volatile: gpu.ECCCounts{Corrected: gpu.Uint(3), Uncorrected: gpu.Uint(0)},
+	}, gpu0.ECC)
+	assert.Equal(t, gpu.NewHealth(), gpu0.Health)
+	assert.Equal(t, &gpu.Partition{ID: 0, Compute: "CPX", Memory: "NPS4"}, gpu0.Partition)
+	assert.Equal(t, &gpu.Interconnect{Type: "XGMI", Status: "NO_ERROR"}, gpu0.Interconnect)
+	assert.Equal(t, &gpu.Clocks{
+		Graphics:    gpu.Float(1420),
+		Memory:      gpu.Float(1300),
+		MaxGraphics: gpu.Float(2100),
+		MaxMemory:   gpu.Float(1300),
+	}, gpu0.Clocks)
+
+	gpu1 := list.GPUInfos[1]
+	assert.Equal(t, 1, gpu1.Num)
+	assert.Equal(t, 1, gpu1.Partition.ID)
+	assert.Equal(t, gpu.MetricUnsupported, gpu1.Metrics.AverageGraphicsPackagePower.Status)
+	assert.Equal(t, gpu.MetricUnsupported, gpu1.Metrics.TemperatureMemory.Status)
+	assert.Nil(t, gpu1.Interconnect)
+}
+
+func TestParseAMDSMIMinimal(t *testing.T) {
+	// Consumer cards have no partitions, ECC or XGMI, and older amd-smi
+	// prints bare numbers.
+	static := `[{"gpu": 0, "asic": {"market_name": "Radeon RX 7900 XTX", "device_id": "0x744c"}, "bus": {"bdf": "0000:03:00.0"}, "partition": {"compute_partition": "N/A", "memory_partition": "N/A"}}]`
+	metric := `[{"gpu": 0, "usage": {"gfx_activity": 5}, "power": {"average_socket_power": 41}, "temperature": {"edge": 38, "hotspot": 40, "mem": 44}, "xgmi_err": "N/A", "mem_usage": {"total_vram": {"value": "24560", "unit": "MB"}, "used_vram": {"value": "1022", "unit": "MB"}}}]`
+
+	list, err := parseAMDSMI([]byte(static), []byte(metric))
+	assert.NoError(t, err)
+	info := list.GPUInfos[0]
+	assert.Equal(t, gpu.GPUMetrics{
+		TemperatureEdge:             gpu.Float(38),
+		TemperatureJunction:         gpu.Float(40),
+		TemperatureMemory:           gpu.Float(44),
+		AverageGraphicsPackagePower: gpu.Float(41),
+		GPUUse:                      gpu.Float(5),
+		VRAMTotalMemory:             gpu.Uint(24560 << 20),
+		VRAMTotalUsedMemory:         gpu.Uint(1022 << 20),
+		PowerLimit:                  gpu.UnsupportedFloat(),
+		FanSpeed:                    gpu.UnsupportedFloat(),
+	}, info.Metrics)
+	assert.Nil(t, info.ECC)
+	assert.Nil(t, info.Health)
+	assert.Nil(t, info.Partition)
+	assert.Nil(t, info.Interconnect)
+	assert.Nil(t, info.Clocks)
+
+	_, err = parseAMDSMI([]byte("not json"), []byte(metric))
+	assert.Error(t, err)
+}
+
+func TestLoaderPrefersAMDSMI(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(amdSMIStaticOutput, "amd-smi", "static", "--json").
+		Stdout(amdSMIMetricOutput, "amd-smi", "metric", "--json").
+		Path(rocmSMIPath, rocmSMIPath)
+	a := New()
+	a.sysfs.root = "testdata/sysfs"
+	a.SetExecutor(fake)
+
+	list, err := a.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+	assert.Equal(t, "AMD Instinct MI300X", list.GPUInfos[0].CardModel)
+
+	info, err := a.DriverInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "6.8.5", info.Version)
+	assert.Equal(t, "022.040.003.043.000001", info.FirmwareVersion)
+	assert.Equal(t, "amd-smi", info.InstallPath)
+}
+
+func TestAMDSMIFromOptRocm(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Path("/opt/rocm/bin/amd-smi", "/opt/rocm/bin/amd-smi")
+	a := &amdSMICommand{}
+	a.SetExecutor(fake)
+	assert.True(t, a.Available())
+	assert.Empty(t, fake.Calls(), "Available only looks amd-smi up")
+
+	a.SetExecutor(gpu.NewScriptedExecutor())
+	assert.False(t, a.Available())
+}
+
+var _ gpu.ContextGPUInfoLoader = &amdSMICommand{}
@@ -0,0 +1,76 @@
+package amd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+var rasGPUHeader = regexp.MustCompile(`^GPU\[(\d+)\]`)
+
+// parseShowRasInfo parses "rocm-smi --showrasinfo all", which has no JSON
+// form, into the ECC state of each GPU by number:
+//
+//	GPU[0]		: RAS INFO
+//	         Block     Status  Correctable Error  Uncorrectable Error
+//	           UMC    ENABLED                  3                    0
+//	           GFX    ENABLED                  1                    0
+//	       PCIE_BIF   DISABLED
+//
+// The mode is that of the UMC (memory controller) block, as with amd-smi,
+// and the counts are summed over the blocks. rocm-smi reads them from the
+// driver, so they are errors since it was loaded.
+func parseShowRasInfo(output []byte) map[int]*gpu.ECCInfo {
+	eccs := map[int]*gpu.ECCInfo{}
+	var ecc *gpu.ECCInfo
+	for _, line := range strings.Split(string(output), "\n") {
+		if m := rasGPUHeader.FindStringSubmatch(line); m != nil {
+			num, _ := strconv.Atoi(m[1])
+			ecc = &gpu.ECCInfo{Volatile: gpu.ECCCounts{
+				Corrected:   gpu.UnsupportedUint(),
+				Uncorrected: gpu.UnsupportedUint(),
+			}}
+			eccs[num] = ecc
+			continue
+		}
+		fields := strings.Fields(line)
+		if ecc == nil || len(fields) < 2 {
+			continue
+		}
+		if fields[0] == "UMC" {
+			ecc.Mode = gpu.ParseMode(fields[1])
+		}
+		if len(fields) != 4 {
+			continue
+		}
+		ecc.Volatile.Corrected = addCount(ecc.Volatile.Corrected, fields[2])
+		ecc.Volatile.Uncorrected = addCount(ecc.Volatile.Uncorrected, fields[3])
+	}
+	return eccs
+}
+
+// addCount adds a count to sum. Counts that are not numbers, such as those
+// of the header row, are skipped.
+func addCount(sum gpu.UintMetric, count string) gpu.UintMetric {
+	n, err := strconv.ParseUint(count, 10, 64)
+	if err != nil {
+		return sum
+	}
+	if !sum.Available() {
+		return gpu.Uint(n)
+	}
+	return gpu.Uint(sum.Value + n)
+}
+
+// fillRASInfo sets the ECC state and health of the GPUs that
+// parseShowRasInfo reported.
+func fillRASInfo(gpus []gpu.GPUInfo, eccs map[int]*gpu.ECCInfo) {
+	for i := range gpus {
+		if ecc, ok := eccs[gpus[i].Num]; ok {
+			gpus[i].ECC = ecc
+			gpus[i].Health = gpu.CheckHealth(gpus[i])
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package amd
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/rocm-smi-showrasinfo.txt
+var rocmSMIRASOutput []byte
+
+func TestParseShowRasInfo(t *testing.T) {
+	eccs := parseShowRasInfo(rocmSMIRASOutput)
+	assert.Len(t, eccs, 2)
+	assert.Equal(t, &gpu.ECCInfo{
+		Mode:     "enabled",
+		Volatile: gpu.ECCCounts{Corrected: gpu.Uint(4), Uncorrected: gpu.Uint(0)},
+	}, eccs[0])
+	assert.Equal(t, gpu.ECCCounts{Corrected: gpu.Uint(0), Uncorrected: gpu.Uint(2)}, eccs[1].Volatile)
+
+	// A card whose blocks are all disabled reports no counts.
+	eccs = parseShowRasInfo([]byte("GPU[0]\t\t: RAS INFO\n    Block   Status  Correctable Error  Uncorrectable Error\n      UMC  DISABLED\n"))
+	assert.Equal(t, "disabled", eccs[0].Mode)
+	assert.Equal(t, gpu.MetricUnsupported, eccs[0].Volatile.Corrected.Status)
+
+	assert.Empty(t, parseShowRasInfo([]byte("WARNING: No RAS information found\n")))
+}
+
+func TestLoadWithRASInfo(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(rocmSMIOutput, rocmSMIPath, "-i", "--showmeminfo", "vram", "--showpower", "--showserial", "--showuse", "--showtemp", "--showproductname", "--showbus", "--showclocks", "--json").
+		Stdout(rocmSMIRASOutput, rocmSMIPath, "--showrasinfo", "all")
+	amd := &rocmSMICommand{executor: fake}
+
+	list, err := amd.Load()
+	assert.NoError(t, err)
+	byNum := map[int]gpu.GPUInfo{}
+	for _, info := range list.GPUInfos {
+		byNum[info.Num] = info
+	}
+	assert.Equal(t, gpu.Uint(4), byNum[0].ECC.Volatile.Corrected)
+	assert.Equal(t, gpu.NewHealth(), byNum[0].Health)
+	assert.Equal(t, gpu.NewHealth(gpu.ReasonECCUncorrectable), byNum[1].Health)
+}
+
+func TestRASInfoNotQueriedAgainWhenUnsupported(t *testing.T) {
+	query := []string{rocmSMIPath, "-i", "--showmeminfo", "vram", "--showpower", "--showserial", "--showuse", "--showtemp", "--showproductname", "--showbus", "--showclocks", "--json"}
+	fake := gpu.NewScriptedExecutor().
+		Stdout(rocmSMIOutput, query...).
+		Script(gpu.Result{ExitCode: 2}, rocmSMIPath, "--showrasinfo", "all")
+	amd := &rocmSMICommand{executor: fake}
+
+	for range 3 {
+		list, err := amd.Load()
+		assert.NoError(t, err)
+		assert.Nil(t, list.GPUInfos[0].ECC)
+	}
+	var rasQueries int
+	for _, call := range fake.Calls() {
+		if call.String() == rocmSMIPath+" --showrasinfo all" {
+			rasQueries++
+		}
+	}
+	assert.Equal(t, 1, rasQueries)
+}
@@ -0,0 +1,196 @@
+package amd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+// amdPCIVendor is the PCI vendor ID of AMD/ATI devices as written in sysfs.
+const amdPCIVendor = "0x1002"
+
+// cardName matches DRM card nodes, skipping connectors such as card0-DP-1.
+var cardName = regexp.MustCompile(`^card(\d+)$`)
+
+// sysfsLoader reads amdgpu devices from /sys/class/drm directly, without
+// rocm-smi or any other tool, so it works on every architecture the amdgpu
+// driver runs on.
+type sysfsLoader struct {
+	// root is prepended to every path read, so tests can use a fake tree.
+	root string
+}
+
+func (s *sysfsLoader) Load() (*gpu.GPUInfoList, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return s.LoadContext(ctx)
+}
+
+// LoadContext only checks ctx before reading, sysfs reads do not block.
+func (s *sysfsLoader) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cards, err := s.cards()
+	if err != nil {
+		return nil, err
+	}
+	list := &gpu.GPUInfoList{GPUInfos: make([]gpu.GPUInfo, 0, len(cards))}
+	for _, card := range cards {
+		list.GPUInfos = append(list.GPUInfos, s.readCard(card))
+	}
+	return list, nil
+}
+
+func (s *sysfsLoader) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return s.AvailableContext(ctx)
+}
+
+func (s *sysfsLoader) AvailableContext(ctx context.Context) bool {
+	cards, err := s.cards()
+	return err == nil && len(cards) > 0
+}
+
+func (s *sysfsLoader) Vendor() string {
+	return "AMD"
+}
+
+// sysfsCard is a DRM card node driven by amdgpu.
+type sysfsCard struct {
+	num    int
+	device string // path of the card's device directory
+}
+
+// cards returns the AMD cards below /sys/class/drm ordered by card number.
+func (s *sysfsLoader) cards() ([]sysfsCard, error) {
+	drm := filepath.Join(s.root, "sys", "class", "drm")
+	entries, err := os.ReadDir(drm)
+	if err != nil {
+		return nil, err
+	}
+	var cards []sysfsCard
+	for _, entry := range entries {
+		m := cardName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		device := filepath.Join(drm, entry.Name(), "device")
+		if readString(device, "vendor") != amdPCIVendor {
+			continue
+		}
+		// Cards without VRAM accounting are not driven by amdgpu, e.g.
+		// older GPUs bound to radeon.
+		if _, err := os.Stat(filepath.Join(device, "mem_info_vram_total")); err != nil {
+			continue
+		}
+		num, _ := strconv.Atoi(m[1])
+		cards = append(cards, sysfsCard{num: num, device: device})
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].num < cards[j].num })
+	return cards, nil
+}
+
+func (s *sysfsLoader) readCard(card sysfsCard) gpu.GPUInfo {
+	temps := readTemperatures(card.device)
+	metrics := gpu.GPUMetrics{
+		TemperatureEdge:             temps["edge"],
+		TemperatureJunction:         temps["junction"],
+		TemperatureMemory:           temps["mem"],
+		AverageGraphicsPackagePower: readPower(card.device, "power1_average", "power1_input"),
+		GPUUse:                      gpu.ParseFloatMetric(readString(card.device, "gpu_busy_percent")),
+		VRAMTotalMemory:             gpu.ParseUintMetric(readString(card.device, "mem_info_vram_total")),
+		VRAMTotalUsedMemory:         gpu.ParseUintMetric(readString(card.device, "mem_info_vram_used")),
+		PowerLimit:                  readPower(card.device, "power1_cap"),
+		FanSpeed:                    gpu.UnsupportedFloat(),
+	}
+
+	info := gpu.GPUInfo{
+		Num:                         card.num,
+		DeviceID:                    readString(card.device, "device"),
+		DeviceRev:                   readString(card.device, "revision"),
+		TemperatureEdge:             formatFloat(metrics.TemperatureEdge),
+		TemperatureJunction:         formatFloat(metrics.TemperatureJunction),
+		TemperatureMemory:           formatFloat(metrics.TemperatureMemory),
+		AverageGraphicsPackagePower: formatFloat(metrics.AverageGraphicsPackagePower),
+		GPUUse:                      metrics.GPUUse.String(),
+		SerialNumber:                readString(card.device, "unique_id"),
+		VRAMTotalMemory:             metrics.VRAMTotalMemory.String(),
+		VRAMTotalUsedMemory:         metrics.VRAMTotalUsedMemory.String(),
+		CardSeries:                  readString(card.device, "device"),
+		CardModel:                   readString(card.device, "product_name"),
+		CardVendor:                  "Advanced Micro Devices, Inc. [AMD/ATI]",
+		CardSKU:                     readString(card.device, "product_number"),
+		Metrics:                     metrics,
+	}
+	if target, err := filepath.EvalSymlinks(card.device); err == nil {
+		info.PCIBus = gpu.CanonicalPCIAddress(filepath.Base(target))
+	}
+	return info
+}
+
+// readTemperatures reads the hwmon temperatures of a card by label ("edge",
+// "junction", "mem"), converting from millidegrees Celsius. Sensors the
+// card does not have are unsupported.
+func readTemperatures(device string) map[string]gpu.FloatMetric {
+	temps := map[string]gpu.FloatMetric{
+		"edge":     gpu.UnsupportedFloat(),
+		"junction": gpu.UnsupportedFloat(),
+		"mem":      gpu.UnsupportedFloat(),
+	}
+	inputs, _ := filepath.Glob(filepath.Join(device, "hwmon", "hwmon*", "temp*_input"))
+	for _, input := range inputs {
+		label := readString(strings.TrimSuffix(input, "_input") + "_label")
+		if _, ok := temps[label]; !ok {
+			continue
+		}
+		m := gpu.ParseFloatMetric(readString(input))
+		if m.Available() {
+			m.Value /= 1000
+		}
+		temps[label] = m
+	}
+	return temps
+}
+
+// readPower reads the first of the hwmon power files names in watts. Power
+// files are in microwatts; newer kernels have power1_input instead of
+// power1_average.
+func readPower(device string, names ...string) gpu.FloatMetric {
+	for _, name := range names {
+		files, _ := filepath.Glob(filepath.Join(device, "hwmon", "hwmon*", name))
+		if len(files) == 0 {
+			continue
+		}
+		m := gpu.ParseFloatMetric(readString(files[0]))
+		if m.Available() {
+			m.Value /= 1e6
+		}
+		return m
+	}
+	return gpu.UnsupportedFloat()
+}
+
+// formatFloat keeps the one-decimal format rocm-smi uses.
+func formatFloat(m gpu.FloatMetric) string {
+	if !m.Available() {
+		return gpu.NotAvailable
+	}
+	return fmt.Sprintf("%.1f", m.Value)
+}
+
+func readString(elem ...string) string {
+	data, err := os.ReadFile(filepath.Join(elem...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
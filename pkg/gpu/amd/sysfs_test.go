@@ -0,0 +1,137 @@
+package amd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSysfsLoad(t *testing.T) {
+	s := &sysfsLoader{root: "testdata/sysfs"}
+	assert.True(t, s.Available())
+
+	list, err := s.Load()
+	assert.NoError(t, err)
+	// card2 is the BMC's ASPEED VGA; connectors and render nodes are skipped.
+	assert.Len(t, list.GPUInfos, 2)
+
+	card0 := list.GPUInfos[0]
+	assert.Equal(t, 0, card0.Num)
+	assert.Equal(t, "0x740f", card0.DeviceID)
+	assert.Equal(t, "AMD Instinct MI210", card0.CardModel)
+	assert.Equal(t, "5c88007d760374f3", card0.SerialNumber)
+	assert.Equal(t, "0000:03:00.0", card0.PCIBus)
+	assert.Equal(t, "36.0", card0.TemperatureEdge)
+	assert.Equal(t, "42.0", card0.AverageGraphicsPackagePower)
+	assert.Equal(t, "7", card0.GPUUse)
+	assert.Equal(t, "17163091968", card0.VRAMTotalMemory)
+	assert.Equal(t, gpu.GPUMetrics{
+		TemperatureEdge:             gpu.Float(36),
+		TemperatureJunction:         gpu.Float(41),
+		TemperatureMemory:           gpu.Float(44),
+		AverageGraphicsPackagePower: gpu.Float(42),
+		GPUUse:                      gpu.Float(7),
+		VRAMTotalMemory:             gpu.Uint(17163091968),
+		VRAMTotalUsedMemory:         gpu.Uint(283090944),
+		PowerLimit:                  gpu.Float(300),
+		FanSpeed:                    gpu.UnsupportedFloat(),
+	}, card0.Metrics)
+
+	// An APU with only an edge sensor and power1_input.
+	card1 := list.GPUInfos[1]
+	assert.Equal(t, "0000:c3:00.0", card1.PCIBus)
+	assert.Equal(t, gpu.Float(9.5), card1.Metrics.AverageGraphicsPackagePower)
+	assert.Equal(t, gpu.MetricUnsupported, card1.Metrics.TemperatureJunction.Status)
+	assert.Equal(t, gpu.MetricUnsupported, card1.Metrics.PowerLimit.Status)
+	assert.Equal(t, gpu.NotAvailable, card1.TemperatureMemory)
+	assert.Equal(t, "", card1.CardModel)
+}
+
+func TestSysfsWithoutCards(t *testing.T) {
+	s := &sysfsLoader{root: t.TempDir()}
+	assert.False(t, s.Available())
+	_, err := s.LoadContext(context.Background())
+	assert.Error(t, err)
+}
+
+func TestLoaderFallsBackToSysfs(t *testing.T) {
+	a := New()
+	a.sysfs.root = "testdata/sysfs"
+	a.SetExecutor(gpu.NewScriptedExecutor()) // no rocm-smi
+
+	assert.True(t, a.Available())
+	list, err := a.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+
+	a.sysfs.root = t.TempDir()
+	assert.False(t, a.Available())
+	_, err = a.Load()
+	assert.Error(t, err)
+}
+
+func TestLoaderAvailableRunsNoTool(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(amdSMIStaticOutput, "amd-smi", "static", "--json").
+		Stdout(amdSMIMetricOutput, "amd-smi", "metric", "--json")
+	a := New()
+	a.SetExecutor(fake)
+
+	assert.True(t, a.Available())
+	assert.Empty(t, fake.Calls())
+	_, err := a.Load()
+	assert.NoError(t, err)
+	assert.Len(t, fake.Calls(), 2, "Load runs only the amd-smi queries")
+}
+
+func TestLoaderFallsBackToRocmSMIWhenAMDSMIFails(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Script(gpu.Result{Stderr: []byte("ImportError: libamd_smi.so"), ExitCode: 1}, "amd-smi", "static", "--json").
+		Stdout(rocmSMIOutput, rocmSMIPath, "-i", "--showmeminfo", "vram", "--showpower", "--showserial", "--showuse", "--showtemp", "--showproductname", "--showbus", "--showclocks", "--json")
+	a := New()
+	a.sysfs.root = t.TempDir()
+	a.SetExecutor(fake)
+
+	list, err := a.Load()
+	var partial *gpu.PartialError
+	assert.ErrorAs(t, err, &partial)
+	assert.NotEmpty(t, list.GPUInfos)
+}
+
+func TestLoaderFallsBackToSysfsWhenRocmSMIFails(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Path(rocmSMIPath, rocmSMIPath)
+	a := New()
+	a.sysfs.root = "testdata/sysfs"
+	a.SetExecutor(fake)
+
+	list, err := a.Load()
+	var partial *gpu.PartialError
+	assert.ErrorAs(t, err, &partial, "the rocm-smi failure is reported")
+	assert.Len(t, list.GPUInfos, 2)
+}
+
+func TestLoaderPrefersRocmSMI(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(nil, rocmSMIPath).
+		Stdout(rocmSMIOutput, rocmSMIPath, "-i", "--showmeminfo", "vram", "--showpower", "--showserial", "--showuse", "--showtemp", "--showproductname", "--showbus", "--showclocks", "--json")
+	a := New()
+	a.sysfs.root = "testdata/sysfs"
+	a.SetExecutor(fake)
+
+	list, err := a.Load()
+	assert.NoError(t, err)
+	byNum := map[int]gpu.GPUInfo{}
+	for _, info := range list.GPUInfos {
+		byNum[info.Num] = info
+	}
+	assert.Equal(t, "0000:83:00.0", byNum[1].PCIBus)
+}
+
+var (
+	_ gpu.ContextGPUInfoLoader = &sysfsLoader{}
+	_ gpu.ContextGPUInfoLoader = New()
+	_ gpu.ContextDriverGetter  = New()
+	_ gpu.ExecutorSetter       = New()
+)
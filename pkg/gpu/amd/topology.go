@@ -0,0 +1,51 @@
+package amd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+// Topology reads the links between the GPUs from rocm-smi. Without rocm-smi
+// they come from the PCIe hierarchy of the cards, as for loaders that cannot
+// report links at all.
+func (a *amdGPU) Topology(ctx context.Context) (*gpu.Topology, error) {
+	if a.smi.AvailableContext(ctx) {
+		return a.smi.Topology(ctx)
+	}
+	list, err := a.LoadContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gpu.DefaultPCIEnumerator.Topology(list.GPUInfos)
+}
+
+// Topology reads the links between the GPUs from "rocm-smi --showtopo".
+func (r *rocmSMICommand) Topology(ctx context.Context) (*gpu.Topology, error) {
+	result, err := r.run(ctx, "--showtopo")
+	if err != nil {
+		return nil, err
+	}
+	return parseShowTopo(result.Stdout)
+}
+
+// parseShowTopo takes the link type section of "rocm-smi --showtopo". The
+// weight and hop sections are ignored. rocm-smi does not tell which path a
+// PCIe link takes, so those have type gpu.LinkPCIe.
+//
+//	=============== Link Type between two GPUs ===============
+//	       GPU0         GPU1
+//	GPU0   0            XGMI
+//	GPU1   XGMI         0
+func parseShowTopo(output []byte) (*gpu.Topology, error) {
+	_, section, ok := strings.Cut(string(output), "Link Type between two GPUs")
+	if !ok {
+		return nil, fmt.Errorf("no link types in rocm-smi --showtopo output")
+	}
+	if end := strings.Index(section, "\n="); end >= 0 {
+		section = section[:end]
+	}
+	return gpu.ParseTopologyMatrix([]byte(section), "GPU")
+}
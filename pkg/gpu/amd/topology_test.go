@@ -0,0 +1,53 @@
+package amd
+
+import (
+	"context"
+	_ "embed"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/rocm-smi-showtopo.txt
+var showTopoOutput []byte
+
+func TestParseShowTopo(t *testing.T) {
+	topo, err := parseShowTopo(showTopoOutput)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3}, topo.Devices)
+	assert.Equal(t, gpu.Link{Type: gpu.LinkXGMI, Bandwidth: gpu.BandwidthFabric}, topo.Link(0, 2))
+	assert.Equal(t, gpu.Link{Type: gpu.LinkPCIe, Bandwidth: gpu.BandwidthUnknown}, topo.Link(3, 1))
+	assert.Equal(t, gpu.LinkSelf, topo.Link(1, 1).Type)
+
+	_, err = parseShowTopo([]byte("ERROR: GPU[0] : Unable to read topology"))
+	assert.Error(t, err)
+}
+
+func TestTopologyFromRocmSMI(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(nil, rocmSMIPath).
+		Stdout(showTopoOutput, rocmSMIPath, "--showtopo")
+	a := New()
+	a.SetExecutor(fake)
+
+	topo, err := a.Topology(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, topo.Devices, 4)
+}
+
+func TestTopologyWithoutRocmSMI(t *testing.T) {
+	defer func(e *gpu.PCIEnumerator) { gpu.DefaultPCIEnumerator = e }(gpu.DefaultPCIEnumerator)
+	gpu.DefaultPCIEnumerator = &gpu.PCIEnumerator{Root: "testdata/sysfs"}
+	a := New()
+	a.sysfs.root = "testdata/sysfs"
+	a.SetExecutor(gpu.NewScriptedExecutor())
+
+	// The fixture has no /sys/devices hierarchy, so the links are unknown.
+	topo, err := a.Topology(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, topo.Devices)
+	assert.Equal(t, gpu.LinkUnknown, topo.Link(0, 1).Type)
+}
+
+var _ gpu.TopologyReporter = &amdGPU{}
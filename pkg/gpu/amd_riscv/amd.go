@@ -1,9 +1,9 @@
 package amdriscv
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -20,9 +20,25 @@ func init() {
 }
 
 type amdRISCVGPU struct {
+	executor gpu.Executor
+}
+
+func (a *amdRISCVGPU) SetExecutor(e gpu.Executor) {
+	a.executor = e
 }
 
 func (a *amdRISCVGPU) Load() (*gpu.GPUInfoList, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.LoadContext(ctx)
+}
+
+// LoadContext only checks ctx before sampling, go-radeontop reads sysfs
+// directly and has no cancellation support.
+func (a *amdRISCVGPU) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	mon, err := monitor.New(slog.Default())
 	if err != nil {
@@ -50,6 +66,20 @@ func (a *amdRISCVGPU) Load() (*gpu.GPUInfoList, error) {
 			VRAMTotalMemory:     fmt.Sprintf("%d", gpuInfo.Stats.VRAMTotal),
 			VRAMTotalUsedMemory: fmt.Sprintf("%d", gpuInfo.Stats.VRAMUsed),
 			CardSeries:          gpuInfo.DeviceID,
+			// go-radeontop does not sample the package power
+			AverageGraphicsPackagePower: gpu.NotAvailable,
+			Metrics: gpu.GPUMetrics{
+				// go-radeontop samples the usage, VRAM and edge sensor only
+				AverageGraphicsPackagePower: gpu.UnsupportedFloat(),
+				PowerLimit:                  gpu.UnsupportedFloat(),
+				FanSpeed:                    gpu.UnsupportedFloat(),
+				TemperatureJunction:         gpu.UnsupportedFloat(),
+				TemperatureMemory:           gpu.UnsupportedFloat(),
+				TemperatureEdge:             gpu.Float(float64(gpuInfo.Stats.GpuTempEdge)),
+				GPUUse:                      gpu.Float(float64(gpuInfo.Stats.GPUUsage)),
+				VRAMTotalMemory:             gpu.Uint(uint64(gpuInfo.Stats.VRAMTotal)),
+				VRAMTotalUsedMemory:         gpu.Uint(uint64(gpuInfo.Stats.VRAMUsed)),
+			},
 		})
 	}
 
@@ -57,6 +87,12 @@ func (a *amdRISCVGPU) Load() (*gpu.GPUInfoList, error) {
 }
 
 func (a *amdRISCVGPU) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.AvailableContext(ctx)
+}
+
+func (a *amdRISCVGPU) AvailableContext(ctx context.Context) bool {
 	// set slog print to console
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	// logger.Info("amdRISCVGPU Available", "os", runtime.GOOS, "arch", runtime.GOARCH)
@@ -66,8 +102,7 @@ func (a *amdRISCVGPU) Available() bool {
 	}
 
 	lsClassDrm := "/sys/class/drm/"
-	cmd := exec.Command("ls", lsClassDrm)
-	output, err := cmd.CombinedOutput()
+	output, err := gpu.CombinedOutput(ctx, a.executor, "ls", lsClassDrm)
 	if err != nil {
 		logger.Info("amdRISCVGPU Available", "lsClassDrm", lsClassDrm, "error", err, "return", false)
 		return false
@@ -77,9 +112,7 @@ func (a *amdRISCVGPU) Available() bool {
 		return false
 	}
 	devicePath := filepath.Join(lsClassDrm, "card0", "device", "device")
-	cmd = exec.Command("cat", devicePath)
-
-	output, err = cmd.CombinedOutput()
+	output, err = gpu.CombinedOutput(ctx, a.executor, "cat", devicePath)
 	if err != nil {
 		logger.Info("amdRISCVGPU Available", "devicePath", devicePath, "error", err, "return", false)
 		return false
@@ -0,0 +1,99 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultTimeout bounds a single Load or Available call made without a
+// context, so that a hung vendor tool cannot block the caller forever.
+var DefaultTimeout = 30 * time.Second
+
+// DefaultContext returns the context used by the context-free Load and
+// Available wrappers.
+func DefaultContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), DefaultTimeout)
+}
+
+// LoadWithContext loads GPU information from loader, honouring ctx. Loaders
+// that do not implement ContextGPUInfoLoader are run in a goroutine that is
+// abandoned once ctx is done.
+func LoadWithContext(ctx context.Context, loader GPUInfoLoader) (*GPUInfoList, error) {
+	if l, ok := loader.(ContextGPUInfoLoader); ok {
+		return l.LoadContext(ctx)
+	}
+
+	type result struct {
+		list *GPUInfoList
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		list, err := loader.Load()
+		done <- result{list, err}
+	}()
+	select {
+	case r := <-done:
+		return r.list, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// AvailableWithContext reports whether loader is available, honouring ctx
+// the same way as LoadWithContext.
+func AvailableWithContext(ctx context.Context, loader GPUInfoLoader) bool {
+	if l, ok := loader.(ContextGPUInfoLoader); ok {
+		return l.AvailableContext(ctx)
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- loader.Available()
+	}()
+	select {
+	case ok := <-done:
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// DriverInfoWithContext returns the driver information of loader, honouring
+// ctx the same way as LoadWithContext, or errors.ErrUnsupported if loader
+// cannot describe its driver. The kernel module fields are filled in by
+// DefaultKernelModuleDetector.
+func DriverInfoWithContext(ctx context.Context, loader GPUInfoLoader) (GPUDriverInfo, error) {
+	info, err := driverInfo(ctx, loader)
+	if err != nil {
+		return info, err
+	}
+	DefaultKernelModuleDetector.Fill(&info)
+	return info, nil
+}
+
+func driverInfo(ctx context.Context, loader GPUInfoLoader) (GPUDriverInfo, error) {
+	switch l := loader.(type) {
+	case ContextDriverGetter:
+		return l.DriverInfoContext(ctx)
+	case DriverGetter:
+		type result struct {
+			info GPUDriverInfo
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			info, err := l.DriverInfo()
+			done <- result{info, err}
+		}()
+		select {
+		case r := <-done:
+			return r.info, r.err
+		case <-ctx.Done():
+			return GPUDriverInfo{}, ctx.Err()
+		}
+	}
+	return GPUDriverInfo{}, fmt.Errorf("%s cannot report driver info: %w", loader.Vendor(), errors.ErrUnsupported)
+}
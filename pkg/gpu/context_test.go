@@ -0,0 +1,94 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type blockingLoader struct {
+	release chan struct{}
+}
+
+func (b *blockingLoader) Load() (*GPUInfoList, error) {
+	<-b.release
+	return &GPUInfoList{}, nil
+}
+
+func (b *blockingLoader) Available() bool {
+	<-b.release
+	return true
+}
+
+func (b *blockingLoader) Vendor() string { return "blocking" }
+
+type contextLoader struct {
+	blockingLoader
+	called bool
+}
+
+func (c *contextLoader) LoadContext(ctx context.Context) (*GPUInfoList, error) {
+	c.called = true
+	return nil, errors.New("from LoadContext")
+}
+
+func (c *contextLoader) AvailableContext(ctx context.Context) bool {
+	c.called = true
+	return true
+}
+
+func TestLoadWithContextAbandonsPlainLoader(t *testing.T) {
+	loader := &blockingLoader{release: make(chan struct{})}
+	defer close(loader.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	list, err := LoadWithContext(ctx, loader)
+	assert.Nil(t, list)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, AvailableWithContext(ctx, loader))
+}
+
+func TestLoadWithContextUsesContextLoader(t *testing.T) {
+	loader := &contextLoader{}
+
+	_, err := LoadWithContext(context.Background(), loader)
+	assert.EqualError(t, err, "from LoadContext")
+	assert.True(t, loader.called)
+	assert.True(t, AvailableWithContext(context.Background(), loader))
+}
+
+func TestDefaultContextHasDeadline(t *testing.T) {
+	ctx, cancel := DefaultContext()
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(DefaultTimeout), deadline, time.Second)
+}
+
+type driverStubLoader struct {
+	stubLoader
+	info GPUDriverInfo
+}
+
+func (d *driverStubLoader) DriverInfo() (GPUDriverInfo, error) { return d.info, nil }
+
+func TestDriverInfoWithContext(t *testing.T) {
+	old := DefaultKernelModuleDetector
+	DefaultKernelModuleDetector = &KernelModuleDetector{Root: t.TempDir()}
+	defer func() { DefaultKernelModuleDetector = old }()
+
+	want := GPUDriverInfo{Vendor: "Denglin", Version: "2.2.1-rc3", Installed: true}
+	got, err := DriverInfoWithContext(context.Background(), &driverStubLoader{info: want})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = DriverInfoWithContext(context.Background(), &stubLoader{vendor: "CPU"})
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	assert.ErrorContains(t, err, "CPU")
+}
@@ -1,6 +1,8 @@
 package cpu
 
 import (
+	"context"
+
 	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 )
 
@@ -12,6 +14,10 @@ type cpuSmiCommand struct {
 }
 
 func (c *cpuSmiCommand) Load() (*gpu.GPUInfoList, error) {
+	return c.LoadContext(context.Background())
+}
+
+func (c *cpuSmiCommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
 	list := &gpu.GPUInfoList{
 		GPUInfos: []gpu.GPUInfo{},
 	}
@@ -22,6 +28,10 @@ func (c *cpuSmiCommand) Available() bool {
 	return true
 }
 
+func (c *cpuSmiCommand) AvailableContext(ctx context.Context) bool {
+	return true
+}
+
 func (c *cpuSmiCommand) Vendor() string {
 	return "CPU"
 }
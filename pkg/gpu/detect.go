@@ -0,0 +1,266 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoaderStatus summarises what DetectAll got from one loader.
+type LoaderStatus int
+
+const (
+	// LoaderNotAvailable means the loader's vendor tool is not present.
+	LoaderNotAvailable LoaderStatus = iota
+	// LoaderOK means the loader returned its inventory without error.
+	LoaderOK
+	// LoaderFailed means the loader is available but returned no inventory.
+	LoaderFailed
+	// LoaderPartial means the loader returned an inventory together with an
+	// error, e.g. because some of its sub-queries failed.
+	LoaderPartial
+)
+
+var loaderStatusNames = map[LoaderStatus]string{
+	LoaderNotAvailable: "not_available",
+	LoaderOK:           "ok",
+	LoaderFailed:       "failed",
+	LoaderPartial:      "partial",
+}
+
+func (s LoaderStatus) String() string {
+	if name, ok := loaderStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("LoaderStatus(%d)", int(s))
+}
+
+func (s LoaderStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *LoaderStatus) UnmarshalText(text []byte) error {
+	for status, name := range loaderStatusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown loader status %q", text)
+}
+
+// PartialError is returned by loaders alongside an inventory that is usable
+// but incomplete. DetectAll reports any loader returning both an inventory
+// and an error as partial; PartialError lets a loader say which parts failed.
+type PartialError struct {
+	Errs []error
+}
+
+func (e *PartialError) Error() string {
+	return "partial result: " + errors.Join(e.Errs...).Error()
+}
+
+func (e *PartialError) Unwrap() []error {
+	return e.Errs
+}
+
+// DetectedGPU is a GPUInfo tagged with the vendor of the loader that
+// reported it.
+type DetectedGPU struct {
+	Vendor string `json:"vendor"`
+	// ID identifies the device across reboots and reindexing, see StableID.
+	ID string `json:"id"`
+	GPUInfo
+}
+
+// LoaderReport describes the outcome of one loader in DetectAll.
+type LoaderReport struct {
+	Vendor string       `json:"vendor"`
+	Status LoaderStatus `json:"status"`
+	GPUs   int          `json:"gpus"`
+	Err    error        `json:"-"`
+	Error  string       `json:"error,omitempty"` // Err.Error(), for JSON consumers
+}
+
+// Inventory is the combined result of DetectAll.
+type Inventory struct {
+	GPUs    []DetectedGPU  `json:"gpus"`
+	Reports []LoaderReport `json:"reports"`
+	// PCIDevices lists the GPUs and accelerators on the PCI bus, found
+	// without any vendor tool. It may hold devices missing from GPUs, e.g.
+	// because no driver is bound to them.
+	PCIDevices []PCIDevice `json:"pci_devices"`
+}
+
+// Undriven returns the PCI devices of known vendors that no driver is bound
+// to.
+func (inv *Inventory) Undriven() []PCIDevice {
+	undriven := []PCIDevice{}
+	for _, dev := range inv.PCIDevices {
+		if dev.Vendor != "" && !dev.Driven() {
+			undriven = append(undriven, dev)
+		}
+	}
+	return undriven
+}
+
+// Err returns the errors of all failed and partial loaders joined together,
+// or nil if every available loader succeeded.
+func (inv *Inventory) Err() error {
+	var errs []error
+	for _, r := range inv.Reports {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Vendor, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DefaultDetectConcurrency is the number of loaders DetectAll probes and
+// loads at the same time unless WithConcurrency says otherwise.
+var DefaultDetectConcurrency = 4
+
+// DetectOption configures DetectAll.
+type DetectOption func(*detectOptions)
+
+type detectOptions struct {
+	loaders     []GPUInfoLoader
+	concurrency int
+	timeout     time.Duration
+	pci         *PCIEnumerator
+}
+
+// WithLoaders makes DetectAll use loaders instead of the registered ones.
+func WithLoaders(loaders ...GPUInfoLoader) DetectOption {
+	return func(o *detectOptions) {
+		o.loaders = loaders
+	}
+}
+
+// WithConcurrency limits how many loaders DetectAll runs at the same time.
+// Values below one run the loaders one after another.
+func WithConcurrency(n int) DetectOption {
+	return func(o *detectOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithTimeout bounds the whole of DetectAll. Loaders still running when it
+// expires are reported as failed with context.DeadlineExceeded.
+func WithTimeout(d time.Duration) DetectOption {
+	return func(o *detectOptions) {
+		o.timeout = d
+	}
+}
+
+// WithPCIEnumerator makes DetectAll list PCI devices with e instead of
+// DefaultPCIEnumerator. A nil e skips the PCI scan.
+func WithPCIEnumerator(e *PCIEnumerator) DetectOption {
+	return func(o *detectOptions) {
+		o.pci = e
+	}
+}
+
+// DetectAll probes every registered loader, loads the available ones and
+// returns their GPUs tagged by vendor, together with one report per loader
+// in registration order. Loaders run concurrently, each probing and loading
+// in the same worker, so the inventory takes about as long as the slowest
+// vendor tool. The PCI devices are listed as well, so that GPUs whose
+// vendor tool or driver is missing still show up in PCIDevices, and the
+// Affinity of each GPU is read from the same sysfs tree.
+func DetectAll(ctx context.Context, opts ...DetectOption) *Inventory {
+	o := detectOptions{loaders: GetAllGPULoaders(), concurrency: DefaultDetectConcurrency, pci: DefaultPCIEnumerator}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	reports := make([]LoaderReport, len(o.loaders))
+	lists := make([]*GPUInfoList, len(o.loaders))
+	sem := make(chan struct{}, o.concurrency)
+	var wg sync.WaitGroup
+	for i, loader := range o.loaders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			reports[i], lists[i] = detectOne(ctx, loader)
+		}()
+	}
+	wg.Wait()
+
+	inv := &Inventory{GPUs: []DetectedGPU{}, Reports: reports, PCIDevices: []PCIDevice{}}
+	if o.pci != nil {
+		// Without sysfs, e.g. on other operating systems, there is simply
+		// nothing to list.
+		if devices, err := o.pci.Devices(); err == nil {
+			inv.PCIDevices = devices
+		}
+	}
+	for i, list := range lists {
+		if list == nil {
+			continue
+		}
+		if o.pci != nil {
+			o.pci.FillAffinity(list.GPUInfos)
+		}
+		for _, info := range list.GPUInfos {
+			inv.GPUs = append(inv.GPUs, DetectedGPU{Vendor: reports[i].Vendor, GPUInfo: info})
+		}
+	}
+	assignStableIDs(inv.GPUs)
+	return inv
+}
+
+func detectOne(ctx context.Context, loader GPUInfoLoader) (LoaderReport, *GPUInfoList) {
+	report := LoaderReport{Vendor: loader.Vendor()}
+	fail := func(err error) (LoaderReport, *GPUInfoList) {
+		report.Status = LoaderFailed
+		report.Err = err
+		report.Error = err.Error()
+		return report, nil
+	}
+	// A loader never probed because the deadline passed has failed rather
+	// than being not available.
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+	if !AvailableWithContext(ctx, loader) {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		return report, nil
+	}
+
+	list, err := LoadWithContext(ctx, loader)
+	switch {
+	case err == nil && list == nil:
+		report.Status = LoaderOK
+	case err == nil:
+		report.Status = LoaderOK
+		report.GPUs = len(list.GPUInfos)
+	case list != nil:
+		report.Status = LoaderPartial
+		report.GPUs = len(list.GPUInfos)
+	default:
+		report.Status = LoaderFailed
+	}
+	if err != nil {
+		report.Err = err
+		report.Error = err.Error()
+	}
+	return report, list
+}
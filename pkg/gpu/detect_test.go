@@ -0,0 +1,179 @@
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubLoader struct {
+	vendor    string
+	available bool
+	list      *GPUInfoList
+	err       error
+}
+
+func (s *stubLoader) Load() (*GPUInfoList, error) { return s.list, s.err }
+func (s *stubLoader) Available() bool             { return s.available }
+func (s *stubLoader) Vendor() string              { return s.vendor }
+
+func gpus(models ...string) *GPUInfoList {
+	list := &GPUInfoList{}
+	for i, model := range models {
+		list.GPUInfos = append(list.GPUInfos, GPUInfo{Num: i, CardModel: model})
+	}
+	return list
+}
+
+func TestDetectAll(t *testing.T) {
+	subQueryErr := errors.New("npu-smi info -t power -i 3 failed")
+	inv := DetectAll(context.Background(), WithPCIEnumerator(nil), WithLoaders(
+		&stubLoader{vendor: "NVIDIA", available: true, list: gpus("L20", "L20")},
+		&stubLoader{vendor: "AMD"},
+		&stubLoader{vendor: "Iluvatar", available: true, err: errors.New("ixsmi crashed")},
+		&stubLoader{vendor: "Huawei", available: true, list: gpus("Atlas 300I Duo"), err: &PartialError{Errs: []error{subQueryErr}}},
+	))
+
+	assert.Len(t, inv.GPUs, 3)
+	assert.Equal(t, "NVIDIA", inv.GPUs[0].Vendor)
+	assert.Equal(t, "L20", inv.GPUs[1].CardModel)
+	assert.Equal(t, "Huawei", inv.GPUs[2].Vendor)
+	assert.Equal(t, "Atlas 300I Duo", inv.GPUs[2].CardModel)
+
+	assert.Equal(t, []LoaderStatus{LoaderOK, LoaderNotAvailable, LoaderFailed, LoaderPartial},
+		[]LoaderStatus{inv.Reports[0].Status, inv.Reports[1].Status, inv.Reports[2].Status, inv.Reports[3].Status})
+	assert.Equal(t, 2, inv.Reports[0].GPUs)
+	assert.Equal(t, "ixsmi crashed", inv.Reports[2].Error)
+	assert.Equal(t, 1, inv.Reports[3].GPUs)
+	assert.True(t, errors.Is(inv.Reports[3].Err, subQueryErr))
+
+	err := inv.Err()
+	assert.ErrorContains(t, err, "Iluvatar: ixsmi crashed")
+	assert.True(t, errors.Is(err, subQueryErr))
+}
+
+func TestDetectAllNoErrors(t *testing.T) {
+	inv := DetectAll(context.Background(), WithPCIEnumerator(nil), WithLoaders(
+		&stubLoader{vendor: "NVIDIA", available: true, list: &GPUInfoList{}},
+		&stubLoader{vendor: "AMD"},
+	))
+	assert.Empty(t, inv.GPUs)
+	assert.NoError(t, inv.Err())
+}
+
+func TestDetectAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	inv := DetectAll(ctx, WithPCIEnumerator(nil), WithLoaders(&contextLoader{}))
+	assert.Equal(t, LoaderFailed, inv.Reports[0].Status)
+	assert.True(t, errors.Is(inv.Err(), context.Canceled))
+}
+
+func TestInventoryJSON(t *testing.T) {
+	inv := DetectAll(context.Background(), WithPCIEnumerator(nil), WithLoaders(
+		&stubLoader{vendor: "NVIDIA", available: true, list: gpus("L20")},
+		&stubLoader{vendor: "AMD", available: true, err: errors.New("rocm-smi failed")},
+	))
+	data, err := json.Marshal(inv)
+	assert.NoError(t, err)
+
+	var decoded struct {
+		GPUs []struct {
+			Vendor    string `json:"vendor"`
+			CardModel string `json:"Card model"`
+		} `json:"gpus"`
+		Reports []struct {
+			Vendor string `json:"vendor"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"reports"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "NVIDIA", decoded.GPUs[0].Vendor)
+	assert.Equal(t, "L20", decoded.GPUs[0].CardModel)
+	assert.Equal(t, "failed", decoded.Reports[1].Status)
+	assert.Equal(t, "rocm-smi failed", decoded.Reports[1].Error)
+}
+
+// slowLoader sleeps in Load and records how many loads overlap.
+type slowLoader struct {
+	vendor   string
+	delay    time.Duration
+	inFlight *atomic.Int32
+	maxSeen  *atomic.Int32
+}
+
+func (s *slowLoader) Load() (*GPUInfoList, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		seen := s.maxSeen.Load()
+		if n <= seen || s.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	time.Sleep(s.delay)
+	return gpus(s.vendor), nil
+}
+func (s *slowLoader) Available() bool { return true }
+func (s *slowLoader) Vendor() string  { return s.vendor }
+
+func slowLoaders(n int, delay time.Duration) ([]GPUInfoLoader, *atomic.Int32) {
+	var inFlight, maxSeen atomic.Int32
+	loaders := make([]GPUInfoLoader, n)
+	for i := range loaders {
+		loaders[i] = &slowLoader{vendor: fmt.Sprintf("vendor%d", i), delay: delay, inFlight: &inFlight, maxSeen: &maxSeen}
+	}
+	return loaders, &maxSeen
+}
+
+func TestDetectAllConcurrent(t *testing.T) {
+	loaders, maxSeen := slowLoaders(6, 50*time.Millisecond)
+
+	start := time.Now()
+	inv := DetectAll(context.Background(), WithPCIEnumerator(nil), WithLoaders(loaders...), WithConcurrency(6))
+	elapsed := time.Since(start)
+
+	assert.Len(t, inv.GPUs, 6)
+	assert.Less(t, elapsed, 250*time.Millisecond, "loaders should run in parallel")
+	assert.Greater(t, maxSeen.Load(), int32(1))
+	// Results keep registration order regardless of completion order.
+	for i, g := range inv.GPUs {
+		assert.Equal(t, fmt.Sprintf("vendor%d", i), g.Vendor)
+	}
+}
+
+func TestDetectAllConcurrencyLimit(t *testing.T) {
+	loaders, maxSeen := slowLoaders(6, 10*time.Millisecond)
+
+	inv := DetectAll(context.Background(), WithPCIEnumerator(nil), WithLoaders(loaders...), WithConcurrency(2))
+	assert.Len(t, inv.GPUs, 6)
+	assert.LessOrEqual(t, maxSeen.Load(), int32(2))
+
+	loaders, maxSeen = slowLoaders(3, 10*time.Millisecond)
+	DetectAll(context.Background(), WithPCIEnumerator(nil), WithLoaders(loaders...), WithConcurrency(0))
+	assert.Equal(t, int32(1), maxSeen.Load())
+}
+
+func TestDetectAllTimeout(t *testing.T) {
+	blocked := &blockingLoader{release: make(chan struct{})}
+	defer close(blocked.release)
+
+	start := time.Now()
+	inv := DetectAll(context.Background(), WithPCIEnumerator(nil),
+		WithLoaders(&stubLoader{vendor: "NVIDIA", available: true, list: gpus("L20")}, blocked),
+		WithTimeout(20*time.Millisecond))
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, LoaderOK, inv.Reports[0].Status)
+	assert.Equal(t, LoaderFailed, inv.Reports[1].Status)
+	assert.True(t, errors.Is(inv.Reports[1].Err, context.DeadlineExceeded))
+	assert.Len(t, inv.GPUs, 1)
+}
@@ -1,10 +1,10 @@
 package dl
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"math"
-	"os/exec"
 	"strconv"
 	"strings"
 
@@ -16,17 +16,28 @@ func init() {
 }
 
 type dlsmiCommand struct {
+	executor gpu.Executor
+}
+
+func (d *dlsmiCommand) SetExecutor(e gpu.Executor) {
+	d.executor = e
 }
 
 func (d *dlsmiCommand) Load() (*gpu.GPUInfoList, error) {
-	output, err := d.query()
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return d.LoadContext(ctx)
+}
+
+func (d *dlsmiCommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	output, err := d.query(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return parseDLSMIOutput(output)
 }
 
-func (d *dlsmiCommand) query() ([]byte, error) {
+func (d *dlsmiCommand) query(ctx context.Context) ([]byte, error) {
 	candidates := [][]string{
 		{"dlsmi", "query", "--xml-format"},
 		{"/usr/bin/dlsmi", "query", "--xml-format"},
@@ -35,11 +46,13 @@ func (d *dlsmiCommand) query() ([]byte, error) {
 
 	var lastErr error
 	for _, args := range candidates {
-		cmd := exec.Command(args[0], args[1:]...)
-		output, err := cmd.CombinedOutput()
+		output, err := gpu.CombinedOutput(ctx, d.executor, args[0], args[1:]...)
 		if err == nil {
 			return output, nil
 		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("dlsmi (%s) failed: %w", args[0], ctx.Err())
+		}
 		lastErr = fmt.Errorf("dlsmi (%s) failed: %w: %s", args[0], err, strings.TrimSpace(string(output)))
 	}
 
@@ -50,26 +63,77 @@ func (d *dlsmiCommand) query() ([]byte, error) {
 }
 
 func (d *dlsmiCommand) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return d.AvailableContext(ctx)
+}
+
+func (d *dlsmiCommand) AvailableContext(ctx context.Context) bool {
+	return d.lookPath() != ""
+}
+
+// lookPath returns the first dlsmi found, or "" if there is none.
+func (d *dlsmiCommand) lookPath() string {
 	paths := []string{
 		"dlsmi",
 		"/usr/bin/dlsmi",
 		"/usr/local/bin/dlsmi",
 	}
 
+	executor := gpu.ExecutorOrDefault(d.executor)
 	for _, p := range paths {
-		if _, err := exec.LookPath(p); err == nil {
-			return true
+		if path, err := executor.LookPath(p); err == nil {
+			return path
 		}
 	}
-	return false
+	return ""
 }
 
 func (d *dlsmiCommand) Vendor() string {
 	return "Denglin"
 }
 
+func (d *dlsmiCommand) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return d.DriverInfoContext(ctx)
+}
+
+func (d *dlsmiCommand) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	output, err := d.query(ctx)
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	info, err := parseDLSMIVersion(output)
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	info.Vendor = d.Vendor()
+	info.Installed = true
+	info.InstallPath = d.lookPath()
+	return info, nil
+}
+
+// parseDLSMIVersion reads the driver version and the firmware version of
+// the first GPU from the dlsmi XML.
+func parseDLSMIVersion(output []byte) (gpu.GPUDriverInfo, error) {
+	var log dlsmiLog
+	if err := xml.Unmarshal(output, &log); err != nil {
+		return gpu.GPUDriverInfo{}, fmt.Errorf("parse dlsmi output: %w", err)
+	}
+	info := gpu.GPUDriverInfo{Version: strings.TrimSpace(log.DriverVersion)}
+	if info.Version == "" {
+		return gpu.GPUDriverInfo{}, fmt.Errorf("dlsmi output has no driver_version")
+	}
+	if len(log.GPUs) > 0 {
+		info.FirmwareVersion = strings.TrimSpace(log.GPUs[0].FirmwareVersion)
+	}
+	return info, nil
+}
+
 type dlsmiLog struct {
-	GPUs []dlsmiGPU `xml:"gpu"`
+	DriverVersion string     `xml:"driver_version"`
+	GPUs          []dlsmiGPU `xml:"gpu"`
 }
 
 type dlsmiGPU struct {
@@ -78,13 +142,30 @@ type dlsmiGPU struct {
 	ProductBrand        string             `xml:"product_brand"`
 	ProductArchitecture string             `xml:"product_architecture"`
 	SerialNumber        string             `xml:"serial_number"`
+	UUID                string             `xml:"uuid"`
 	FirmwareVersion     string             `xml:"fw_version"`
 	BoardPartNumber     string             `xml:"board_part_number"`
+	FanSpeed            string             `xml:"fan_speed"`
 	PCI                 dlsmiPCISection    `xml:"pci"`
 	MemoryUsage         dlsmiMemorySection `xml:"memory_usage"`
 	Utilization         dlsmiUtilization   `xml:"utilization"`
 	Temperature         dlsmiTemperature   `xml:"temperature"`
 	PowerReadings       dlsmiPowerReadings `xml:"power_readings"`
+	PerformanceState    string             `xml:"performance_state"`
+	Clocks              dlsmiClocks        `xml:"clocks"`
+	MaxClocks           dlsmiClocks        `xml:"max_clocks"`
+	ThrottleReasons     dlsmiReasons       `xml:"clocks_throttle_reasons"`
+	ECCMode             dlsmiECCMode       `xml:"ecc_mode"`
+	ECCErrors           dlsmiECCErrors     `xml:"ecc_errors"`
+	RetiredPages        dlsmiRetiredPages  `xml:"retired_pages"`
+	Processes           []dlsmiProcessInfo `xml:"processes>process_info"`
+}
+
+// dlsmiProcessInfo is one entry of <processes>, which is "None" when idle.
+type dlsmiProcessInfo struct {
+	PID        string `xml:"pid"`
+	Name       string `xml:"process_name"`
+	UsedMemory string `xml:"used_memory"`
 }
 
 type dlsmiPCISection struct {
@@ -116,7 +197,78 @@ type dlsmiTemperature struct {
 }
 
 type dlsmiPowerReadings struct {
-	PowerDraw string `xml:"power_draw"`
+	PowerDraw          string `xml:"power_draw"`
+	EnforcedPowerLimit string `xml:"enforced_power_limit"`
+}
+
+// dlsmiClocks are the clock domains of a Denglin GPU: the front end (fe),
+// the compute units (cu), the tensor units (tu), memory and video.
+type dlsmiClocks struct {
+	CU     string `xml:"cu"`
+	Memory string `xml:"memory"`
+}
+
+// dlsmiReasons holds one element per clock throttle reason, such as
+// <hw_thermal_slowdown>Active</hw_thermal_slowdown>.
+type dlsmiReasons struct {
+	Reasons []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+type dlsmiECCMode struct {
+	Current string `xml:"current"`
+	Pending string `xml:"pending"`
+}
+
+type dlsmiECCErrors struct {
+	Volatile  dlsmiECCCounts `xml:"volatile"`
+	Aggregate dlsmiECCCounts `xml:"aggregate"`
+}
+
+type dlsmiECCCounts struct {
+	SingleBit string `xml:"single_bit>total"`
+	DoubleBit string `xml:"double_bit>total"`
+}
+
+type dlsmiRetiredPages struct {
+	SingleBit string `xml:"single_bit_ecc"`
+	DoubleBit string `xml:"double_bit_ecc"`
+	Pending   string `xml:"pending"`
+}
+
+// Processes lists the processes holding memory on each GPU.
+func (d *dlsmiCommand) Processes(ctx context.Context) ([]gpu.GPUProcess, error) {
+	output, err := d.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseDLSMIProcesses(output)
+}
+
+func parseDLSMIProcesses(output []byte) ([]gpu.GPUProcess, error) {
+	var log dlsmiLog
+	if err := xml.Unmarshal(output, &log); err != nil {
+		return nil, fmt.Errorf("parse dlsmi output: %w", err)
+	}
+
+	processes := []gpu.GPUProcess{}
+	for idx, gpuNode := range log.GPUs {
+		for _, p := range gpuNode.Processes {
+			pid, err := strconv.Atoi(strings.TrimSpace(p.PID))
+			if err != nil {
+				return nil, fmt.Errorf("parse dlsmi process pid %q: %w", p.PID, err)
+			}
+			processes = append(processes, gpu.GPUProcess{
+				PID:         pid,
+				Name:        strings.TrimSpace(p.Name),
+				DeviceIndex: idx,
+				MemoryUsed:  sizeMetric(p.UsedMemory),
+			})
+		}
+	}
+	return processes, nil
 }
 
 func parseDLSMIOutput(output []byte) (*gpu.GPUInfoList, error) {
@@ -132,23 +284,48 @@ func parseDLSMIOutput(output []byte) (*gpu.GPUInfoList, error) {
 			DeviceID:                    strings.TrimSpace(gpuNode.PCI.DeviceID),
 			DeviceRev:                   strings.TrimSpace(gpuNode.FirmwareVersion),
 			SerialNumber:                strings.TrimSpace(gpuNode.SerialNumber),
+			UUID:                        strings.TrimSpace(gpuNode.UUID),
 			CardSeries:                  strings.TrimSpace(gpuNode.ProductArchitecture),
 			CardModel:                   strings.TrimSpace(gpuNode.ProductName),
 			CardVendor:                  resolveVendor(gpuNode.ProductBrand),
 			CardSKU:                     strings.TrimSpace(gpuNode.BoardPartNumber),
-			PCIBus:                      resolveBusID(gpuNode),
+			PCIBus:                      gpu.CanonicalPCIAddress(resolveBusID(gpuNode)),
 			VRAMTotalMemory:             convertSizeToBytes(gpuNode.MemoryUsage.Total),
 			VRAMTotalUsedMemory:         convertSizeToBytes(gpuNode.MemoryUsage.Used),
 			GPUUse:                      parseNumericField(gpuNode.Utilization.GPU),
 			TemperatureEdge:             parseNumericField(gpuNode.Temperature.GPUCurrent),
-			TemperatureJunction:         parseNumericField(gpuNode.Temperature.GPUSlowdown),
+			TemperatureJunction:         gpu.NotAvailable, // gpu_slowdown_temp is a threshold, not a reading
 			TemperatureMemory:           parseNumericField(gpuNode.Temperature.MemoryCurrent),
 			AverageGraphicsPackagePower: parseNumericField(gpuNode.PowerReadings.PowerDraw),
+			Metrics: gpu.GPUMetrics{
+				TemperatureEdge:             gpu.ParseFloatMetric(gpuNode.Temperature.GPUCurrent),
+				TemperatureJunction:         gpu.UnsupportedFloat(),
+				TemperatureMemory:           gpu.ParseFloatMetric(gpuNode.Temperature.MemoryCurrent),
+				AverageGraphicsPackagePower: gpu.ParseFloatMetric(gpuNode.PowerReadings.PowerDraw),
+				PowerLimit:                  gpu.ParseFloatMetric(gpuNode.PowerReadings.EnforcedPowerLimit),
+				FanSpeed:                    gpu.ParseFloatMetric(gpuNode.FanSpeed),
+				GPUUse:                      gpu.ParseFloatMetric(gpuNode.Utilization.GPU),
+				VRAMTotalMemory:             sizeMetric(gpuNode.MemoryUsage.Total),
+				VRAMTotalUsedMemory:         sizeMetric(gpuNode.MemoryUsage.Used),
+			},
 		}
 
 		if info.DeviceID == "" {
 			info.DeviceID = strings.TrimSpace(gpuNode.ID)
 		}
+		info.Clocks = &gpu.Clocks{
+			// The compute unit clock is what runs kernels.
+			Graphics:    gpu.ParseFloatMetric(gpuNode.Clocks.CU),
+			Memory:      gpu.ParseFloatMetric(gpuNode.Clocks.Memory),
+			MaxGraphics: gpu.ParseFloatMetric(gpuNode.MaxClocks.CU),
+			MaxMemory:   gpu.ParseFloatMetric(gpuNode.MaxClocks.Memory),
+		}
+		if pstate := strings.TrimSpace(gpuNode.PerformanceState); !gpu.IsNotSupported(pstate) {
+			info.PState = pstate
+		}
+		info.ThrottleReasons = activeReasons(gpuNode.ThrottleReasons)
+		info.ECC = dlECC(gpuNode)
+		info.Health = gpu.CheckHealth(info)
 
 		gpuInfos = append(gpuInfos, info)
 	}
@@ -156,6 +333,56 @@ func parseDLSMIOutput(output []byte) (*gpu.GPUInfoList, error) {
 	return &gpu.GPUInfoList{GPUInfos: gpuInfos}, nil
 }
 
+// dlECC returns the ECC state, or nil when dlsmi prints N/A for all of it,
+// as it does for cards without ECC memory.
+func dlECC(g dlsmiGPU) *gpu.ECCInfo {
+	ecc := &gpu.ECCInfo{
+		Mode:        gpu.ParseMode(g.ECCMode.Current),
+		PendingMode: gpu.ParseMode(g.ECCMode.Pending),
+		Volatile:    dlECCCounts(g.ECCErrors.Volatile),
+	}
+	if aggregate := dlECCCounts(g.ECCErrors.Aggregate); eccReported(aggregate) {
+		ecc.Aggregate = &aggregate
+	}
+	pages := &gpu.RetiredPages{
+		Correctable:   gpu.ParseUintMetric(g.RetiredPages.SingleBit),
+		Uncorrectable: gpu.ParseUintMetric(g.RetiredPages.DoubleBit),
+		Pending:       strings.EqualFold(strings.TrimSpace(g.RetiredPages.Pending), "Yes"),
+	}
+	if pages.Correctable.Available() || pages.Uncorrectable.Available() || pages.Pending {
+		ecc.RetiredPages = pages
+	}
+	if ecc.Mode == "" && ecc.Aggregate == nil && ecc.RetiredPages == nil && !eccReported(ecc.Volatile) {
+		return nil
+	}
+	return ecc
+}
+
+func dlECCCounts(c dlsmiECCCounts) gpu.ECCCounts {
+	return gpu.ECCCounts{
+		Corrected:   gpu.ParseUintMetric(c.SingleBit),
+		Uncorrected: gpu.ParseUintMetric(c.DoubleBit),
+	}
+}
+
+func eccReported(c gpu.ECCCounts) bool {
+	return c.Corrected.Available() || c.Uncorrected.Available()
+}
+
+// activeReasons returns the throttle reasons reported "Active", e.g.
+// "sw_power_cap". The GPU being idle is not throttling and is skipped.
+func activeReasons(r dlsmiReasons) []string {
+	var active []string
+	for _, reason := range r.Reasons {
+		name := reason.XMLName.Local
+		if name == "idle" || strings.TrimSpace(reason.Value) != "Active" {
+			continue
+		}
+		active = append(active, name)
+	}
+	return active
+}
+
 func resolveVendor(productBrand string) string {
 	if trimmed := strings.TrimSpace(productBrand); trimmed != "" && !strings.EqualFold(trimmed, "N/A") {
 		return trimmed
@@ -173,17 +400,17 @@ func resolveBusID(g dlsmiGPU) string {
 func parseNumericField(value string) string {
 	value = strings.TrimSpace(value)
 	if value == "" || strings.EqualFold(value, "N/A") {
-		return "0"
+		return gpu.NotAvailable
 	}
 	fields := strings.Fields(value)
 	if len(fields) == 0 {
-		return "0"
+		return gpu.NotAvailable
 	}
 
 	numStr := strings.Trim(fields[0], "+")
 	num, err := strconv.ParseFloat(numStr, 64)
 	if err != nil {
-		return "0"
+		return gpu.NotAvailable
 	}
 
 	if math.Abs(num-math.Round(num)) < 1e-6 {
@@ -192,19 +419,27 @@ func parseNumericField(value string) string {
 	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", num), "0"), ".")
 }
 
+// sizeMetric is the typed counterpart of convertSizeToBytes.
+func sizeMetric(value string) gpu.UintMetric {
+	if m := gpu.ParseFloatMetric(value); !m.Available() {
+		return gpu.UintMetric{Status: m.Status}
+	}
+	return gpu.ParseUintMetric(convertSizeToBytes(value))
+}
+
 func convertSizeToBytes(value string) string {
 	value = strings.TrimSpace(value)
 	if value == "" || strings.EqualFold(value, "N/A") {
-		return "0"
+		return gpu.NotAvailable
 	}
 	fields := strings.Fields(value)
 	if len(fields) == 0 {
-		return "0"
+		return gpu.NotAvailable
 	}
 
 	num, err := strconv.ParseFloat(fields[0], 64)
 	if err != nil {
-		return "0"
+		return gpu.NotAvailable
 	}
 
 	unit := "B"
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 )
 
 func TestParseDLSMIOutput(t *testing.T) {
@@ -44,12 +48,31 @@ func TestParseDLSMIOutput(t *testing.T) {
 	if first.CardSKU != "GDF00189C02" {
 		t.Fatalf("expected SKU GDF00189C02, got %s", first.CardSKU)
 	}
-	if first.PCIBus != "00000000:1E:00.0" {
-		t.Fatalf("expected PCI bus 00000000:1E:00.0, got %s", first.PCIBus)
+	if first.PCIBus != "0000:1e:00.0" {
+		t.Fatalf("expected PCI bus 0000:1e:00.0, got %s", first.PCIBus)
+	}
+	if first.Health != nil {
+		t.Fatalf("expected no health for a card with ECC N/A and no throttling, got %+v", first.Health)
+	}
+	if first.ECC != nil {
+		t.Fatalf("expected no ECC for a card reporting N/A, got %+v", first.ECC)
+	}
+	wantClocks := gpu.Clocks{Graphics: gpu.Float(1000), Memory: gpu.Float(6336), MaxGraphics: gpu.Float(1000), MaxMemory: gpu.Float(6336)}
+	if first.Clocks == nil || *first.Clocks != wantClocks {
+		t.Fatalf("expected clocks %+v, got %+v", wantClocks, first.Clocks)
+	}
+	if first.PState != "P0" {
+		t.Fatalf("expected performance state P0, got %s", first.PState)
+	}
+	if len(first.ThrottleReasons) != 0 {
+		t.Fatalf("expected no throttle reasons, got %v", first.ThrottleReasons)
 	}
 	if first.SerialNumber != "GDF00189C01DE25300141" {
 		t.Fatalf("expected serial number GDF00189C01DE25300141, got %s", first.SerialNumber)
 	}
+	if first.UUID != "GPU-be21b2e7-f219-1666-711f-d9b646c1f00b" {
+		t.Fatalf("expected UUID GPU-be21b2e7-f219-1666-711f-d9b646c1f00b, got %s", first.UUID)
+	}
 
 	expectedTotal := fmt.Sprintf("%d", 32768*1024*1024)
 	if first.VRAMTotalMemory != expectedTotal {
@@ -69,19 +92,28 @@ func TestParseDLSMIOutput(t *testing.T) {
 	if first.TemperatureEdge != "53" {
 		t.Fatalf("expected edge temperature 53, got %s", first.TemperatureEdge)
 	}
-	if first.TemperatureJunction != "106" {
-		t.Fatalf("expected junction temperature 106, got %s", first.TemperatureJunction)
+	if first.TemperatureJunction != gpu.NotAvailable {
+		t.Fatalf("expected no junction temperature, got %s", first.TemperatureJunction)
+	}
+	if first.Metrics.TemperatureJunction != gpu.UnsupportedFloat() {
+		t.Fatalf("expected unsupported typed junction temperature, got %+v", first.Metrics.TemperatureJunction)
 	}
 	if first.TemperatureMemory != "53" {
 		t.Fatalf("expected memory temperature 53, got %s", first.TemperatureMemory)
 	}
+	if first.Metrics.AverageGraphicsPackagePower != gpu.Float(6.29) {
+		t.Fatalf("expected typed power draw 6.29, got %+v", first.Metrics.AverageGraphicsPackagePower)
+	}
+	if first.Metrics.VRAMTotalMemory != gpu.Uint(32768*1024*1024) {
+		t.Fatalf("expected typed total memory, got %+v", first.Metrics.VRAMTotalMemory)
+	}
 
 	second := infoList.GPUInfos[1]
 	if second.Num != 1 {
 		t.Fatalf("expected second GPU num 1, got %d", second.Num)
 	}
-	if second.PCIBus != "00000000:1F:00.0" {
-		t.Fatalf("expected second PCI bus 00000000:1F:00.0, got %s", second.PCIBus)
+	if second.PCIBus != "0000:1f:00.0" {
+		t.Fatalf("expected second PCI bus 0000:1f:00.0, got %s", second.PCIBus)
 	}
 	if second.CardModel != "KS38 QUAD-2" {
 		t.Fatalf("expected second card model KS38 QUAD-2, got %s", second.CardModel)
@@ -91,10 +123,222 @@ func TestParseDLSMIOutput(t *testing.T) {
 	if last.Num != 7 {
 		t.Fatalf("expected last GPU num 7, got %d", last.Num)
 	}
-	if last.PCIBus != "00000000:30:00.0" {
-		t.Fatalf("expected last PCI bus 00000000:30:00.0, got %s", last.PCIBus)
+	if last.PCIBus != "0000:30:00.0" {
+		t.Fatalf("expected last PCI bus 0000:30:00.0, got %s", last.PCIBus)
 	}
 	if last.CardModel != "KS38 QUAD-1" {
 		t.Fatalf("expected last card model KS38 QUAD-1, got %s", last.CardModel)
 	}
 }
+
+func TestParseDLSMIOutputNotAvailable(t *testing.T) {
+	data := []byte(`<dlsmi_log><gpu id="00000000:1E:00.0">
+		<memory_usage><total>N/A</total></memory_usage>
+		<utilization><gpu>N/A</gpu></utilization>
+		<power_readings><power_draw>N/A</power_draw></power_readings>
+	</gpu></dlsmi_log>`)
+
+	infoList, err := parseDLSMIOutput(data)
+	if err != nil {
+		t.Fatalf("parseDLSMIOutput returned error: %v", err)
+	}
+	info := infoList.GPUInfos[0]
+	if info.AverageGraphicsPackagePower != gpu.NotAvailable || info.VRAMTotalMemory != gpu.NotAvailable || info.TemperatureEdge != gpu.NotAvailable {
+		t.Fatalf("expected N/A, got power %s memory %s temperature %s", info.AverageGraphicsPackagePower, info.VRAMTotalMemory, info.TemperatureEdge)
+	}
+	if info.Metrics.AverageGraphicsPackagePower.Status != gpu.MetricUnsupported {
+		t.Fatalf("expected unsupported power, got %s", info.Metrics.AverageGraphicsPackagePower.Status)
+	}
+	if info.Metrics.VRAMTotalMemory.Status != gpu.MetricUnsupported {
+		t.Fatalf("expected unsupported memory, got %s", info.Metrics.VRAMTotalMemory.Status)
+	}
+	if info.Metrics.TemperatureEdge.Status != gpu.MetricUnavailable {
+		t.Fatalf("expected unavailable temperature, got %s", info.Metrics.TemperatureEdge.Status)
+	}
+}
+
+func TestParseDLSMIHealth(t *testing.T) {
+	data := []byte(`<dlsmi_log><gpu id="00000000:1E:00.0">
+		<clocks_throttle_reasons>
+			<idle>Active</idle>
+			<sw_thermal_slowdown>Active</sw_thermal_slowdown>
+		</clocks_throttle_reasons>
+		<ecc_errors><volatile>
+			<single_bit><total>12</total></single_bit>
+			<double_bit><total>1</total></double_bit>
+		</volatile></ecc_errors>
+		<retired_pages><pending>Yes</pending></retired_pages>
+	</gpu></dlsmi_log>`)
+
+	infoList, err := parseDLSMIOutput(data)
+	if err != nil {
+		t.Fatalf("parseDLSMIOutput returned error: %v", err)
+	}
+	health := infoList.GPUInfos[0].Health
+	if health.Status != gpu.HealthCritical {
+		t.Fatalf("expected critical health, got %s", health.Status)
+	}
+	want := []gpu.HealthReason{gpu.ReasonECCUncorrectable, gpu.ReasonRetiredPagesPending, gpu.ReasonThermalThrottle}
+	if !slices.Equal(health.Reasons, want) {
+		t.Fatalf("expected reasons %v, got %v", want, health.Reasons)
+	}
+	// Being idle is not throttling.
+	if reasons := infoList.GPUInfos[0].ThrottleReasons; !slices.Equal(reasons, []string{"sw_thermal_slowdown"}) {
+		t.Fatalf("expected throttle reasons [sw_thermal_slowdown], got %v", reasons)
+	}
+}
+
+func TestParseDLSMIECC(t *testing.T) {
+	data := []byte(`<dlsmi_log><gpu id="00000000:1E:00.0">
+		<ecc_mode><current>Enabled</current><pending>Disabled</pending></ecc_mode>
+		<ecc_errors>
+			<volatile>
+				<single_bit><device_memory>12</device_memory><total>12</total></single_bit>
+				<double_bit><device_memory>0</device_memory><total>0</total></double_bit>
+			</volatile>
+			<aggregate>
+				<single_bit><device_memory>40</device_memory><total>40</total></single_bit>
+				<double_bit><device_memory>1</device_memory><total>1</total></double_bit>
+			</aggregate>
+		</ecc_errors>
+		<retired_pages>
+			<single_bit_ecc>2</single_bit_ecc>
+			<double_bit_ecc>1</double_bit_ecc>
+			<pending>No</pending>
+		</retired_pages>
+	</gpu></dlsmi_log>`)
+
+	infoList, err := parseDLSMIOutput(data)
+	if err != nil {
+		t.Fatalf("parseDLSMIOutput returned error: %v", err)
+	}
+	ecc := infoList.GPUInfos[0].ECC
+	if ecc == nil {
+		t.Fatal("expected ECC info")
+	}
+	if ecc.Mode != "enabled" || ecc.PendingMode != "disabled" {
+		t.Fatalf("expected mode enabled pending disabled, got %q %q", ecc.Mode, ecc.PendingMode)
+	}
+	if ecc.Volatile != (gpu.ECCCounts{Corrected: gpu.Uint(12), Uncorrected: gpu.Uint(0)}) {
+		t.Fatalf("unexpected volatile counts %+v", ecc.Volatile)
+	}
+	if ecc.Aggregate == nil || *ecc.Aggregate != (gpu.ECCCounts{Corrected: gpu.Uint(40), Uncorrected: gpu.Uint(1)}) {
+		t.Fatalf("unexpected aggregate counts %+v", ecc.Aggregate)
+	}
+	if ecc.RetiredPages == nil || *ecc.RetiredPages != (gpu.RetiredPages{Correctable: gpu.Uint(2), Uncorrectable: gpu.Uint(1)}) {
+		t.Fatalf("unexpected retired pages %+v", ecc.RetiredPages)
+	}
+	// Uncorrected errors before the driver was loaded are not a fault now.
+	if status := infoList.GPUInfos[0].Health.Status; status != gpu.HealthOK {
+		t.Fatalf("expected ok health, got %s", status)
+	}
+}
+
+func TestLoadWithScriptedExecutor(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "dlsmi_output.xml"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	fake := gpu.NewScriptedExecutor().
+		Stdout(data, "/usr/local/bin/dlsmi", "query", "--xml-format")
+	d := &dlsmiCommand{}
+	d.SetExecutor(fake)
+
+	if !d.Available() {
+		t.Fatal("expected dlsmi to be available")
+	}
+	infoList, err := d.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(infoList.GPUInfos) != 8 {
+		t.Fatalf("expected 8 GPUs, got %d", len(infoList.GPUInfos))
+	}
+	if calls := fake.Calls(); len(calls) != 3 {
+		t.Fatalf("expected dlsmi candidates to be tried in order, got %v", calls)
+	}
+}
+
+func TestLoadReportsLastFailure(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Script(gpu.Result{Stdout: []byte("no device"), ExitCode: 2}, "dlsmi", "query", "--xml-format")
+	d := &dlsmiCommand{executor: fake}
+
+	_, err := d.Load()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := err.Error(); !strings.Contains(got, "/usr/local/bin/dlsmi") {
+		t.Errorf("expected the last candidate in the error, got %q", got)
+	}
+}
+
+var _ gpu.ContextGPUInfoLoader = &dlsmiCommand{}
+var _ gpu.ExecutorSetter = &dlsmiCommand{}
+
+func TestParseDLSMIProcesses(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "dlsmi_output.xml"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	processes, err := parseDLSMIProcesses(data)
+	if err != nil {
+		t.Fatalf("parseDLSMIProcesses returned error: %v", err)
+	}
+	if len(processes) != 0 {
+		t.Fatalf("expected no processes for <processes>None</processes>, got %+v", processes)
+	}
+
+	busy := strings.Replace(string(data), "<processes>None</processes>", `<processes>
+			<process_info>
+				<pid>31337</pid>
+				<type>C</type>
+				<process_name>python3</process_name>
+				<used_memory>1024 MiB</used_memory>
+			</process_info>
+		</processes>`, 1)
+	processes, err = parseDLSMIProcesses([]byte(busy))
+	if err != nil {
+		t.Fatalf("parseDLSMIProcesses returned error: %v", err)
+	}
+	want := gpu.GPUProcess{PID: 31337, Name: "python3", DeviceIndex: 0, MemoryUsed: gpu.Uint(1024 * 1024 * 1024)}
+	if len(processes) != 1 || processes[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, processes)
+	}
+}
+
+var _ gpu.ProcessLister = &dlsmiCommand{}
+
+func TestDriverInfoWithScriptedExecutor(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "dlsmi_output.xml"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	fake := gpu.NewScriptedExecutor().
+		Stdout(data, "/usr/bin/dlsmi", "query", "--xml-format")
+	d := &dlsmiCommand{executor: fake}
+
+	info, err := d.DriverInfo()
+	if err != nil {
+		t.Fatalf("DriverInfo returned error: %v", err)
+	}
+	want := gpu.GPUDriverInfo{
+		Vendor:          "Denglin",
+		Version:         "2.2.1-rc3",
+		Installed:       true,
+		InstallPath:     "/usr/bin/dlsmi",
+		FirmwareVersion: "0.3.16",
+	}
+	if info != want {
+		t.Errorf("DriverInfo() = %+v, want %+v", info, want)
+	}
+}
+
+func TestParseDLSMIVersionMissing(t *testing.T) {
+	if _, err := parseDLSMIVersion([]byte("<dlsmi_log><attached_gpus>0</attached_gpus></dlsmi_log>")); err == nil {
+		t.Error("expected an error when driver_version is missing")
+	}
+}
+
+var _ gpu.ContextDriverGetter = &dlsmiCommand{}
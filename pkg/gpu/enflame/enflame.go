@@ -3,10 +3,10 @@ package enflame
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-
-	// "os"
-	"os/exec"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,32 +18,57 @@ func init() {
 	gpu.Register(&enflameSMICommand{})
 }
 
+// moduleVersionFile 为 efsmi 不输出驱动版本时的回退，测试中可替换
+var moduleVersionFile = "/sys/module/enflame/version"
+
 type enflameSMICommand struct {
+	executor gpu.Executor
+}
+
+func (e *enflameSMICommand) SetExecutor(executor gpu.Executor) {
+	e.executor = executor
 }
 
 func (e *enflameSMICommand) Load() (*gpu.GPUInfoList, error) {
-	// 执行efsmi命令获取GPU信息
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return e.LoadContext(ctx)
+}
 
-	cmd := exec.Command("efsmi", "-q", "-d", "TEMP,MEMORY,USAGE,PCIE")
-	output, err := cmd.CombinedOutput()
+func (e *enflameSMICommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	// 执行efsmi命令获取GPU信息
+	output, _, err := e.run(ctx, "-q", "-d", "TEMP,MEMORY,USAGE,PCIE")
 	if err != nil {
-
-		cmd = exec.Command("/usr/bin/efsmi", "-q", "-d", "TEMP,MEMORY,USAGE,PCIE")
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("failed to execute efsmi command: %v", err)
-		} else {
-			return e.parse(output)
-		}
+		return nil, err
 	}
 	return e.parse(output)
 }
 
+// run 执行 efsmi，PATH 中找不到时回退到 /usr/bin/efsmi，并返回实际执行的路径
+func (e *enflameSMICommand) run(ctx context.Context, args ...string) ([]byte, string, error) {
+	output, err := gpu.CombinedOutput(ctx, e.executor, "efsmi", args...)
+	if err == nil {
+		return output, "efsmi", nil
+	}
+	output, err = gpu.CombinedOutput(ctx, e.executor, "/usr/bin/efsmi", args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to execute efsmi command: %v", err)
+	}
+	return output, "/usr/bin/efsmi", nil
+}
+
 func (e *enflameSMICommand) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return e.AvailableContext(ctx)
+}
+
+func (e *enflameSMICommand) AvailableContext(ctx context.Context) bool {
 	// 检查efsmi命令是否可用
-	_, err := exec.LookPath("efsmi")
+	executor := gpu.ExecutorOrDefault(e.executor)
+	_, err := executor.LookPath("efsmi")
 	if err != nil {
-		_, err = exec.LookPath("/usr/bin/efsmi")
+		_, err = executor.LookPath("/usr/bin/efsmi")
 		return err == nil
 	} else {
 		return true
@@ -74,17 +99,27 @@ func (e *enflameSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 
 			deviceID := strings.TrimSpace(line[len("DEV ID"):])
 			currentGPU = &gpu.GPUInfo{
-				Num:                 num,
-				DeviceID:            deviceID,
-				CardVendor:          "Enflame",
-				CardModel:           "Enflame GCU",
-				TemperatureMemory:   "0",
-				TemperatureEdge:     "0",
-				TemperatureJunction: "0",
-				VRAMTotalMemory:     "0",
-				VRAMTotalUsedMemory: "0",
-				GPUUse:              "0",
-				PCIBus:              "",
+				Num:                         num,
+				DeviceID:                    deviceID,
+				CardVendor:                  "Enflame",
+				CardModel:                   "Enflame GCU",
+				TemperatureMemory:           gpu.NotAvailable,
+				TemperatureEdge:             gpu.NotAvailable,
+				TemperatureJunction:         gpu.NotAvailable,
+				VRAMTotalMemory:             gpu.NotAvailable,
+				VRAMTotalUsedMemory:         gpu.NotAvailable,
+				GPUUse:                      gpu.NotAvailable,
+				AverageGraphicsPackagePower: gpu.NotAvailable,
+				PCIBus:                      "",
+				Metrics: gpu.GPUMetrics{
+					// 查询参数中不包含 POWER
+					AverageGraphicsPackagePower: gpu.UnsupportedFloat(),
+					PowerLimit:                  gpu.UnsupportedFloat(),
+					FanSpeed:                    gpu.UnsupportedFloat(),
+					// efsmi 只有一个 GCU 温度
+					TemperatureJunction: gpu.UnsupportedFloat(),
+					TemperatureMemory:   gpu.UnsupportedFloat(),
+				},
 			}
 			currentSection = ""
 			num++
@@ -135,6 +170,7 @@ func (e *enflameSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 					if size, err := strconv.ParseFloat(parts[0], 64); err == nil {
 						size = size * 1024 * 1024
 						currentGPU.VRAMTotalMemory = fmt.Sprintf("%.0f", size)
+						currentGPU.Metrics.VRAMTotalMemory = gpu.Uint(uint64(size))
 					}
 				}
 			case strings.Contains(line, "Mem Usage") || strings.Contains(line, "Used Size"):
@@ -143,6 +179,7 @@ func (e *enflameSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 					if size, err := strconv.ParseFloat(parts[0], 64); err == nil {
 						size = size * 1024 * 1024
 						currentGPU.VRAMTotalUsedMemory = fmt.Sprintf("%.0f", size)
+						currentGPU.Metrics.VRAMTotalUsedMemory = gpu.Uint(uint64(size))
 					}
 				}
 			}
@@ -153,6 +190,7 @@ func (e *enflameSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 					currentGPU.TemperatureMemory = parts[0]
 					currentGPU.TemperatureEdge = parts[0]
 					currentGPU.TemperatureJunction = parts[0]
+					currentGPU.Metrics.TemperatureEdge = gpu.ParseFloatMetric(parts[0])
 				}
 			}
 		case "usage":
@@ -160,6 +198,7 @@ func (e *enflameSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 				parts := strings.Split(value, " ")
 				if len(parts) >= 1 {
 					currentGPU.GPUUse = parts[0]
+					currentGPU.Metrics.GPUUse = gpu.ParseFloatMetric(parts[0])
 				}
 			}
 		case "pcie":
@@ -181,9 +220,80 @@ func (e *enflameSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 		result.GPUInfos = append(result.GPUInfos, *currentGPU)
 	}
 
+	// PCI 地址由 Domain/Bus/Dev/Func 四行拼接而成，统一为 sysfs 格式
+	for i := range result.GPUInfos {
+		result.GPUInfos[i].PCIBus = gpu.CanonicalPCIAddress(result.GPUInfos[i].PCIBus)
+	}
+
 	return result, nil
 }
 
 func (e *enflameSMICommand) Vendor() string {
 	return "Enflame"
 }
+
+func (e *enflameSMICommand) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return e.DriverInfoContext(ctx)
+}
+
+func (e *enflameSMICommand) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	var info gpu.GPUDriverInfo
+	output, path, smiErr := e.run(ctx, "-q")
+	if smiErr == nil {
+		info = parseVersion(output)
+		if resolved, err := gpu.ExecutorOrDefault(e.executor).LookPath(path); err == nil {
+			info.InstallPath = resolved
+		}
+	}
+	// 旧版本 efsmi 不输出驱动版本，回退到内核模块版本
+	if info.Version == "" {
+		data, err := os.ReadFile(moduleVersionFile)
+		if err != nil {
+			return gpu.GPUDriverInfo{}, errors.Join(smiErr, err)
+		}
+		info.Version = strings.TrimSpace(string(data))
+	}
+	info.Vendor = e.Vendor()
+	info.Installed = true
+	return info, nil
+}
+
+// parseVersion 解析 efsmi -q 输出中的版本信息，多卡时取第一张卡
+// 格式:
+//
+//	EFSMI Ver               : 1.5.0
+//	DEV ID 0
+//	    Device Info
+//	        Driver Ver              : 1.5.0.12
+//	        Firmware Ver            : 33.5.3
+func parseVersion(output []byte) gpu.GPUDriverInfo {
+	info := gpu.GPUDriverInfo{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if value == "" || value == gpu.NotAvailable {
+			continue
+		}
+
+		switch key {
+		case "EFSMI Ver", "EFSMI Version":
+			info.ClientVersion = value
+		case "Driver Ver", "Driver Version":
+			if info.Version == "" {
+				info.Version = value
+			}
+		case "Firmware Ver", "Firmware Version":
+			if info.FirmwareVersion == "" {
+				info.FirmwareVersion = value
+			}
+		}
+	}
+	return info
+}
@@ -81,10 +81,10 @@ func TestEnflameParse(t *testing.T) {
 				},
 				{
 					Num: 1, DeviceID: "1", CardVendor: "Enflame", CardModel: "Enflame GCU",
-					TemperatureMemory: "0", TemperatureEdge: "0", TemperatureJunction: "0",
-					VRAMTotalMemory:     "0",
-					VRAMTotalUsedMemory: "0",
-					GPUUse:              "0",
+					TemperatureMemory: "N/A", TemperatureEdge: "N/A", TemperatureJunction: "N/A",
+					VRAMTotalMemory:     "N/A",
+					VRAMTotalUsedMemory: "N/A",
+					GPUUse:              "N/A",
 					PCIBus:              "0000:0f:00.0",
 				},
 			},
@@ -136,7 +136,108 @@ func TestEnflameParse(t *testing.T) {
 				if got.PCIBus != exp.PCIBus {
 					t.Errorf("GPU %d PCIBus: expected %s, got %s", i, exp.PCIBus, got.PCIBus)
 				}
+				if got.AverageGraphicsPackagePower != gpu.NotAvailable {
+					t.Errorf("GPU %d AverageGraphicsPackagePower: expected N/A, got %s", i, got.AverageGraphicsPackagePower)
+				}
+				if got.Metrics.AverageGraphicsPackagePower.Status != gpu.MetricUnsupported {
+					t.Errorf("GPU %d power status: expected unsupported, got %s", i, got.Metrics.AverageGraphicsPackagePower.Status)
+				}
+				if got.Metrics.TemperatureEdge.Available() != (exp.TemperatureEdge != gpu.NotAvailable) {
+					t.Errorf("GPU %d typed temperature: got %+v for %s", i, got.Metrics.TemperatureEdge, exp.TemperatureEdge)
+				}
+				if got.Metrics.TemperatureJunction.Status != gpu.MetricUnsupported || got.Metrics.TemperatureMemory.Status != gpu.MetricUnsupported {
+					t.Errorf("GPU %d junction/memory temperature: expected unsupported, got %+v %+v", i, got.Metrics.TemperatureJunction, got.Metrics.TemperatureMemory)
+				}
 			}
 		})
 	}
 }
+
+func TestEnflameLoadWithScriptedExecutor(t *testing.T) {
+	// efsmi 不在 PATH 中时回退到 /usr/bin/efsmi
+	fake := gpu.NewScriptedExecutor().
+		Stdout(efs17, "/usr/bin/efsmi", "-q", "-d", "TEMP,MEMORY,USAGE,PCIE")
+	e := &enflameSMICommand{}
+	e.SetExecutor(fake)
+
+	if !e.Available() {
+		t.Fatal("expected efsmi to be available via /usr/bin/efsmi")
+	}
+	result, err := e.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(result.GPUInfos) != 2 {
+		t.Fatalf("expected 2 GPUs, got %d", len(result.GPUInfos))
+	}
+	if result.GPUInfos[1].PCIBus != "0000:0f:00.0" {
+		t.Errorf("GPU 1 PCIBus: expected 0000:0f:00.0, got %s", result.GPUInfos[1].PCIBus)
+	}
+	if calls := fake.Calls(); len(calls) != 2 || calls[0].Path != "efsmi" {
+		t.Errorf("expected efsmi then /usr/bin/efsmi, got %v", calls)
+	}
+}
+
+func TestEnflameLoadWithoutEfsmi(t *testing.T) {
+	e := &enflameSMICommand{executor: gpu.NewScriptedExecutor()}
+	if e.Available() {
+		t.Error("expected efsmi to be unavailable")
+	}
+	if _, err := e.Load(); err == nil {
+		t.Error("expected an error when efsmi is missing")
+	}
+}
+
+var _ gpu.ContextGPUInfoLoader = &enflameSMICommand{}
+var _ gpu.ExecutorSetter = &enflameSMICommand{}
+
+//go:embed testdata/efs-version.txt
+var efsVersion []byte
+
+func TestEnflameDriverInfo(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Path("efsmi", "/usr/local/bin/efsmi").
+		Stdout(efsVersion, "efsmi", "-q")
+	e := &enflameSMICommand{executor: fake}
+
+	info, err := e.DriverInfo()
+	if err != nil {
+		t.Fatalf("DriverInfo failed: %v", err)
+	}
+	want := gpu.GPUDriverInfo{
+		Vendor:          "Enflame",
+		Version:         "1.5.0.12",
+		Installed:       true,
+		InstallPath:     "/usr/local/bin/efsmi",
+		ClientVersion:   "1.5.0",
+		FirmwareVersion: "33.5.3",
+	}
+	if info != want {
+		t.Errorf("DriverInfo() = %+v, want %+v", info, want)
+	}
+}
+
+func TestEnflameDriverInfoFallsBackToModuleVersion(t *testing.T) {
+	old := moduleVersionFile
+	moduleVersionFile = "testdata/sys/module/enflame/version"
+	defer func() { moduleVersionFile = old }()
+
+	// efs.txt 来自不输出版本信息的 efsmi
+	fake := gpu.NewScriptedExecutor().Stdout(efs, "/usr/bin/efsmi", "-q")
+	e := &enflameSMICommand{executor: fake}
+
+	info, err := e.DriverInfo()
+	if err != nil {
+		t.Fatalf("DriverInfo failed: %v", err)
+	}
+	if info.Version != "1.4.2.3" || info.ClientVersion != "" || info.InstallPath != "/usr/bin/efsmi" {
+		t.Errorf("unexpected driver info: %+v", info)
+	}
+
+	moduleVersionFile = "testdata/sys/module/missing/version"
+	if _, err := e.DriverInfo(); err == nil {
+		t.Error("expected an error without efsmi output or module version")
+	}
+}
+
+var _ gpu.ContextDriverGetter = &enflameSMICommand{}
@@ -0,0 +1,149 @@
+package gpu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Command describes a single vendor tool invocation.
+type Command struct {
+	Path string   // program name or path, resolved like exec.Command does
+	Args []string // arguments, without the program itself
+	Env  []string // environment in "KEY=value" form; nil inherits the current one
+}
+
+// Argv returns the program followed by its arguments.
+func (c Command) Argv() []string {
+	return append([]string{c.Path}, c.Args...)
+}
+
+func (c Command) String() string {
+	return strings.Join(c.Argv(), " ")
+}
+
+// Result is the outcome of a Command that was started.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// Combined returns standard output followed by standard error, the closest
+// equivalent of exec.Cmd.CombinedOutput an Executor can offer.
+func (r *Result) Combined() []byte {
+	if r == nil {
+		return nil
+	}
+	return append(append([]byte{}, r.Stdout...), r.Stderr...)
+}
+
+// ExitError is returned by an Executor when a command exits non-zero.
+type ExitError struct {
+	Command  Command
+	ExitCode int
+	Stderr   []byte
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("%s: exit status %d", e.Command.Path, e.ExitCode)
+	if stderr := strings.TrimSpace(string(e.Stderr)); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// Executor runs vendor tools on behalf of loaders. Run returns a non-nil
+// Result whenever the command was started, together with an *ExitError if
+// it exited non-zero. LookPath follows the semantics of exec.LookPath.
+type Executor interface {
+	Run(ctx context.Context, cmd Command) (*Result, error)
+	LookPath(file string) (string, error)
+}
+
+// ExecutorSetter is implemented by loaders that accept an injected Executor.
+type ExecutorSetter interface {
+	SetExecutor(e Executor)
+}
+
+// OSExecutor runs commands with os/exec.
+type OSExecutor struct{}
+
+func (OSExecutor) Run(ctx context.Context, cmd Command) (*Result, error) {
+	c := exec.CommandContext(ctx, cmd.Path, cmd.Args...)
+	c.Env = cmd.Env
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+
+	err := c.Run()
+	result := &Result{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return result, nil
+	case ctx.Err() != nil:
+		return result, fmt.Errorf("%s: %w", cmd.Path, ctx.Err())
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		return result, &ExitError{Command: cmd, ExitCode: result.ExitCode, Stderr: result.Stderr}
+	default:
+		return nil, err
+	}
+}
+
+func (OSExecutor) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+var (
+	defaultExecutorMu sync.RWMutex
+	defaultExecutor   Executor = OSExecutor{}
+)
+
+// DefaultExecutor returns the Executor used by loaders that have none
+// injected.
+func DefaultExecutor() Executor {
+	defaultExecutorMu.RLock()
+	defer defaultExecutorMu.RUnlock()
+	return defaultExecutor
+}
+
+// SetDefaultExecutor replaces the Executor used by loaders that have none
+// injected. Passing nil restores OSExecutor.
+func SetDefaultExecutor(e Executor) {
+	if e == nil {
+		e = OSExecutor{}
+	}
+	defaultExecutorMu.Lock()
+	defer defaultExecutorMu.Unlock()
+	defaultExecutor = e
+}
+
+// ExecutorOrDefault returns e, or DefaultExecutor if e is nil.
+func ExecutorOrDefault(e Executor) Executor {
+	if e != nil {
+		return e
+	}
+	return DefaultExecutor()
+}
+
+// Output runs name with args through e and returns its standard output.
+func Output(ctx context.Context, e Executor, name string, args ...string) ([]byte, error) {
+	result, err := ExecutorOrDefault(e).Run(ctx, Command{Path: name, Args: args})
+	if result == nil {
+		return nil, err
+	}
+	return result.Stdout, err
+}
+
+// CombinedOutput runs name with args through e and returns its standard
+// output followed by its standard error.
+func CombinedOutput(ctx context.Context, e Executor, name string, args ...string) ([]byte, error) {
+	result, err := ExecutorOrDefault(e).Run(ctx, Command{Path: name, Args: args})
+	return result.Combined(), err
+}
@@ -0,0 +1,101 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOSExecutorRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	ctx := context.Background()
+
+	result, err := OSExecutor{}.Run(ctx, Command{Path: "sh", Args: []string{"-c", "echo out; echo err >&2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "out\n", string(result.Stdout))
+	assert.Equal(t, "err\n", string(result.Stderr))
+	assert.Equal(t, "out\nerr\n", string(result.Combined()))
+
+	result, err = OSExecutor{}.Run(ctx, Command{Path: "sh", Args: []string{"-c", "echo broken >&2; exit 3"}})
+	var exitErr *ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, "sh: exit status 3: broken", err.Error())
+
+	result, err = OSExecutor{}.Run(ctx, Command{Path: "sh", Args: []string{"-c", `printf %s "$GPU_TOOLS_TEST"`}, Env: []string{"GPU_TOOLS_TEST=set"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "set", string(result.Stdout))
+}
+
+func TestOSExecutorMissingProgram(t *testing.T) {
+	result, err := OSExecutor{}.Run(context.Background(), Command{Path: "/nonexistent/gpu-tools-smi"})
+	assert.Nil(t, result)
+	assert.Error(t, err)
+}
+
+func TestScriptedExecutor(t *testing.T) {
+	fake := NewScriptedExecutor().
+		Stdout([]byte("ok"), "smi", "-q").
+		Script(Result{Stderr: []byte("no device"), ExitCode: 2}, "smi", "-x").
+		Fail(errors.New("permission denied"), "locked-smi").
+		Path("other-smi", "/opt/bin/other-smi")
+	ctx := context.Background()
+
+	out, err := Output(ctx, fake, "smi", "-q")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(out))
+
+	out, err = CombinedOutput(ctx, fake, "smi", "-x")
+	assert.Equal(t, "no device", string(out))
+	var exitErr *ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 2, exitErr.ExitCode)
+
+	_, err = Output(ctx, fake, "locked-smi")
+	assert.EqualError(t, err, "permission denied")
+
+	_, err = Output(ctx, fake, "smi", "--unscripted")
+	assert.True(t, errors.Is(err, exec.ErrNotFound))
+
+	path, err := fake.LookPath("smi")
+	assert.NoError(t, err)
+	assert.Equal(t, "smi", path)
+	path, err = fake.LookPath("other-smi")
+	assert.NoError(t, err)
+	assert.Equal(t, "/opt/bin/other-smi", path)
+	_, err = fake.LookPath("missing-smi")
+	assert.True(t, errors.Is(err, exec.ErrNotFound))
+
+	assert.Equal(t, []string{"smi", "-q"}, fake.Calls()[0].Argv())
+	assert.Len(t, fake.Calls(), 4)
+}
+
+func TestScriptedExecutorCancelled(t *testing.T) {
+	fake := NewScriptedExecutor().Stdout([]byte("ok"), "smi")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Output(ctx, fake, "smi")
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, fake.Calls())
+}
+
+func TestSetDefaultExecutor(t *testing.T) {
+	fake := NewScriptedExecutor()
+	SetDefaultExecutor(fake)
+	defer SetDefaultExecutor(nil)
+
+	assert.Same(t, fake, DefaultExecutor())
+	assert.Same(t, fake, ExecutorOrDefault(nil))
+	other := NewScriptedExecutor()
+	assert.Same(t, other, ExecutorOrDefault(other))
+
+	SetDefaultExecutor(nil)
+	assert.Equal(t, OSExecutor{}, DefaultExecutor())
+}
@@ -2,12 +2,13 @@ package gpu_test
 
 import (
 	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu/huawei"
 	"github.com/hawkli-1994/gpu_tools/pkg/gpu/nvidia"
 )
 
-var _ DriverGetter = nvidia.New()
-
-type DriverGetter interface {
-	gpu.GPUInfoLoader
-	DriverInfo() (gpu.GPUDriverInfo, error)
-}
+var (
+	_ gpu.ContextGPUInfoLoader = nvidia.New()
+	_ gpu.ContextGPUInfoLoader = huawei.New()
+	_ gpu.ContextDriverGetter  = nvidia.New()
+	_ gpu.ContextDriverGetter  = huawei.New()
+)
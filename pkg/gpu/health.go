@@ -0,0 +1,112 @@
+package gpu
+
+import (
+	"slices"
+	"strings"
+)
+
+// HealthStatus is the overall health of a device.
+type HealthStatus string
+
+const (
+	HealthOK HealthStatus = "ok"
+	// HealthWarning devices still work but need attention, e.g. a reset to
+	// retire pages.
+	HealthWarning HealthStatus = "warning"
+	// HealthCritical devices should be drained.
+	HealthCritical HealthStatus = "critical"
+)
+
+func (s HealthStatus) severity() int {
+	switch s {
+	case HealthWarning:
+		return 1
+	case HealthCritical:
+		return 2
+	}
+	return 0
+}
+
+// HealthReason is why a device is not healthy.
+type HealthReason string
+
+const (
+	ReasonECCUncorrectable    HealthReason = "ecc_uncorrectable"
+	ReasonThermalThrottle     HealthReason = "thermal_throttle"
+	ReasonRetiredPagesPending HealthReason = "retired_pages_pending"
+	ReasonDeviceLost          HealthReason = "device_lost"
+	ReasonResetRequired       HealthReason = "reset_required"
+	ReasonVendorWarning       HealthReason = "vendor_warning" // the vendor tool's own verdict
+	ReasonVendorAlarm         HealthReason = "vendor_alarm"   // the vendor tool's own verdict
+)
+
+// Status returns the health status a device with only this reason has.
+func (r HealthReason) Status() HealthStatus {
+	switch r {
+	case ReasonThermalThrottle, ReasonRetiredPagesPending, ReasonVendorWarning:
+		return HealthWarning
+	}
+	return HealthCritical
+}
+
+// Health is the health of a device and the reasons for it.
+type Health struct {
+	Status  HealthStatus   `json:"status"`
+	Reasons []HealthReason `json:"reasons,omitempty"`
+	// VendorStatus is the health the vendor tool printed, e.g. "Alarm" from
+	// npu-smi. Empty for tools without a health field of their own.
+	VendorStatus string `json:"vendor_status,omitempty"`
+}
+
+// NewHealth returns the health of a device showing reasons, which is
+// HealthOK when there are none.
+func NewHealth(reasons ...HealthReason) *Health {
+	h := &Health{Status: HealthOK}
+	for _, r := range reasons {
+		h.Add(r)
+	}
+	return h
+}
+
+// Add records reason, raising Status to the reason's status.
+func (h *Health) Add(reason HealthReason) {
+	if slices.Contains(h.Reasons, reason) {
+		return
+	}
+	h.Reasons = append(h.Reasons, reason)
+	if reason.Status().severity() > h.Status.severity() {
+		h.Status = reason.Status()
+	}
+}
+
+// CheckHealth returns the health of info as far as its common fields tell:
+// uncorrected errors in ECC.Volatile, pages waiting in ECC.RetiredPages and
+// thermal ThrottleReasons. Loaders add the reasons only their vendor tool
+// reports, such as a lost device.
+//
+// CheckHealth returns nil when info has none of these, e.g. no ECC or only
+// N/A counts and no throttle reasons, rather than calling the device ok.
+func CheckHealth(info GPUInfo) *Health {
+	ecc := info.ECC
+	if ecc != nil && !ecc.Volatile.Uncorrected.Available() && ecc.RetiredPages == nil {
+		ecc = nil
+	}
+	if ecc == nil && len(info.ThrottleReasons) == 0 {
+		return nil
+	}
+	h := NewHealth()
+	if ecc != nil {
+		if m := ecc.Volatile.Uncorrected; m.Available() && m.Value > 0 {
+			h.Add(ReasonECCUncorrectable)
+		}
+		if ecc.RetiredPages != nil && ecc.RetiredPages.Pending {
+			h.Add(ReasonRetiredPagesPending)
+		}
+	}
+	for _, reason := range info.ThrottleReasons {
+		if strings.Contains(reason, "thermal") {
+			h.Add(ReasonThermalThrottle)
+		}
+	}
+	return h
+}
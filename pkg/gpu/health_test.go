@@ -0,0 +1,58 @@
+package gpu
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHealth(t *testing.T) {
+	assert.Equal(t, &Health{Status: HealthOK}, NewHealth())
+
+	h := NewHealth(ReasonThermalThrottle)
+	assert.Equal(t, HealthWarning, h.Status)
+
+	h.Add(ReasonDeviceLost)
+	h.Add(ReasonRetiredPagesPending)
+	h.Add(ReasonThermalThrottle)
+	assert.Equal(t, HealthCritical, h.Status, "a warning never lowers the status")
+	assert.Equal(t, []HealthReason{ReasonThermalThrottle, ReasonDeviceLost, ReasonRetiredPagesPending}, h.Reasons)
+}
+
+func TestCheckHealth(t *testing.T) {
+	assert.Equal(t, NewHealth(), CheckHealth(GPUInfo{ECC: &ECCInfo{Volatile: ECCCounts{Uncorrected: Uint(0)}}}))
+
+	info := GPUInfo{
+		ECC:             &ECCInfo{Volatile: ECCCounts{Corrected: Uint(40), Uncorrected: Uint(0)}},
+		ThrottleReasons: []string{"sw_power_cap"},
+	}
+	assert.Equal(t, NewHealth(), CheckHealth(info))
+
+	info.ECC.Volatile.Uncorrected = Uint(2)
+	info.ThrottleReasons = append(info.ThrottleReasons, "hw_thermal_slowdown")
+	assert.Equal(t, NewHealth(ReasonECCUncorrectable, ReasonThermalThrottle), CheckHealth(info))
+
+	info = GPUInfo{ECC: &ECCInfo{RetiredPages: &RetiredPages{Uncorrectable: Uint(1), Pending: true}}}
+	assert.Equal(t, NewHealth(ReasonRetiredPagesPending), CheckHealth(info))
+}
+
+func TestCheckHealthWithoutInputs(t *testing.T) {
+	assert.Nil(t, CheckHealth(GPUInfo{}))
+
+	// ECC reported, but every count N/A.
+	info := GPUInfo{ECC: &ECCInfo{
+		Mode:     "enabled",
+		Volatile: ECCCounts{Corrected: UnsupportedUint(), Uncorrected: UnsupportedUint()},
+	}}
+	assert.Nil(t, CheckHealth(info))
+
+	info.ThrottleReasons = []string{"sw_power_cap"}
+	assert.Equal(t, NewHealth(), CheckHealth(info))
+}
+
+func TestHealthJSON(t *testing.T) {
+	data, err := json.Marshal(NewHealth(ReasonResetRequired))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status": "critical", "reasons": ["reset_required"]}`, string(data))
+}
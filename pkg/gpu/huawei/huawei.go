@@ -3,11 +3,12 @@ package huawei
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 )
@@ -16,67 +17,439 @@ func init() {
 	gpu.Register(&npuSMICommand{})
 }
 
-func New() *npuSMICommand {
-	return &npuSMICommand{}
+// DefaultConcurrency is the number of npu-smi sub-queries Load runs at the
+// same time unless WithConcurrency says otherwise.
+const DefaultConcurrency = 8
+
+// Option configures the loader returned by New.
+type Option func(*npuSMICommand)
+
+// WithConcurrency sets how many npu-smi sub-queries Load runs at the same
+// time. Values below one use DefaultConcurrency.
+func WithConcurrency(n int) Option {
+	return func(h *npuSMICommand) {
+		h.concurrency = n
+	}
+}
+
+// WithDetailedQueries makes Load run all five "npu-smi info -t" queries per
+// NPU instead of reading the summary table of "npu-smi info".
+func WithDetailedQueries() Option {
+	return func(h *npuSMICommand) {
+		h.detailed = true
+	}
+}
+
+func New(opts ...Option) *npuSMICommand {
+	h := &npuSMICommand{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 type npuSMICommand struct {
-	smiPath string
+	// mu guards smiPath, which the registered loader shares between the
+	// DetectAll workers and callers of Processes and Topology.
+	mu          sync.Mutex
+	smiPath     string
+	executor    gpu.Executor
+	concurrency int
+	detailed    bool
+}
+
+// SubQueryError reports a failed "npu-smi info -t <Query> -i <NPUID>" call.
+type SubQueryError struct {
+	NPUID string
+	Query string
+	Err   error
+}
+
+func (e *SubQueryError) Error() string {
+	return fmt.Sprintf("npu-smi info -t %s -i %s: %v", e.Query, e.NPUID, e.Err)
+}
+
+func (e *SubQueryError) Unwrap() error {
+	return e.Err
+}
+
+func (h *npuSMICommand) SetExecutor(e gpu.Executor) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.executor = e
+	// The path was found by the previous executor.
+	h.smiPath = ""
 }
 
 func (h *npuSMICommand) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return h.AvailableContext(ctx)
+}
+
+func (h *npuSMICommand) AvailableContext(ctx context.Context) bool {
+	return h.lookPath() != ""
+}
+
+// lookPath returns how to run npu-smi, "npu-smi" on PATH or the path of
+// its usual install location, or "" if it is in neither. The result is
+// kept until SetExecutor.
+func (h *npuSMICommand) lookPath() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	if h.smiPath != "" {
-		return true
-	}
-	if _, err := exec.LookPath("npu-smi"); err == nil {
-		h.smiPath = "npu-smi"
-		return true
+		return h.smiPath
 	}
-	if _, err := exec.LookPath("/usr/local/sbin/npu-smi"); err == nil {
-		h.smiPath = "/usr/local/sbin/npu-smi"
-		return true
+	for _, path := range []string{"npu-smi", "/usr/local/sbin/npu-smi"} {
+		if _, err := gpu.ExecutorOrDefault(h.executor).LookPath(path); err == nil {
+			h.smiPath = path
+			break
+		}
 	}
-	return false
+	return h.smiPath
 }
 
 func (h *npuSMICommand) Vendor() string {
 	return "Huawei"
 }
 
+func (h *npuSMICommand) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return h.DriverInfoContext(ctx)
+}
+
+// DriverInfoContext reads the driver and npu-smi versions from the header of
+// "npu-smi info", and the firmware version from the board of the first NPU.
+func (h *npuSMICommand) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	output, err := h.info(ctx)
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	info, err := parseVersionHeader(output)
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	info.Vendor = h.Vendor()
+	info.Installed = true
+	if path, err := gpu.ExecutorOrDefault(h.executor).LookPath(h.lookPath()); err == nil {
+		info.InstallPath = path
+	}
+
+	// The firmware version is only in the board query; a failure there
+	// leaves it empty rather than failing the driver lookup.
+	if ids := parseInfoTable(output).npuIDs(); len(ids) > 0 {
+		if board, err := h.getBoardInfo(ctx, ids[0]); err == nil {
+			info.FirmwareVersion = board["Firmware Version"]
+		}
+	}
+	return info, nil
+}
+
 func (h *npuSMICommand) Load() (*gpu.GPUInfoList, error) {
-	if h.smiPath == "" {
-		h.Available()
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return h.LoadContext(ctx)
+}
+
+func (h *npuSMICommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	output, err := h.info(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if h.smiPath == "" {
+
+	// The summary table already has most readings; only fall back to the
+	// five detailed queries when asked to, or when the table is not understood.
+	if !h.detailed {
+		if table := parseInfoTable(output); len(table.Chips) > 0 {
+			return h.loadFromTable(ctx, table)
+		}
+	}
+	return h.loadDetailed(ctx, output)
+}
+
+// info runs "npu-smi info", locating npu-smi first if needed.
+func (h *npuSMICommand) info(ctx context.Context) ([]byte, error) {
+	smiPath := h.lookPath()
+	if smiPath == "" {
 		return nil, fmt.Errorf("npu-smi command not found")
 	}
-	cmd := exec.Command(h.smiPath, "info")
-	output, err := cmd.CombinedOutput()
+	output, err := gpu.CombinedOutput(ctx, h.executor, smiPath, "info")
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute npu-smi info: %v", err)
 	}
+	return output, nil
+}
+
+// Processes lists the processes from the process section of "npu-smi info".
+// DeviceIndex follows the chip order of the summary table, as Num does;
+// processes on chips missing from the table are left out.
+func (h *npuSMICommand) Processes(ctx context.Context) ([]gpu.GPUProcess, error) {
+	output, err := h.info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tableProcesses(parseInfoTable(output)), nil
+}
 
+// Topology reads the links between the NPUs from "npu-smi info -t topo",
+// whose NPU numbers are the logical device IDs and so follow the chip order
+// of the summary table, as Num does.
+func (h *npuSMICommand) Topology(ctx context.Context) (*gpu.Topology, error) {
+	smiPath := h.lookPath()
+	if smiPath == "" {
+		return nil, fmt.Errorf("npu-smi command not found")
+	}
+	output, err := gpu.CombinedOutput(ctx, h.executor, smiPath, "info", "-t", "topo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute npu-smi info -t topo: %v", err)
+	}
+	return gpu.ParseTopologyMatrix(output, "NPU")
+}
+
+func tableProcesses(table *infoTable) []gpu.GPUProcess {
+	index := make(map[[2]string]int, len(table.Chips))
+	for i, chip := range table.Chips {
+		index[[2]string{chip.NPUID, chip.ChipID}] = i
+	}
+	processes := []gpu.GPUProcess{}
+	for _, p := range table.Processes {
+		deviceIndex, ok := index[[2]string{p.NPUID, p.ChipID}]
+		if !ok {
+			// DeviceIndex must be a Num; a chip missing from the table has none.
+			continue
+		}
+		processes = append(processes, gpu.GPUProcess{
+			PID:         p.PID,
+			Name:        p.Name,
+			DeviceIndex: deviceIndex,
+			MemoryUsed:  p.Memory,
+		})
+	}
+	return processes
+}
+
+func (h *npuSMICommand) loadDetailed(ctx context.Context, output []byte) (*gpu.GPUInfoList, error) {
 	npuIDs, err := extractNPUIDs(output)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract NPU IDs: %v", err)
 	}
 
+	// Each NPU needs five sub-queries; run them all through a bounded pool.
+	type npuResults struct {
+		board, power            map[string]string
+		common, usages, product map[string]map[string]string
+	}
+	results := make([]npuResults, len(npuIDs))
+	var tasks []func() error
+	for i, npuID := range npuIDs {
+		r := &results[i]
+		tasks = append(tasks,
+			func() (err error) { r.board, err = h.getBoardInfo(ctx, npuID); return },
+			func() (err error) { r.common, err = h.getCommonInfo(ctx, npuID); return },
+			func() (err error) { r.usages, err = h.getUsagesInfo(ctx, npuID); return },
+			func() (err error) { r.product, err = h.getProductInfo(ctx, npuID); return },
+			func() (err error) { r.power, err = h.getPowerInfo(ctx, npuID); return },
+		)
+	}
+	var failed []error
+	for _, err := range runBounded(h.concurrencyOrDefault(), tasks) {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
 	result := &gpu.GPUInfoList{GPUInfos: []gpu.GPUInfo{}}
 	globalNum := 0
+	for i, npuID := range npuIDs {
+		r := results[i]
+		infos, nextNum := buildGPUInfoList(npuID, r.board, r.common, r.usages, r.product, r.power, globalNum)
+		result.GPUInfos = append(result.GPUInfos, infos...)
+		globalNum = nextNum
+	}
+	// The detailed queries have no health; take it from the summary table
+	// when that lists the same chips.
+	if table := parseInfoTable(output); len(table.Chips) == len(result.GPUInfos) {
+		for i, chip := range table.Chips {
+			result.GPUInfos[i].Health = npuHealth(chip.Health)
+		}
+	}
+
+	return finishLoad(result, failed)
+}
+
+// loadFromTable builds the GPU list from the summary table, querying each
+// NPU only for the serial number and product type the table lacks, plus the
+// power reading when the table prints NA for it.
+func (h *npuSMICommand) loadFromTable(ctx context.Context, table *infoTable) (*gpu.GPUInfoList, error) {
+	npuIDs := table.npuIDs()
+	needsPower := make(map[string]bool)
+	for _, chip := range table.Chips {
+		if !gpu.ParseFloatMetric(chip.Power).Available() {
+			needsPower[chip.NPUID] = true
+		}
+	}
 
+	type npuResults struct {
+		board, power map[string]string
+		product      map[string]map[string]string
+	}
+	results := make(map[string]*npuResults, len(npuIDs))
+	var tasks []func() error
 	for _, npuID := range npuIDs {
-		boardInfo, _ := h.getBoardInfo(npuID)
-		commonInfo, _ := h.getCommonInfo(npuID)
-		usagesInfo, _ := h.getUsagesInfo(npuID)
-		productInfo, _ := h.getProductInfo(npuID)
-		powerInfo, _ := h.getPowerInfo(npuID)
+		r := &npuResults{}
+		results[npuID] = r
+		tasks = append(tasks,
+			func() (err error) { r.board, err = h.getBoardInfo(ctx, npuID); return },
+			func() (err error) { r.product, err = h.getProductInfo(ctx, npuID); return },
+		)
+		if needsPower[npuID] {
+			tasks = append(tasks, func() (err error) { r.power, err = h.getPowerInfo(ctx, npuID); return })
+		}
+	}
+	var failed []error
+	for _, err := range runBounded(h.concurrencyOrDefault(), tasks) {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
 
-		infos, nextNum := buildGPUInfoList(npuID, boardInfo, commonInfo, usagesInfo, productInfo, powerInfo, globalNum)
-		result.GPUInfos = append(result.GPUInfos, infos...)
-		globalNum = nextNum
+	result := &gpu.GPUInfoList{GPUInfos: []gpu.GPUInfo{}}
+	for num, chip := range table.Chips {
+		r := results[chip.NPUID]
+		result.GPUInfos = append(result.GPUInfos, buildTableGPUInfo(chip, r.board, r.product, r.power, num))
+	}
+	return finishLoad(result, failed)
+}
+
+// finishLoad reports a load with failed sub-queries as partial, or as failed
+// when no NPU could be listed at all.
+func finishLoad(result *gpu.GPUInfoList, failed []error) (*gpu.GPUInfoList, error) {
+	switch {
+	case len(failed) == 0:
+		return result, nil
+	case len(result.GPUInfos) == 0:
+		return nil, fmt.Errorf("failed to query NPUs: %w", errors.Join(failed...))
+	default:
+		return result, &gpu.PartialError{Errs: failed}
+	}
+}
+
+func buildTableGPUInfo(
+	chip tableChip,
+	board map[string]string,
+	product map[string]map[string]string,
+	power map[string]string,
+	num int,
+) gpu.GPUInfo {
+	var metrics gpu.GPUMetrics
+	metrics.PowerLimit = gpu.UnsupportedFloat()
+	metrics.FanSpeed = gpu.UnsupportedFloat()
+
+	temp := gpu.NotAvailable
+	metrics.TemperatureEdge = gpu.ParseFloatMetric(chip.Temperature)
+	if metrics.TemperatureEdge.Available() {
+		temp = chip.Temperature
+	}
+	// npu-smi reports a single chip temperature.
+	metrics.TemperatureJunction = gpu.UnsupportedFloat()
+	metrics.TemperatureMemory = gpu.UnsupportedFloat()
+
+	gpuUse := gpu.NotAvailable
+	metrics.GPUUse = gpu.ParseFloatMetric(chip.AICore)
+	if metrics.GPUUse.Available() {
+		gpuUse = chip.AICore
+	}
+
+	metrics.VRAMTotalMemory = chip.MemoryTotal
+	metrics.VRAMTotalUsedMemory = chip.MemoryUsed
+
+	powerValue := gpu.NotAvailable
+	metrics.AverageGraphicsPackagePower = gpu.ParseFloatMetric(chip.Power)
+	if metrics.AverageGraphicsPackagePower.Available() {
+		powerValue = chip.Power
+	} else {
+		// The table printed NA, so "-t power" was queried; a failed query
+		// leaves power nil and the reading unavailable.
+		metrics.AverageGraphicsPackagePower = parseMetric(power, "Power Dissipation(W)")
+		powerValue = powerString(power, metrics.AverageGraphicsPackagePower)
+	}
+
+	// Product type comes from "-t product"; fall back to the chip name.
+	model := chip.Name
+	if v := product[chip.ChipID]["Product Type"]; v != "" && v != "NA" {
+		model = v
 	}
 
-	return result, nil
+	pciBus := chip.BusID
+	if pciBus == "" {
+		pciBus = board["PCIe Bus Info"]
+	}
+
+	return gpu.GPUInfo{
+		Num:                         num,
+		DeviceID:                    chip.ChipID,
+		CardVendor:                  "Huawei",
+		CardSeries:                  "Ascend",
+		CardModel:                   model,
+		TemperatureEdge:             temp,
+		TemperatureJunction:         temp,
+		TemperatureMemory:           temp,
+		GPUUse:                      gpuUse,
+		VRAMTotalMemory:             metrics.VRAMTotalMemory.String(),
+		VRAMTotalUsedMemory:         metrics.VRAMTotalUsedMemory.String(),
+		AverageGraphicsPackagePower: powerValue,
+		SerialNumber:                board["Serial Number"],
+		PCIBus:                      gpu.CanonicalPCIAddress(pciBus),
+		Metrics:                     metrics,
+		Health:                      npuHealth(chip.Health),
+	}
+}
+
+// npuHealth maps the Health column of "npu-smi info" onto gpu.Health. The
+// column gives no reason, so Warning is ReasonVendorWarning and the worse
+// Alarm and Critical are ReasonVendorAlarm. Other values, such as NA, give
+// nil.
+func npuHealth(value string) *gpu.Health {
+	var h *gpu.Health
+	switch strings.ToLower(value) {
+	case "ok":
+		h = gpu.NewHealth()
+	case "warning":
+		h = gpu.NewHealth(gpu.ReasonVendorWarning)
+	case "alarm", "critical":
+		h = gpu.NewHealth(gpu.ReasonVendorAlarm)
+	default:
+		return nil
+	}
+	h.VendorStatus = value
+	return h
+}
+
+func (h *npuSMICommand) concurrencyOrDefault() int {
+	if h.concurrency < 1 {
+		return DefaultConcurrency
+	}
+	return h.concurrency
+}
+
+// runBounded runs tasks with at most limit of them at a time and returns
+// their errors in task order.
+func runBounded(limit int, tasks []func() error) []error {
+	errs := make([]error, len(tasks))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = task()
+		}()
+	}
+	wg.Wait()
+	return errs
 }
 
 func buildGPUInfoList(
@@ -88,11 +461,9 @@ func buildGPUInfoList(
 	power map[string]string,
 	globalNum int,
 ) ([]gpu.GPUInfo, int) {
-	powerVal := "0"
+	var powerMetric gpu.FloatMetric
 	if power != nil {
-		if v, ok := power["Power Dissipation(W)"]; ok && v != "" && v != "NA" {
-			powerVal = v
-		}
+		powerMetric = parseMetric(power, "Power Dissipation(W)")
 	}
 
 	serialNumber := ""
@@ -130,41 +501,46 @@ func buildGPUInfoList(
 		chipUsages := usages[chipID]
 		chipProduct := product[chipID]
 
+		var metrics gpu.GPUMetrics
+		metrics.AverageGraphicsPackagePower = powerMetric
+		metrics.PowerLimit = gpu.UnsupportedFloat()
+		metrics.FanSpeed = gpu.UnsupportedFloat()
+
 		// Temperature
-		temp := "0"
-		if chipCommon != nil {
-			if v, ok := chipCommon["Temperature(C)"]; ok && v != "" && v != "NA" {
-				temp = v
-			}
+		temp := gpu.NotAvailable
+		metrics.TemperatureEdge = parseMetric(chipCommon, "Temperature(C)")
+		if metrics.TemperatureEdge.Available() {
+			temp = chipCommon["Temperature(C)"]
 		}
+		// npu-smi reports a single chip temperature.
+		metrics.TemperatureJunction = gpu.UnsupportedFloat()
+		metrics.TemperatureMemory = gpu.UnsupportedFloat()
 
 		// GPU Use: prefer usages Aicore, fallback to common Aicore
-		gpuUse := "0"
-		if chipUsages != nil {
-			if v, ok := chipUsages["Aicore Usage Rate(%)"]; ok && v != "" && v != "NA" {
-				gpuUse = v
-			}
+		gpuUse := gpu.NotAvailable
+		metrics.GPUUse = parseMetric(chipUsages, "Aicore Usage Rate(%)")
+		if metrics.GPUUse.Available() {
+			gpuUse = chipUsages["Aicore Usage Rate(%)"]
 		}
-		if gpuUse == "0" && chipCommon != nil {
-			if v, ok := chipCommon["Aicore Usage Rate(%)"]; ok && v != "" && v != "NA" {
-				gpuUse = v
+		if !metrics.GPUUse.Available() || metrics.GPUUse.Value == 0 {
+			if m := parseMetric(chipCommon, "Aicore Usage Rate(%)"); m.Available() {
+				metrics.GPUUse = m
+				gpuUse = chipCommon["Aicore Usage Rate(%)"]
 			}
 		}
 
 		// VRAM: total from usages DDR Capacity, used computed from DDR Usage Rate
-		var vramTotalBytes int64
-		var vramUsedBytes int64
-		if chipUsages != nil {
-			if capStr, ok := chipUsages["DDR Capacity(MB)"]; ok && capStr != "" {
-				if capMB, err := strconv.ParseFloat(capStr, 64); err == nil {
-					vramTotalBytes = int64(capMB * 1024 * 1024)
-				}
-			}
-			if rateStr, ok := chipUsages["DDR Usage Rate(%)"]; ok && rateStr != "" {
-				if rate, err := strconv.ParseFloat(rateStr, 64); err == nil && vramTotalBytes > 0 {
-					vramUsedBytes = int64(float64(vramTotalBytes) * rate / 100.0)
-				}
-			}
+		vramTotal := parseMetric(chipUsages, "DDR Capacity(MB)")
+		if vramTotal.Available() {
+			metrics.VRAMTotalMemory = gpu.Uint(uint64(vramTotal.Value * 1024 * 1024))
+		} else {
+			metrics.VRAMTotalMemory.Status = vramTotal.Status
+		}
+		rate := parseMetric(chipUsages, "DDR Usage Rate(%)")
+		if rate.Available() && metrics.VRAMTotalMemory.Value > 0 {
+			metrics.VRAMTotalUsedMemory = gpu.Uint(uint64(float64(metrics.VRAMTotalMemory.Value) * rate.Value / 100.0))
+		} else {
+			metrics.VRAMTotalUsedMemory.Status = rate.Status
 		}
 
 		// Model
@@ -185,11 +561,12 @@ func buildGPUInfoList(
 			TemperatureJunction:         temp,
 			TemperatureMemory:           temp,
 			GPUUse:                      gpuUse,
-			VRAMTotalMemory:             fmt.Sprintf("%d", vramTotalBytes),
-			VRAMTotalUsedMemory:         fmt.Sprintf("%d", vramUsedBytes),
-			AverageGraphicsPackagePower: powerVal,
+			VRAMTotalMemory:             metrics.VRAMTotalMemory.String(),
+			VRAMTotalUsedMemory:         metrics.VRAMTotalUsedMemory.String(),
+			AverageGraphicsPackagePower: powerString(power, powerMetric),
 			SerialNumber:                serialNumber,
-			PCIBus:                      pciBus,
+			PCIBus:                      gpu.CanonicalPCIAddress(pciBus),
+			Metrics:                     metrics,
 		})
 		globalNum++
 	}
@@ -197,6 +574,23 @@ func buildGPUInfoList(
 	return infos, globalNum
 }
 
+// parseMetric reads a numeric npu-smi value. A missing key is unavailable,
+// while the "NA" npu-smi prints for unsupported sensors is unsupported.
+func parseMetric(values map[string]string, key string) gpu.FloatMetric {
+	v, ok := values[key]
+	if !ok {
+		return gpu.FloatMetric{}
+	}
+	return gpu.ParseFloatMetric(v)
+}
+
+func powerString(power map[string]string, m gpu.FloatMetric) string {
+	if !m.Available() {
+		return gpu.NotAvailable
+	}
+	return power["Power Dissipation(W)"]
+}
+
 // extractNPUIDs extracts unique NPU IDs from npu-smi info table output.
 // It distinguishes NPU rows (e.g. "2944    310P3") from Chip rows (e.g. "0       0")
 // by checking whether the second field contains non-digit characters.
@@ -346,47 +740,50 @@ func parseBoardOutput(output []byte) map[string]string {
 	return result
 }
 
-func (h *npuSMICommand) getBoardInfo(npuID string) (map[string]string, error) {
-	cmd := exec.Command(h.smiPath, "info", "-t", "board", "-i", npuID)
-	output, err := cmd.CombinedOutput()
+func (h *npuSMICommand) subQuery(ctx context.Context, query, npuID string) ([]byte, error) {
+	output, err := gpu.CombinedOutput(ctx, h.executor, h.lookPath(), "info", "-t", query, "-i", npuID)
+	if err != nil {
+		return nil, &SubQueryError{NPUID: npuID, Query: query, Err: err}
+	}
+	return output, nil
+}
+
+func (h *npuSMICommand) getBoardInfo(ctx context.Context, npuID string) (map[string]string, error) {
+	output, err := h.subQuery(ctx, "board", npuID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get board info for NPU %s: %v", npuID, err)
+		return nil, err
 	}
 	return parseBoardOutput(output), nil
 }
 
-func (h *npuSMICommand) getCommonInfo(npuID string) (map[string]map[string]string, error) {
-	cmd := exec.Command(h.smiPath, "info", "-t", "common", "-i", npuID)
-	output, err := cmd.CombinedOutput()
+func (h *npuSMICommand) getCommonInfo(ctx context.Context, npuID string) (map[string]map[string]string, error) {
+	output, err := h.subQuery(ctx, "common", npuID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get common info for NPU %s: %v", npuID, err)
+		return nil, err
 	}
 	return parseChipSections(output), nil
 }
 
-func (h *npuSMICommand) getUsagesInfo(npuID string) (map[string]map[string]string, error) {
-	cmd := exec.Command(h.smiPath, "info", "-t", "usages", "-i", npuID)
-	output, err := cmd.CombinedOutput()
+func (h *npuSMICommand) getUsagesInfo(ctx context.Context, npuID string) (map[string]map[string]string, error) {
+	output, err := h.subQuery(ctx, "usages", npuID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get usages info for NPU %s: %v", npuID, err)
+		return nil, err
 	}
 	return parseChipSections(output), nil
 }
 
-func (h *npuSMICommand) getProductInfo(npuID string) (map[string]map[string]string, error) {
-	cmd := exec.Command(h.smiPath, "info", "-t", "product", "-i", npuID)
-	output, err := cmd.CombinedOutput()
+func (h *npuSMICommand) getProductInfo(ctx context.Context, npuID string) (map[string]map[string]string, error) {
+	output, err := h.subQuery(ctx, "product", npuID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get product info for NPU %s: %v", npuID, err)
+		return nil, err
 	}
 	return parseChipSections(output), nil
 }
 
-func (h *npuSMICommand) getPowerInfo(npuID string) (map[string]string, error) {
-	cmd := exec.Command(h.smiPath, "info", "-t", "power", "-i", npuID)
-	output, err := cmd.CombinedOutput()
+func (h *npuSMICommand) getPowerInfo(ctx context.Context, npuID string) (map[string]string, error) {
+	output, err := h.subQuery(ctx, "power", npuID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get power info for NPU %s: %v", npuID, err)
+		return nil, err
 	}
 	return parseBoardOutput(output), nil
 }
@@ -1,9 +1,15 @@
 package huawei
 
 import (
+	"context"
 	"embed"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -305,7 +311,7 @@ func TestBuildGPUInfoList(t *testing.T) {
 		assert.Equal(t, "45", infos[0].TemperatureMemory)
 		assert.Equal(t, "10", infos[0].GPUUse)
 		assert.Equal(t, "SN12345", infos[0].SerialNumber)
-		assert.Equal(t, "0000:0C:00.0", infos[0].PCIBus)
+		assert.Equal(t, "0000:0c:00.0", infos[0].PCIBus)
 		assert.Equal(t, "43.0", infos[0].AverageGraphicsPackagePower)
 		// VRAM: 44278 * 1024 * 1024 = 46428848128
 		assert.Equal(t, "46428848128", infos[0].VRAMTotalMemory)
@@ -320,7 +326,7 @@ func TestBuildGPUInfoList(t *testing.T) {
 		assert.Equal(t, "Atlas 300I Duo", infos[1].CardModel)
 	})
 
-	t.Run("missing_common_temperature_fallback_to_zero", func(t *testing.T) {
+	t.Run("missing_common_temperature_not_available", func(t *testing.T) {
 		board := map[string]string{}
 		common := map[string]map[string]string{}
 		usages := map[string]map[string]string{
@@ -331,11 +337,13 @@ func TestBuildGPUInfoList(t *testing.T) {
 
 		infos, _ := buildGPUInfoList("2944", board, common, usages, product, power, 0)
 		assert.Len(t, infos, 1)
-		assert.Equal(t, "0", infos[0].TemperatureEdge)
-		assert.Equal(t, "0", infos[0].GPUUse)
+		assert.Equal(t, "N/A", infos[0].TemperatureEdge)
+		assert.Equal(t, "N/A", infos[0].GPUUse)
+		assert.Equal(t, gpu.MetricUnavailable, infos[0].Metrics.TemperatureEdge.Status)
+		assert.Equal(t, gpu.MetricUnavailable, infos[0].Metrics.GPUUse.Status)
 	})
 
-	t.Run("missing_usages_vram_zero", func(t *testing.T) {
+	t.Run("missing_usages_vram_not_available", func(t *testing.T) {
 		board := map[string]string{}
 		common := map[string]map[string]string{
 			"0": {"Temperature(C)": "50", "Aicore Usage Rate(%)": "30"},
@@ -348,8 +356,10 @@ func TestBuildGPUInfoList(t *testing.T) {
 		assert.Len(t, infos, 1)
 		assert.Equal(t, "50", infos[0].TemperatureEdge)
 		assert.Equal(t, "30", infos[0].GPUUse)
-		assert.Equal(t, "0", infos[0].VRAMTotalMemory)
-		assert.Equal(t, "0", infos[0].VRAMTotalUsedMemory)
+		assert.Equal(t, "N/A", infos[0].VRAMTotalMemory)
+		assert.Equal(t, "N/A", infos[0].VRAMTotalUsedMemory)
+		assert.False(t, infos[0].Metrics.VRAMTotalMemory.Available())
+		assert.False(t, infos[0].Metrics.VRAMTotalUsedMemory.Available())
 	})
 
 	t.Run("gpu_use_fallback_from_common", func(t *testing.T) {
@@ -367,7 +377,7 @@ func TestBuildGPUInfoList(t *testing.T) {
 		assert.Equal(t, "25", infos[0].GPUUse)
 	})
 
-	t.Run("power_na_becomes_zero", func(t *testing.T) {
+	t.Run("power_na_is_unsupported", func(t *testing.T) {
 		board := map[string]string{}
 		common := map[string]map[string]string{
 			"0": {"Temperature(C)": "40"},
@@ -379,10 +389,12 @@ func TestBuildGPUInfoList(t *testing.T) {
 		power := map[string]string{"Power Dissipation(W)": "NA"}
 
 		infos, _ := buildGPUInfoList("2944", board, common, usages, product, power, 0)
-		assert.Equal(t, "0", infos[0].AverageGraphicsPackagePower)
+		assert.Equal(t, "N/A", infos[0].AverageGraphicsPackagePower)
+		assert.Equal(t, gpu.MetricUnsupported, infos[0].Metrics.AverageGraphicsPackagePower.Status)
+		assert.Equal(t, gpu.Float(40), infos[0].Metrics.TemperatureEdge)
 	})
 
-	t.Run("power_empty_becomes_zero", func(t *testing.T) {
+	t.Run("power_missing_is_unavailable", func(t *testing.T) {
 		board := map[string]string{}
 		common := map[string]map[string]string{
 			"0": {"Temperature(C)": "40"},
@@ -394,7 +406,8 @@ func TestBuildGPUInfoList(t *testing.T) {
 		power := map[string]string{}
 
 		infos, _ := buildGPUInfoList("2944", board, common, usages, product, power, 0)
-		assert.Equal(t, "0", infos[0].AverageGraphicsPackagePower)
+		assert.Equal(t, "N/A", infos[0].AverageGraphicsPackagePower)
+		assert.Equal(t, gpu.MetricUnavailable, infos[0].Metrics.AverageGraphicsPackagePower.Status)
 	})
 
 	t.Run("missing_product_model_empty", func(t *testing.T) {
@@ -491,6 +504,28 @@ func TestNpuSMICommandVendor(t *testing.T) {
 func TestAvailableWithCachedPath(t *testing.T) {
 	cmd := &npuSMICommand{smiPath: "/some/path/npu-smi"}
 	assert.True(t, cmd.Available())
+
+	// A new executor looks npu-smi up again.
+	cmd.SetExecutor(gpu.NewScriptedExecutor())
+	assert.False(t, cmd.Available())
+}
+
+func TestConcurrentQueries(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info.txt")
+	fake := gpu.NewScriptedExecutor().
+		Stdout(info, "npu-smi", "info").
+		Stdout([]byte("NPU0 X\n"), "npu-smi", "info", "-t", "topo")
+	cmd := &npuSMICommand{executor: fake}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(3)
+		go func() { defer wg.Done(); cmd.Available() }()
+		go func() { defer wg.Done(); _, _ = cmd.Processes(context.Background()) }()
+		go func() { defer wg.Done(); _, _ = cmd.Topology(context.Background()) }()
+	}
+	wg.Wait()
+	assert.Equal(t, "npu-smi", cmd.lookPath())
 }
 
 func TestNewReturnsCommand(t *testing.T) {
@@ -530,10 +565,17 @@ func TestIntegrationDualChipFromFixtures(t *testing.T) {
 	assert.Equal(t, "45", infos[0].TemperatureEdge)
 	assert.Equal(t, "0", infos[0].GPUUse)
 	assert.Equal(t, "2106030737ZERC003572", infos[0].SerialNumber)
-	assert.Equal(t, "0000:0C:00.0", infos[0].PCIBus)
+	assert.Equal(t, "0000:0c:00.0", infos[0].PCIBus)
 	assert.Equal(t, "42.9", infos[0].AverageGraphicsPackagePower)
 	assert.Equal(t, "46428848128", infos[0].VRAMTotalMemory)
 	assert.Equal(t, "928576962", infos[0].VRAMTotalUsedMemory)
+	assert.Equal(t, gpu.Float(42.9), infos[0].Metrics.AverageGraphicsPackagePower)
+	assert.Equal(t, gpu.Float(45), infos[0].Metrics.TemperatureEdge)
+	assert.Equal(t, gpu.UnsupportedFloat(), infos[0].Metrics.TemperatureJunction)
+	assert.Equal(t, gpu.UnsupportedFloat(), infos[0].Metrics.TemperatureMemory)
+	assert.Equal(t, gpu.Float(0), infos[0].Metrics.GPUUse)
+	assert.Equal(t, gpu.Uint(46428848128), infos[0].Metrics.VRAMTotalMemory)
+	assert.Equal(t, gpu.Uint(928576962), infos[0].Metrics.VRAMTotalUsedMemory)
 
 	// Chip 1
 	assert.Equal(t, 1, infos[1].Num)
@@ -578,3 +620,384 @@ func TestIntegrationSingleChipFromFixtures(t *testing.T) {
 	// 23068672000 * 10 / 100 = 2306867200
 	assert.Equal(t, "2306867200", infos[0].VRAMTotalUsedMemory)
 }
+
+// scriptNPU scripts "npu-smi info" plus the five per-NPU sub-queries from the
+// fixtures named prefix_{board,common,usages,product,power}.txt.
+func scriptNPU(t *testing.T, fake *gpu.ScriptedExecutor, infoFixture, prefix, npuID string) {
+	t.Helper()
+	read := func(name string) []byte {
+		data, err := testdataFS.ReadFile("testdata/" + name)
+		assert.NoError(t, err)
+		return data
+	}
+	fake.Stdout(read(infoFixture), "npu-smi", "info")
+	for _, typ := range []string{"board", "common", "usages", "product", "power"} {
+		fake.Stdout(read(prefix+"_"+typ+".txt"), "npu-smi", "info", "-t", typ, "-i", npuID)
+	}
+}
+
+func TestLoadDualChipWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor()
+	scriptNPU(t, fake, "npu_info.txt", "npu", "2944")
+	cmd := New()
+	cmd.SetExecutor(fake)
+
+	assert.True(t, cmd.Available())
+	list, err := cmd.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+	assert.Equal(t, "Atlas 300I Duo", list.GPUInfos[0].CardModel)
+	assert.Equal(t, "2106030737ZERC003572", list.GPUInfos[0].SerialNumber)
+	assert.Equal(t, gpu.Float(42.9), list.GPUInfos[0].Metrics.AverageGraphicsPackagePower)
+	assert.Equal(t, gpu.Float(47), list.GPUInfos[1].Metrics.TemperatureEdge)
+	// The summary table has no power for 310P3, so "-t power" is queried too.
+	assert.Len(t, fake.Calls(), 4)
+	// Memory comes straight from the table rather than from the usage rate.
+	assert.Equal(t, gpu.Uint(1255*1024*1024), list.GPUInfos[0].Metrics.VRAMTotalUsedMemory)
+	assert.Equal(t, "46428848128", list.GPUInfos[0].VRAMTotalMemory)
+}
+
+func TestLoadDualChipDetailedQueries(t *testing.T) {
+	fake := gpu.NewScriptedExecutor()
+	scriptNPU(t, fake, "npu_info.txt", "npu", "2944")
+	cmd := New(WithDetailedQueries())
+	cmd.SetExecutor(fake)
+
+	list, err := cmd.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+	assert.Equal(t, "Atlas 300I Duo", list.GPUInfos[0].CardModel)
+	assert.Equal(t, "928576962", list.GPUInfos[0].VRAMTotalUsedMemory)
+	assert.Equal(t, gpu.HealthOK, list.GPUInfos[1].Health.Status, "from the summary table")
+	assert.Len(t, fake.Calls(), 6)
+}
+
+func TestLoadSingleChipWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor()
+	scriptNPU(t, fake, "npu_single_chip.txt", "npu_single", "1234")
+	cmd := &npuSMICommand{executor: fake}
+
+	list, err := cmd.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 1)
+	assert.Equal(t, "Atlas 300I Pro", list.GPUInfos[0].CardModel)
+	assert.Equal(t, "65.5", list.GPUInfos[0].AverageGraphicsPackagePower)
+	assert.Equal(t, "2306867200", list.GPUInfos[0].VRAMTotalUsedMemory)
+}
+
+func TestLoadFallsBackToSbinPath(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_single_chip.txt")
+	common, _ := testdataFS.ReadFile("testdata/npu_single_common.txt")
+	fake := gpu.NewScriptedExecutor().
+		Path("/usr/local/sbin/npu-smi", "/usr/local/sbin/npu-smi").
+		Stdout(info, "/usr/local/sbin/npu-smi", "info").
+		Stdout(common, "/usr/local/sbin/npu-smi", "info", "-t", "common", "-i", "1234")
+	cmd := &npuSMICommand{executor: fake}
+
+	list, err := cmd.Load()
+	var partial *gpu.PartialError
+	assert.ErrorAs(t, err, &partial)
+	// Board and product are unscripted; the table has everything else.
+	assert.Len(t, partial.Errs, 2)
+	assert.Len(t, list.GPUInfos, 1)
+	assert.Equal(t, gpu.Float(55), list.GPUInfos[0].Metrics.TemperatureEdge)
+	assert.Equal(t, "65.5", list.GPUInfos[0].AverageGraphicsPackagePower)
+	assert.Equal(t, "910B", list.GPUInfos[0].CardModel)
+	assert.Equal(t, "", list.GPUInfos[0].SerialNumber)
+}
+
+func TestLoadWithoutNPUSMI(t *testing.T) {
+	cmd := &npuSMICommand{executor: gpu.NewScriptedExecutor()}
+	assert.False(t, cmd.Available())
+	_, err := cmd.Load()
+	assert.Error(t, err)
+}
+
+func TestLoadSurfacesFailedSubQueries(t *testing.T) {
+	fake := gpu.NewScriptedExecutor()
+	scriptNPU(t, fake, "npu_info.txt", "npu", "2944")
+	fake.Script(gpu.Result{Stderr: []byte("power query not supported"), ExitCode: 1}, "npu-smi", "info", "-t", "power", "-i", "2944")
+	cmd := New(WithConcurrency(2))
+	cmd.SetExecutor(fake)
+
+	list, err := cmd.Load()
+	assert.Len(t, list.GPUInfos, 2)
+	assert.Equal(t, gpu.MetricUnavailable, list.GPUInfos[0].Metrics.AverageGraphicsPackagePower.Status)
+
+	var partial *gpu.PartialError
+	assert.ErrorAs(t, err, &partial)
+	assert.Len(t, partial.Errs, 1)
+	var subErr *SubQueryError
+	assert.ErrorAs(t, err, &subErr)
+	assert.Equal(t, "2944", subErr.NPUID)
+	assert.Equal(t, "power", subErr.Query)
+	assert.ErrorContains(t, err, "power query not supported")
+}
+
+func TestLoadAllSubQueriesFailed(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info.txt")
+	fake := gpu.NewScriptedExecutor().Stdout(info, "npu-smi", "info")
+	cmd := &npuSMICommand{executor: fake, detailed: true}
+
+	list, err := cmd.Load()
+	assert.Nil(t, list)
+	var subErr *SubQueryError
+	assert.ErrorAs(t, err, &subErr)
+}
+
+func TestDetectAllGivesChipsDistinctIDs(t *testing.T) {
+	// Both chips of the 310P3 in npu_info.txt report Bus-Id 0000:0C:00.0.
+	info, _ := testdataFS.ReadFile("testdata/npu_info.txt")
+	fake := gpu.NewScriptedExecutor().Stdout(info, "npu-smi", "info")
+	cmd := &npuSMICommand{executor: fake}
+
+	inv := gpu.DetectAll(context.Background(), gpu.WithPCIEnumerator(nil), gpu.WithLoaders(cmd))
+	assert.Len(t, inv.GPUs, 2)
+	assert.Equal(t, "huawei-pci-0000:0c:00.0-0", inv.GPUs[0].ID)
+	assert.Equal(t, "huawei-pci-0000:0c:00.0-1", inv.GPUs[1].ID)
+}
+
+func TestLoadFromTableWithoutSubQueries(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info.txt")
+	fake := gpu.NewScriptedExecutor().Stdout(info, "npu-smi", "info")
+	cmd := &npuSMICommand{executor: fake}
+
+	list, err := cmd.Load()
+	var partial *gpu.PartialError
+	assert.ErrorAs(t, err, &partial)
+	assert.Len(t, partial.Errs, 3) // board, product and power of NPU 2944
+	assert.Len(t, list.GPUInfos, 2)
+	assert.Equal(t, gpu.Float(45), list.GPUInfos[0].Metrics.TemperatureEdge)
+	assert.Equal(t, gpu.UnsupportedFloat(), list.GPUInfos[0].Metrics.TemperatureJunction)
+	assert.Equal(t, gpu.UnsupportedFloat(), list.GPUInfos[0].Metrics.TemperatureMemory)
+	assert.Equal(t, "45", list.GPUInfos[0].TemperatureJunction)
+	assert.Equal(t, gpu.MetricUnavailable, list.GPUInfos[0].Metrics.AverageGraphicsPackagePower.Status)
+}
+
+func TestRunBounded(t *testing.T) {
+	var inFlight, maxSeen atomic.Int32
+	tasks := make([]func() error, 10)
+	for i := range tasks {
+		tasks[i] = func() error {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				seen := maxSeen.Load()
+				if n <= seen || maxSeen.CompareAndSwap(seen, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if i%3 == 0 {
+				return fmt.Errorf("task %d", i)
+			}
+			return nil
+		}
+	}
+
+	errs := runBounded(3, tasks)
+	assert.LessOrEqual(t, maxSeen.Load(), int32(3))
+	assert.Greater(t, maxSeen.Load(), int32(1))
+	for i, err := range errs {
+		if i%3 == 0 {
+			assert.EqualError(t, err, fmt.Sprintf("task %d", i))
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestNewWithConcurrency(t *testing.T) {
+	assert.Equal(t, DefaultConcurrency, New().concurrencyOrDefault())
+	assert.Equal(t, 3, New(WithConcurrency(3)).concurrencyOrDefault())
+	assert.Equal(t, DefaultConcurrency, New(WithConcurrency(0)).concurrencyOrDefault())
+}
+
+var _ gpu.ExecutorSetter = &npuSMICommand{}
+
+func TestParseInfoTable(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info.txt")
+	table := parseInfoTable(info)
+
+	assert.Len(t, table.Chips, 2)
+	assert.Empty(t, table.Processes)
+	assert.Equal(t, []string{"2944"}, table.npuIDs())
+
+	chip := table.Chips[1]
+	assert.Equal(t, "2944", chip.NPUID)
+	assert.Equal(t, "310P3", chip.Name)
+	assert.Equal(t, "OK", chip.Health)
+	assert.Equal(t, "NA", chip.Power)
+	assert.Equal(t, "47", chip.Temperature)
+	assert.Equal(t, "1", chip.ChipID)
+	assert.Equal(t, "1", chip.Device)
+	assert.Equal(t, "0000:0C:00.0", chip.BusID)
+	assert.Equal(t, "0", chip.AICore)
+	assert.Equal(t, gpu.Uint(1472*1024*1024), chip.MemoryUsed)
+	assert.Equal(t, gpu.Uint(43693*1024*1024), chip.MemoryTotal)
+}
+
+func TestParseInfoTable910B(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info_910b.txt")
+	table := parseInfoTable(info)
+
+	assert.Len(t, table.Chips, 2)
+	assert.Equal(t, []string{"0", "1"}, table.npuIDs())
+
+	// 910 boards report device memory in the HBM-Usage column.
+	chip := table.Chips[0]
+	assert.Equal(t, "910B3", chip.Name)
+	assert.Equal(t, "93.3", chip.Power)
+	assert.Equal(t, "0", chip.ChipID)
+	assert.Equal(t, "", chip.Device)
+	assert.Equal(t, "0000:C1:00.0", chip.BusID)
+	assert.Equal(t, "12", chip.AICore)
+	assert.Equal(t, gpu.Uint(33404*1024*1024), chip.MemoryUsed)
+	assert.Equal(t, gpu.Uint(65536*1024*1024), chip.MemoryTotal)
+	assert.Equal(t, "Warning", table.Chips[1].Health)
+
+	assert.Equal(t, []tableProcess{
+		{NPUID: "0", ChipID: "0", PID: 1234567, Name: "python3", Memory: gpu.Uint(30012 * 1024 * 1024)},
+		{NPUID: "0", ChipID: "0", PID: 1234890, Name: "mindie_llm_back", Memory: gpu.Uint(1024 * 1024 * 1024)},
+	}, table.Processes)
+}
+
+func TestParseInfoTableUnrecognised(t *testing.T) {
+	table := parseInfoTable([]byte("npu-smi: command failed\n"))
+	assert.Empty(t, table.Chips)
+	assert.Empty(t, table.Processes)
+}
+
+func TestLoadFallsBackToDetailedQueries(t *testing.T) {
+	// A table layout the parser does not understand still yields NPU IDs for
+	// the detailed queries.
+	fake := gpu.NewScriptedExecutor()
+	scriptNPU(t, fake, "npu_info.txt", "npu", "2944")
+	fake.Stdout([]byte("| 2944    310P3   | OK |\n"), "npu-smi", "info")
+	cmd := &npuSMICommand{executor: fake}
+
+	list, err := cmd.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+	assert.Len(t, fake.Calls(), 6)
+}
+
+func TestLoad910BFromTable(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info_910b.txt")
+	fake := gpu.NewScriptedExecutor().Stdout(info, "npu-smi", "info")
+	for _, id := range []string{"0", "1"} {
+		fake.Stdout([]byte("\tSerial Number                  : SN91"+id+"\n"), "npu-smi", "info", "-t", "board", "-i", id).
+			Stdout([]byte("\tProduct Type                   : Atlas 800T A2\n\tChip ID                        : 0\n"), "npu-smi", "info", "-t", "product", "-i", id)
+	}
+	cmd := &npuSMICommand{executor: fake}
+
+	list, err := cmd.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+	assert.Len(t, fake.Calls(), 5) // info plus board and product per NPU; power is in the table
+	assert.Equal(t, 1, list.GPUInfos[1].Num)
+	assert.Equal(t, "Atlas 800T A2", list.GPUInfos[1].CardModel)
+	assert.Equal(t, "SN911", list.GPUInfos[1].SerialNumber)
+	assert.Equal(t, gpu.Float(93.3), list.GPUInfos[0].Metrics.AverageGraphicsPackagePower)
+	assert.Equal(t, gpu.Float(12), list.GPUInfos[0].Metrics.GPUUse)
+	assert.Equal(t, "35026632704", list.GPUInfos[0].VRAMTotalUsedMemory)
+	assert.Equal(t, &gpu.Health{Status: gpu.HealthOK, VendorStatus: "OK"}, list.GPUInfos[0].Health)
+	assert.Equal(t, &gpu.Health{
+		Status:       gpu.HealthWarning,
+		Reasons:      []gpu.HealthReason{gpu.ReasonVendorWarning},
+		VendorStatus: "Warning",
+	}, list.GPUInfos[1].Health)
+}
+
+func TestNPUHealth(t *testing.T) {
+	assert.Equal(t, gpu.HealthCritical, npuHealth("Alarm").Status)
+	assert.Equal(t, []gpu.HealthReason{gpu.ReasonVendorAlarm}, npuHealth("Critical").Reasons)
+	assert.Nil(t, npuHealth("NA"))
+	assert.Nil(t, npuHealth(""))
+}
+
+func TestProcessesWithScriptedExecutor(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info_910b.txt")
+	fake := gpu.NewScriptedExecutor().Stdout(info, "npu-smi", "info")
+	cmd := &npuSMICommand{executor: fake}
+
+	processes, err := cmd.Processes(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []gpu.GPUProcess{
+		{PID: 1234567, Name: "python3", DeviceIndex: 0, MemoryUsed: gpu.Uint(30012 * 1024 * 1024)},
+		{PID: 1234890, Name: "mindie_llm_back", DeviceIndex: 0, MemoryUsed: gpu.Uint(1024 * 1024 * 1024)},
+	}, processes)
+	assert.Len(t, fake.Calls(), 1)
+
+	info, _ = testdataFS.ReadFile("testdata/npu_info.txt")
+	cmd = &npuSMICommand{executor: gpu.NewScriptedExecutor().Stdout(info, "npu-smi", "info")}
+	processes, err = cmd.Processes(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, processes)
+	assert.NotNil(t, processes)
+}
+
+func TestTableProcessesSkipsUnknownChips(t *testing.T) {
+	table := &infoTable{
+		Chips: []tableChip{{NPUID: "1", ChipID: "0"}, {NPUID: "2", ChipID: "0"}},
+		Processes: []tableProcess{
+			{NPUID: "2", ChipID: "0", PID: 100, Name: "python3"},
+			{NPUID: "3", ChipID: "0", PID: 200, Name: "orphan"},
+		},
+	}
+	assert.Equal(t, []gpu.GPUProcess{{PID: 100, Name: "python3", DeviceIndex: 1}}, tableProcesses(table))
+}
+
+func TestDriverInfoWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor()
+	scriptNPU(t, fake, "npu_info.txt", "npu", "2944")
+	cmd := &npuSMICommand{executor: fake}
+
+	info, err := cmd.DriverInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, gpu.GPUDriverInfo{
+		Vendor:          "Huawei",
+		Version:         "25.5.1",
+		Installed:       true,
+		InstallPath:     "npu-smi",
+		ClientVersion:   "25.5.1",
+		FirmwareVersion: "7.8.0.6.201",
+	}, info)
+}
+
+func TestDriverInfoWithoutBoardQuery(t *testing.T) {
+	info, _ := testdataFS.ReadFile("testdata/npu_info_910b.txt")
+	cmd := &npuSMICommand{executor: gpu.NewScriptedExecutor().Stdout(info, "npu-smi", "info")}
+
+	driver, err := cmd.DriverInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "23.0.rc3", driver.Version)
+	assert.Equal(t, "", driver.FirmwareVersion)
+}
+
+func TestParseVersionHeaderMissing(t *testing.T) {
+	_, err := parseVersionHeader([]byte("npu-smi: command not found\n"))
+	assert.Error(t, err)
+}
+
+func TestTopology(t *testing.T) {
+	topo, _ := testdataFS.ReadFile("testdata/npu-smi-topo.txt")
+	fake := gpu.NewScriptedExecutor().Stdout(topo, "npu-smi", "info", "-t", "topo")
+	cmd := New()
+	cmd.SetExecutor(fake)
+
+	topology, err := cmd.Topology(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, topology.Devices)
+	assert.Equal(t, gpu.Link{Type: gpu.LinkHCCS, Bandwidth: gpu.BandwidthFabric}, topology.Link(1, 3))
+	assert.Equal(t, gpu.Link{Type: gpu.LinkSys, Bandwidth: gpu.BandwidthSystem}, topology.Link(3, 4))
+
+	_, err = (&npuSMICommand{executor: gpu.NewScriptedExecutor()}).Topology(context.Background())
+	assert.Error(t, err)
+}
+
+var (
+	_ gpu.ProcessLister       = &npuSMICommand{}
+	_ gpu.ContextDriverGetter = &npuSMICommand{}
+	_ gpu.TopologyReporter    = &npuSMICommand{}
+)
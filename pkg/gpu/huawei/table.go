@@ -0,0 +1,185 @@
+package huawei
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+// infoTable is the parsed form of the summary table printed by "npu-smi info".
+type infoTable struct {
+	Chips     []tableChip
+	Processes []tableProcess
+}
+
+// tableChip is one NPU/Chip row pair of the summary table. Memory readings
+// come from HBM-Usage when the table has that column (Atlas 800/900 with
+// 910 chips), otherwise from Memory-Usage.
+type tableChip struct {
+	NPUID       string
+	Name        string // chip name, e.g. "310P3" or "910B3"
+	Health      string
+	Power       string
+	Temperature string
+	ChipID      string
+	Device      string // logical device ID; empty on layouts without the column
+	BusID       string
+	AICore      string
+	MemoryUsed  gpu.UintMetric // bytes
+	MemoryTotal gpu.UintMetric // bytes
+}
+
+// tableProcess is one row of the process section of the summary table.
+type tableProcess struct {
+	NPUID  string
+	ChipID string
+	PID    int
+	Name   string
+	Memory gpu.UintMetric // bytes
+}
+
+// usagePair matches the "used / total" columns of the summary table.
+var usagePair = regexp.MustCompile(`(\S+)\s*/\s*(\S+)`)
+
+// parseInfoTable parses the chip and process sections of "npu-smi info".
+// Rows it does not recognise, such as the banner and separators, are skipped.
+func parseInfoTable(output []byte) *infoTable {
+	table := &infoTable{}
+	inProcesses := false
+	var npuRow *tableChip
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		if strings.Contains(line, "Process id") {
+			inProcesses = true
+			continue
+		}
+
+		cells := strings.Split(strings.Trim(line, "|"), "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		first := strings.Fields(cells[0])
+		if len(first) == 0 || !isAllDigits(first[0]) {
+			continue
+		}
+
+		if inProcesses {
+			if p, ok := parseProcessRow(first, cells); ok {
+				table.Processes = append(table.Processes, p)
+			}
+			continue
+		}
+		if len(cells) != 3 {
+			continue
+		}
+		if len(first) >= 2 && !isAllDigits(first[1]) {
+			npuRow = parseNPURow(first, cells)
+			continue
+		}
+		if npuRow != nil {
+			table.Chips = append(table.Chips, parseChipRow(*npuRow, first, cells))
+		}
+	}
+	return table
+}
+
+// versionHeader matches the banner of "npu-smi info", e.g.
+// "| npu-smi 25.5.1          Version: 25.5.1          |".
+var versionHeader = regexp.MustCompile(`npu-smi\s+(\S+)\s+Version:\s*(\S+)`)
+
+// parseVersionHeader returns the npu-smi version as ClientVersion and the
+// driver version as Version.
+func parseVersionHeader(output []byte) (gpu.GPUDriverInfo, error) {
+	m := versionHeader.FindSubmatch(output)
+	if m == nil {
+		return gpu.GPUDriverInfo{}, fmt.Errorf("npu-smi info has no version header")
+	}
+	return gpu.GPUDriverInfo{ClientVersion: string(m[1]), Version: string(m[2])}, nil
+}
+
+// parseNPURow parses e.g. "| 2944    310P3 | OK | NA    45    0 / 0 |".
+func parseNPURow(first, cells []string) *tableChip {
+	row := &tableChip{NPUID: first[0], Name: first[1], Health: cells[1]}
+	readings := strings.Fields(cells[2])
+	if len(readings) > 0 {
+		row.Power = readings[0]
+	}
+	if len(readings) > 1 {
+		row.Temperature = readings[1]
+	}
+	return row
+}
+
+// parseChipRow parses e.g. "| 0    0 | 0000:0C:00.0 | 0    1255 / 44278 |"
+// into a copy of the NPU row above it.
+func parseChipRow(chip tableChip, first, cells []string) tableChip {
+	chip.ChipID = first[0]
+	if len(first) > 1 {
+		chip.Device = first[1]
+	}
+	chip.BusID = cells[1]
+	if readings := strings.Fields(cells[2]); len(readings) > 0 {
+		chip.AICore = readings[0]
+	}
+
+	pairs := usagePair.FindAllStringSubmatch(cells[2], -1)
+	if len(pairs) > 0 {
+		usage := pairs[0]
+		// HBM-Usage follows Memory-Usage on 910 boards, where Memory-Usage is 0 / 0.
+		if len(pairs) > 1 {
+			usage = pairs[1]
+		}
+		chip.MemoryUsed = parseMiB(usage[1])
+		chip.MemoryTotal = parseMiB(usage[2])
+	}
+	return chip
+}
+
+// parseProcessRow parses e.g. "| 0    0 | 1234567 | python3 | 60123 |".
+func parseProcessRow(first, cells []string) (tableProcess, bool) {
+	if len(first) < 2 || len(cells) < 4 {
+		return tableProcess{}, false
+	}
+	pid, err := strconv.Atoi(cells[1])
+	if err != nil {
+		return tableProcess{}, false
+	}
+	return tableProcess{
+		NPUID:  first[0],
+		ChipID: first[1],
+		PID:    pid,
+		Name:   cells[2],
+		Memory: parseMiB(cells[3]),
+	}, true
+}
+
+func parseMiB(s string) gpu.UintMetric {
+	m := gpu.ParseUintMetric(s)
+	if m.Available() {
+		m.Value *= 1024 * 1024
+	}
+	return m
+}
+
+// npuIDs returns the distinct NPU IDs of the table in order of appearance.
+func (t *infoTable) npuIDs() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, chip := range t.Chips {
+		if !seen[chip.NPUID] {
+			seen[chip.NPUID] = true
+			ids = append(ids, chip.NPUID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,83 @@
+package gpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StableID returns an identifier for the physical device described by info
+// that survives reboots and reindexing, unlike Num and DeviceID. In order of
+// preference it is:
+//
+//   - the UUID the vendor tool reports, e.g. "GPU-4a6c3d5e-...", as used by
+//     CUDA_VISIBLE_DEVICES and the container runtimes;
+//   - "<vendor>-sn-<serial>" from the serial number, which is only unique
+//     per vendor;
+//   - "<vendor>-pci-<address>" from the PCI address, which is only unique
+//     within a host and changes if the card is moved to another slot.
+//
+// vendor is the Vendor() of the loader that reported info. StableID returns
+// "" if info has none of the three.
+func StableID(vendor string, info GPUInfo) string {
+	if id := reported(info.UUID); id != "" {
+		return id
+	}
+	if serial := reported(info.SerialNumber); serial != "" {
+		return fmt.Sprintf("%s-sn-%s", strings.ToLower(vendor), serial)
+	}
+	return pciStableID(vendor, info)
+}
+
+func pciStableID(vendor string, info GPUInfo) string {
+	address := reported(info.PCIBus)
+	if address == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-pci-%s", strings.ToLower(vendor), CanonicalPCIAddress(address))
+}
+
+// reported returns value unless it is empty or a not-supported marker.
+func reported(value string) string {
+	value = strings.TrimSpace(value)
+	if IsNotSupported(value) {
+		return ""
+	}
+	return value
+}
+
+// assignStableIDs sets the ID of every GPU. Cards with several chips, such
+// as the Atlas 300I Duo, report one serial number for all of them; GPUs
+// whose serial-based IDs collide are told apart by PCI address instead.
+// The chips of some cards, such as the 310P3, also share one PCI address;
+// those get their device ID appended, e.g. "huawei-pci-0000:0c:00.0-1".
+func assignStableIDs(gpus []DetectedGPU) {
+	for i := range gpus {
+		gpus[i].ID = StableID(gpus[i].Vendor, gpus[i].GPUInfo)
+	}
+	count := countIDs(gpus)
+	for i := range gpus {
+		g := &gpus[i]
+		if count[g.ID] > 1 && reported(g.UUID) == "" {
+			if id := pciStableID(g.Vendor, g.GPUInfo); id != "" {
+				g.ID = id
+			}
+		}
+	}
+	count = countIDs(gpus)
+	for i := range gpus {
+		g := &gpus[i]
+		if count[g.ID] > 1 && reported(g.UUID) == "" {
+			if device := reported(g.DeviceID); device != "" {
+				g.ID = fmt.Sprintf("%s-%s", g.ID, device)
+			}
+		}
+	}
+}
+
+func countIDs(gpus []DetectedGPU) map[string]int {
+	count := make(map[string]int, len(gpus))
+	for _, g := range gpus {
+		count[g.ID]++
+	}
+	return count
+}
@@ -0,0 +1,63 @@
+package gpu
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStableID(t *testing.T) {
+	tests := []struct {
+		name   string
+		vendor string
+		info   GPUInfo
+		want   string
+	}{
+		{"uuid", "NVIDIA", GPUInfo{UUID: "GPU-4a6c3d5e-8f1b-2c7d-9e0a-1b2c3d4e5f60", SerialNumber: "1323021010463"}, "GPU-4a6c3d5e-8f1b-2c7d-9e0a-1b2c3d4e5f60"},
+		{"serial", "Huawei", GPUInfo{UUID: "N/A", SerialNumber: "033VNY10M4000236", PCIBus: "0000:C1:00.0"}, "huawei-sn-033VNY10M4000236"},
+		{"pci", "Enflame", GPUInfo{SerialNumber: " ", PCIBus: "0000:0C:00.0"}, "enflame-pci-0000:0c:00.0"},
+		{"long pci domain", "NVIDIA", GPUInfo{PCIBus: "00000000:1D:00.0"}, "nvidia-pci-0000:1d:00.0"},
+		{"nothing", "CPU", GPUInfo{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, StableID(tt.vendor, tt.info))
+		})
+	}
+}
+
+func TestDetectAllAssignsStableIDs(t *testing.T) {
+	// Both chips of an Atlas 300I Duo report the board's serial number.
+	duo := &GPUInfoList{GPUInfos: []GPUInfo{
+		{Num: 0, SerialNumber: "033VNY10M4000236", PCIBus: "0000:81:00.0"},
+		{Num: 1, SerialNumber: "033VNY10M4000236", PCIBus: "0000:82:00.0"},
+		{Num: 2, SerialNumber: "033VNY10M4000999", PCIBus: "0000:C1:00.0"},
+	}}
+	nvidia := &GPUInfoList{GPUInfos: []GPUInfo{{UUID: "GPU-9b8a7c6d-5e4f-3a2b-1c0d-ef9876543210"}}}
+	inv := DetectAll(context.Background(), WithPCIEnumerator(nil), WithLoaders(
+		&stubLoader{vendor: "Huawei", available: true, list: duo},
+		&stubLoader{vendor: "NVIDIA", available: true, list: nvidia},
+	))
+
+	var ids []string
+	for _, g := range inv.GPUs {
+		ids = append(ids, g.ID)
+	}
+	assert.Equal(t, []string{
+		"huawei-pci-0000:81:00.0",
+		"huawei-pci-0000:82:00.0",
+		"huawei-sn-033VNY10M4000999",
+		"GPU-9b8a7c6d-5e4f-3a2b-1c0d-ef9876543210",
+	}, ids)
+}
+
+func TestStableIDsOfChipsSharingPCIAddress(t *testing.T) {
+	gpus := []DetectedGPU{
+		{Vendor: "Huawei", GPUInfo: GPUInfo{Num: 0, DeviceID: "0", SerialNumber: "033VNY10M4000236", PCIBus: "0000:0C:00.0"}},
+		{Vendor: "Huawei", GPUInfo: GPUInfo{Num: 1, DeviceID: "1", SerialNumber: "033VNY10M4000236", PCIBus: "0000:0C:00.0"}},
+	}
+	assignStableIDs(gpus)
+	assert.Equal(t, "huawei-pci-0000:0c:00.0-0", gpus[0].ID)
+	assert.Equal(t, "huawei-pci-0000:0c:00.0-1", gpus[1].ID)
+}
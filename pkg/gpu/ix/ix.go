@@ -1,11 +1,11 @@
 package ix
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"sort"
@@ -27,7 +27,8 @@ const (
 var logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 var smiPaths = []string{}
 
-// scanCorexSmiPaths 扫描指定目录下所有以 corex 开头的文件夹，并返回对应的 ixsmi 路径
+// scanCorexSmiPaths 扫描指定目录下所有以 corex 开头的文件夹，并返回对应的 ixsmi 路径。
+// ixsmi 是否存在由 autoFindSmiPath 通过 Executor 确认
 func scanCorexSmiPaths(rootDir string) []string {
 	var paths []string
 
@@ -42,10 +43,8 @@ func scanCorexSmiPaths(rootDir string) []string {
 		logger.Info("scanCorexSmiPaths: checking dir", "name", d.Name(), "isDir", d.IsDir())
 		if d.IsDir() && strings.HasPrefix(d.Name(), "corex") {
 			smiPath := filepath.Join(rootDir, d.Name(), "bin", "ixsmi")
-			logger.Info("scanCorexSmiPaths: checking smiPath", "smiPath", smiPath)
-			if _, err := os.Stat(smiPath); err == nil {
-				paths = append(paths, smiPath)
-			}
+			logger.Info("scanCorexSmiPaths: found smiPath", "smiPath", smiPath)
+			paths = append(paths, smiPath)
 		}
 	}
 
@@ -54,47 +53,84 @@ func scanCorexSmiPaths(rootDir string) []string {
 	return paths
 }
 
-func autoFindSmiPath() string {
+// autoFindSmiPath 通过 executor 查找 corex 安装目录下的 ixsmi，
+// 这样 ScriptedExecutor 和 ReplayExecutor 在没有 ixsmi 的机器上也能驱动加载器
+func autoFindSmiPath(executor gpu.Executor) string {
 	for _, path := range smiPaths {
-		if _, err := os.Stat(path); err == nil {
-			return path
+		if found, err := executor.LookPath(path); err == nil {
+			return found
 		}
 	}
 	return ""
 }
 
-// setupIxsmmiEnv 设置 ixsmi 所需的环境变量
-func setupIxsmmiEnv(smiPath string) {
-	smiDir := strings.TrimSuffix(smiPath, "/bin/ixsmi")
-	ixsmiLibPath := fmt.Sprintf("%s/lib:%s/lib64", smiDir, smiDir)
+// corexEnv 返回在 corex 安装目录 corexDir 下运行 ixsmi 所需的环境变量：
+// 在 environ 的 PATH 和 LD_LIBRARY_PATH 前加上 corex 的 bin 和 lib 目录。
+// 不修改进程本身的环境变量，多个加载器并发运行时互不影响
+func corexEnv(environ []string, corexDir string) []string {
+	prepend := map[string]string{
+		"PATH":            corexDir + "/bin",
+		"LD_LIBRARY_PATH": fmt.Sprintf("%s/lib:%s/lib64", corexDir, corexDir),
+	}
+	env := make([]string, 0, len(environ)+len(prepend))
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		if dirs, ok := prepend[key]; ok {
+			delete(prepend, key)
+			if value != "" {
+				dirs += ":" + value
+			}
+			kv = key + "=" + dirs
+		}
+		env = append(env, kv)
+	}
+	for _, key := range []string{"PATH", "LD_LIBRARY_PATH"} {
+		if dirs, ok := prepend[key]; ok {
+			env = append(env, key+"="+dirs)
+		}
+	}
+	return env
+}
 
-	oldPath := os.Getenv("PATH")
-	os.Setenv("PATH", smiDir+"/bin:"+oldPath)
+type ixGPU struct {
+	executor gpu.Executor
+}
 
-	oldLibPath := os.Getenv("LD_LIBRARY_PATH")
-	os.Setenv("LD_LIBRARY_PATH", ixsmiLibPath+":"+oldLibPath)
+func (a *ixGPU) SetExecutor(e gpu.Executor) {
+	a.executor = e
+}
 
-	logger.Info("ixGPU env", "PATH", os.Getenv("PATH"), "LD_LIBRARY_PATH", os.Getenv("LD_LIBRARY_PATH"))
+// findSmiPath 优先使用 corex 安装目录下的 ixsmi，找不到时再从 PATH 中查找
+func (a *ixGPU) findSmiPath() string {
+	cmd, _ := a.smiCommand()
+	return cmd.Path
 }
 
-type ixGPU struct {
+// smiCommand 返回运行 ixsmi 的命令。corex 安装目录下的 ixsmi 带上 corexEnv
+// 给出的环境变量；PATH 中找到的 ixsmi 已能直接运行，沿用当前环境
+func (a *ixGPU) smiCommand(args ...string) (gpu.Command, bool) {
+	executor := gpu.ExecutorOrDefault(a.executor)
+	if smiPath := autoFindSmiPath(executor); smiPath != "" {
+		corexDir := strings.TrimSuffix(smiPath, "/bin/ixsmi")
+		return gpu.Command{Path: smiPath, Args: args, Env: corexEnv(os.Environ(), corexDir)}, true
+	}
+	if smiPath, err := executor.LookPath("ixsmi"); err == nil {
+		return gpu.Command{Path: smiPath, Args: args}, true
+	}
+	return gpu.Command{}, false
 }
 
 func (a *ixGPU) Load() (*gpu.GPUInfoList, error) {
-	smiPath := autoFindSmiPath()
-	if smiPath == "" {
-		logger.Error("ixGPU Load autoFindSmiPath", "smiPath", smiPath, "error", fmt.Errorf("ixsmi not found"))
-		return nil, fmt.Errorf("ixsmi not found")
-	}
-	setupIxsmmiEnv(smiPath)
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.LoadContext(ctx)
+}
 
-	cmd := exec.Command(smiPath, "-q", "-x")
-	data, err := cmd.Output()
+func (a *ixGPU) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	data, err := a.query(ctx)
 	if err != nil {
-		logger.Error("ixGPU Load get data", "cmd", cmd.String(), "error", err)
 		return nil, err
 	}
-	logger.Info("ixGPU Load get data success")
 
 	result, err := ParseIXSMI(string(data))
 	if err != nil {
@@ -105,33 +141,138 @@ func (a *ixGPU) Load() (*gpu.GPUInfoList, error) {
 	return result, nil
 }
 
+// query 执行 ixsmi -q -x 并返回 XML 输出
+func (a *ixGPU) query(ctx context.Context) ([]byte, error) {
+	cmd, ok := a.smiCommand("-q", "-x")
+	if !ok {
+		logger.Error("ixGPU Load autoFindSmiPath", "error", fmt.Errorf("ixsmi not found"))
+		return nil, fmt.Errorf("ixsmi not found")
+	}
+
+	result, err := gpu.ExecutorOrDefault(a.executor).Run(ctx, cmd)
+	if err != nil {
+		logger.Error("ixGPU Load get data", "cmd", cmd.String(), "error", err)
+		return nil, err
+	}
+	logger.Info("ixGPU Load get data success")
+	return result.Stdout, nil
+}
+
+// Processes 列出占用各 GPU 显存的进程
+func (a *ixGPU) Processes(ctx context.Context) ([]gpu.GPUProcess, error) {
+	data, err := a.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIXSMIProcesses(string(data))
+}
+
+// ParseIXSMIProcesses 解析 ixsmi -q -x 输出中每个 GPU 的 processes 段，
+// DeviceIndex 与 ParseIXSMI 返回的 Num 一致
+func ParseIXSMIProcesses(data string) ([]gpu.GPUProcess, error) {
+	type ProcessInfo struct {
+		PID        int    `xml:"pid"`
+		Name       string `xml:"process_name"`
+		UsedMemory string `xml:"used_memory"`
+	}
+	type GPU struct {
+		Processes []ProcessInfo `xml:"processes>process_info"`
+	}
+	type IXSMILog struct {
+		XMLName xml.Name `xml:"ixsmi_log"`
+		GPUs    []GPU    `xml:"gpu"`
+	}
+
+	var log IXSMILog
+	if err := xml.Unmarshal([]byte(data), &log); err != nil {
+		return nil, err
+	}
+
+	processes := []gpu.GPUProcess{}
+	for i, g := range log.GPUs {
+		for _, p := range g.Processes {
+			processes = append(processes, gpu.GPUProcess{
+				PID:         p.PID,
+				Name:        p.Name,
+				DeviceIndex: i,
+				MemoryUsed:  parseMiBMetric(p.UsedMemory),
+			})
+		}
+	}
+	return processes, nil
+}
+
+func (a *ixGPU) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.DriverInfoContext(ctx)
+}
+
+func (a *ixGPU) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	data, err := a.query(ctx)
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	info, err := ParseIXSMIVersion(string(data))
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	info.Vendor = a.Vendor()
+	info.Installed = true
+	info.InstallPath = a.findSmiPath()
+	return info, nil
+}
+
+// ParseIXSMIVersion 解析 ixsmi -q -x 输出中的 driver_version 和 cuda_version
+func ParseIXSMIVersion(data string) (gpu.GPUDriverInfo, error) {
+	type IXSMILog struct {
+		XMLName       xml.Name `xml:"ixsmi_log"`
+		DriverVersion string   `xml:"driver_version"`
+		CUDAVersion   string   `xml:"cuda_version"`
+	}
+
+	var log IXSMILog
+	if err := xml.Unmarshal([]byte(data), &log); err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	if log.DriverVersion == "" {
+		return gpu.GPUDriverInfo{}, fmt.Errorf("ixsmi output has no driver_version")
+	}
+	return gpu.GPUDriverInfo{
+		Version:    strings.TrimSpace(log.DriverVersion),
+		LibVersion: strings.TrimSpace(log.CUDAVersion),
+	}, nil
+}
+
 func (a *ixGPU) Vendor() string {
 	return "Iluvatar"
 }
 
 func (a *ixGPU) Available() bool {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return a.AvailableContext(ctx)
+}
+
+func (a *ixGPU) AvailableContext(ctx context.Context) bool {
 	if runtime.GOOS != "linux" {
 		logger.Info("ixGPU Available check os", "os", runtime.GOOS, "arch", runtime.GOARCH, "return", false)
 		return false
 	}
-	smiPath := autoFindSmiPath()
-	if smiPath == "" {
+	cmd, ok := a.smiCommand("-q", "-x")
+	if !ok {
 		return false
 	}
-	logger.Info("ixGPU Available", "smiPath", smiPath)
-	setupIxsmmiEnv(smiPath)
+	logger.Info("ixGPU Available", "smiPath", cmd.Path)
 
-	_, err := exec.LookPath(smiPath)
+	executor := gpu.ExecutorOrDefault(a.executor)
+	_, err := executor.LookPath(cmd.Path)
 	if err != nil {
-		logger.Error("ixGPU lookpath ixsmi", "smiPath", smiPath, "error", err)
-		return false
-	}
-	cmd := exec.Command(smiPath, "-q", "-x")
-	if err := cmd.Run(); err != nil {
-		logger.Error("ixGPU test ixsmi", "cmd", cmd.String(), "error", err)
+		logger.Error("ixGPU lookpath ixsmi", "smiPath", cmd.Path, "error", err)
 		return false
 	}
-	logger.Info("ixGPU Available success", "cmd", cmd.String(), "return", true)
+	// 只查找 ixsmi，不运行完整查询；ixsmi 无法运行时由 Load 报错
+	logger.Info("ixGPU Available success", "smiPath", cmd.Path, "return", true)
 	return true
 }
 
@@ -147,6 +288,10 @@ func ParseIXSMI(data string) (*gpu.GPUInfoList, error) {
 	type Temperature struct {
 		GPUTemp string `xml:"gpu_temp"`
 	}
+	type PowerReadings struct {
+		GPUPowerDraw         string `xml:"gpu_power_draw"`
+		CurrentGPUPowerLimit string `xml:"current_gpu_power_limit"`
+	}
 	type PCI struct {
 		Bus         string `xml:"pci_bus"`
 		Device      string `xml:"pci_device"`
@@ -155,15 +300,35 @@ func ParseIXSMI(data string) (*gpu.GPUInfoList, error) {
 		BusID       string `xml:"pci_bus_id"`
 		SubSystemID string `xml:"pci_sub_system_id"`
 	}
+	type Clocks struct {
+		SM     string `xml:"sm_clock"`
+		Memory string `xml:"mem_clock"`
+	}
+	type ECCMode struct {
+		Current string `xml:"current_ecc"`
+		Pending string `xml:"pending_ecc"`
+	}
+	type ECCErrors struct {
+		SingleBit string `xml:"single_bit"`
+		DoubleBit string `xml:"double_bit"`
+	}
 	type GPU struct {
-		ID      string      `xml:"id,attr"`
-		Product string      `xml:"product_name"`
-		Serial  string      `xml:"serial"`
-		Minor   string      `xml:"minor_number"`
-		Memory  MemoryUsage `xml:"memory_usage"`
-		Util    Utilization `xml:"utilization"`
-		Temp    Temperature `xml:"temperature"`
-		PCI     PCI         `xml:"pci"`
+		ID        string        `xml:"id,attr"`
+		Product   string        `xml:"product_name"`
+		Serial    string        `xml:"serial"`
+		UUID      string        `xml:"uuid"`
+		Minor     string        `xml:"minor_number"`
+		Fan       string        `xml:"fan_speed"`
+		Memory    MemoryUsage   `xml:"memory_usage"`
+		Util      Utilization   `xml:"utilization"`
+		Temp      Temperature   `xml:"temperature"`
+		Power     PowerReadings `xml:"power_readings"`
+		PCI       PCI           `xml:"pci"`
+		Clocks    Clocks        `xml:"clocks"`
+		MaxClocks Clocks        `xml:"max_clocks"`
+		PState    string        `xml:"performance_state"`
+		ECCMode   ECCMode       `xml:"ecc_mode"`
+		ECC       ECCErrors     `xml:"ecc_errors"`
 	}
 
 	type IXSMILog struct {
@@ -182,18 +347,28 @@ func ParseIXSMI(data string) (*gpu.GPUInfoList, error) {
 		memUsed := parseMiBToBytes(g.Memory.Used)
 		temp := parseTempC(g.Temp.GPUTemp)
 		gpuUse := parsePercent(g.Util.GPUUtil)
-		var pcibus string
-		if g.PCI.BusID != "" {
-			buf := strings.Split(g.PCI.BusID, ":")
-			if len(buf) > 0 {
-				buf[0] = g.PCI.Domain
+		power := gpu.ParseFloatMetric(g.Power.GPUPowerDraw)
+		pcibus := gpu.CanonicalPCIAddress(g.PCI.BusID)
+		pstate := strings.TrimSpace(g.PState)
+		if gpu.IsNotSupported(pstate) {
+			pstate = ""
+		}
+		// ixsmi 只给出单比特（可纠正）/双比特（不可纠正）错误总数，没有累计值和页面退役信息
+		var ecc *gpu.ECCInfo
+		mode := gpu.ParseMode(g.ECCMode.Current)
+		single, double := gpu.ParseUintMetric(g.ECC.SingleBit), gpu.ParseUintMetric(g.ECC.DoubleBit)
+		if mode != "" || single.Available() || double.Available() {
+			ecc = &gpu.ECCInfo{
+				Mode:        mode,
+				PendingMode: gpu.ParseMode(g.ECCMode.Pending),
+				Volatile:    gpu.ECCCounts{Corrected: single, Uncorrected: double},
 			}
-			pcibus = strings.Join(buf, ":")
 		}
 		infos = append(infos, gpu.GPUInfo{
 			Num:                 i,
 			DeviceID:            g.ID,
 			SerialNumber:        g.Serial,
+			UUID:                strings.TrimSpace(g.UUID),
 			VRAMTotalMemory:     memTotal,
 			VRAMTotalUsedMemory: memUsed,
 			TemperatureEdge:     temp,
@@ -204,25 +379,63 @@ func ParseIXSMI(data string) (*gpu.GPUInfoList, error) {
 			CardModel:           g.Product,
 			CardVendor:          "Iluvatar",
 			PCIBus:              pcibus,
+			// ixsmi 的 gpu_power_draw 为整数瓦特，如 "37 W"
+			AverageGraphicsPackagePower: power.String(),
+			Metrics: gpu.GPUMetrics{
+				// ixsmi 只有一个 GPU 温度
+				TemperatureEdge:             gpu.ParseFloatMetric(g.Temp.GPUTemp),
+				TemperatureJunction:         gpu.UnsupportedFloat(),
+				TemperatureMemory:           gpu.UnsupportedFloat(),
+				AverageGraphicsPackagePower: power,
+				PowerLimit:                  gpu.ParseFloatMetric(g.Power.CurrentGPUPowerLimit),
+				FanSpeed:                    gpu.ParseFloatMetric(g.Fan),
+				GPUUse:                      gpu.ParseFloatMetric(g.Util.GPUUtil),
+				VRAMTotalMemory:             parseMiBMetric(g.Memory.Total),
+				VRAMTotalUsedMemory:         parseMiBMetric(g.Memory.Used),
+			},
+			// ixsmi 没有单独的图形时钟，sm_clock 即计算核心频率
+			Clocks: &gpu.Clocks{
+				Graphics:    gpu.ParseFloatMetric(g.Clocks.SM),
+				Memory:      gpu.ParseFloatMetric(g.Clocks.Memory),
+				MaxGraphics: gpu.ParseFloatMetric(g.MaxClocks.SM),
+				MaxMemory:   gpu.ParseFloatMetric(g.MaxClocks.Memory),
+			},
+			PState: pstate,
+			ECC:    ecc,
 		})
+		infos[i].Health = gpu.CheckHealth(infos[i])
 	}
 	return &gpu.GPUInfoList{GPUInfos: infos}, nil
 }
 
 func parseMiBToBytes(s string) string {
-	var v float64
-	fmt.Sscanf(s, "%f", &v)
-	return fmt.Sprintf("%.0f", v*1024*1024)
+	m := gpu.ParseFloatMetric(s)
+	if !m.Available() {
+		return gpu.NotAvailable
+	}
+	return fmt.Sprintf("%.0f", m.Value*1024*1024)
+}
+
+// parseMiBMetric converts a "32768 MiB" reading into bytes.
+func parseMiBMetric(s string) gpu.UintMetric {
+	m := gpu.ParseFloatMetric(s)
+	if !m.Available() {
+		return gpu.UintMetric{Status: m.Status}
+	}
+	return gpu.Uint(uint64(m.Value * 1024 * 1024))
 }
 
 func parseTempC(s string) string {
-	var v int
-	fmt.Sscanf(s, "%d", &v)
-	return fmt.Sprintf("%d", v)
+	return formatInt(gpu.ParseFloatMetric(s))
 }
 
 func parsePercent(s string) string {
-	var v int
-	fmt.Sscanf(s, "%d", &v)
-	return fmt.Sprintf("%d", v)
+	return formatInt(gpu.ParseFloatMetric(s))
+}
+
+func formatInt(m gpu.FloatMetric) string {
+	if !m.Available() {
+		return gpu.NotAvailable
+	}
+	return fmt.Sprintf("%d", int(m.Value))
 }
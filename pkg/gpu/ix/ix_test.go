@@ -1,75 +1,98 @@
 package ix
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 )
 
-func TestSetupIxsmmiEnv(t *testing.T) {
-	// 创建临时目录结构
-	tmpDir := t.TempDir()
-	binDir := filepath.Join(tmpDir, "bin")
-	if err := os.MkdirAll(binDir, 0755); err != nil {
-		t.Fatalf("failed to create bin dir: %v", err)
+func TestCorexEnv(t *testing.T) {
+	env := corexEnv([]string{"HOME=/root", "PATH=/usr/bin", "LD_LIBRARY_PATH=/usr/lib"}, "/usr/local/corex-4.4.0")
+	want := []string{
+		"HOME=/root",
+		"PATH=/usr/local/corex-4.4.0/bin:/usr/bin",
+		"LD_LIBRARY_PATH=/usr/local/corex-4.4.0/lib:/usr/local/corex-4.4.0/lib64:/usr/lib",
+	}
+	if strings.Join(env, "\n") != strings.Join(want, "\n") {
+		t.Errorf("corexEnv = %v, want %v", env, want)
 	}
-	// 创建假的 ixsmi 文件
-	ixsmiPath := filepath.Join(binDir, "ixsmi")
-	if err := os.WriteFile(ixsmiPath, []byte("#!/bin/bash"), 0755); err != nil {
-		t.Fatalf("failed to create ixsmi: %v", err)
+
+	// 原环境中没有的变量直接添加
+	env = corexEnv([]string{"HOME=/root"}, "/usr/local/corex-4.4.0")
+	want = []string{"HOME=/root", "PATH=/usr/local/corex-4.4.0/bin", "LD_LIBRARY_PATH=/usr/local/corex-4.4.0/lib:/usr/local/corex-4.4.0/lib64"}
+	if strings.Join(env, "\n") != strings.Join(want, "\n") {
+		t.Errorf("corexEnv = %v, want %v", env, want)
 	}
+}
 
-	// 保存原始环境变量
-	origPath := os.Getenv("PATH")
-	origLibPath := os.Getenv("LD_LIBRARY_PATH")
-	defer func() {
-		os.Setenv("PATH", origPath)
-		os.Setenv("LD_LIBRARY_PATH", origLibPath)
-	}()
+func TestLoadFromCorexDir(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ixsmi.xml"))
+	if err != nil {
+		t.Fatalf("failed to read xml: %v", err)
+	}
+	// ixsmi 只存在于 ScriptedExecutor 中，本机无需安装
+	corexDir := "/usr/local/corex-4.2.0"
+	smiPath := filepath.Join(corexDir, "bin", "ixsmi")
+	oldPaths := smiPaths
+	smiPaths = []string{smiPath}
+	defer func() { smiPaths = oldPaths }()
 
-	// 调用 setupIxsmmiEnv
-	setupIxsmmiEnv(ixsmiPath)
+	fake := gpu.NewScriptedExecutor().
+		Path(smiPath, smiPath).
+		Stdout(data, smiPath, "-q", "-x")
+	a := &ixGPU{executor: fake}
+	oldPath := os.Getenv("PATH")
 
-	// 验证 PATH
-	newPath := os.Getenv("PATH")
-	if !strings.Contains(newPath, binDir) {
-		t.Errorf("PATH should contain %s, got %s", binDir, newPath)
+	if !a.Available() {
+		t.Fatal("expected ixsmi in the corex dir to be available")
 	}
-	if !strings.HasPrefix(newPath, binDir) {
-		t.Errorf("PATH should start with %s, got %s", binDir, newPath)
+	if _, err := a.Load(); err != nil {
+		t.Fatalf("Load error: %v", err)
 	}
-
-	// 验证 LD_LIBRARY_PATH
-	newLibPath := os.Getenv("LD_LIBRARY_PATH")
-	expectedLibDir := filepath.Join(tmpDir, "lib")
-	expectedLib64Dir := filepath.Join(tmpDir, "lib64")
-	if !strings.Contains(newLibPath, expectedLibDir) {
-		t.Errorf("LD_LIBRARY_PATH should contain %s, got %s", expectedLibDir, newLibPath)
+	// 环境变量只传给 ixsmi，不修改进程本身的环境
+	if os.Getenv("PATH") != oldPath {
+		t.Errorf("PATH changed to %s", os.Getenv("PATH"))
+	}
+	calls := fake.Calls()
+	found := false
+	for _, kv := range calls[len(calls)-1].Env {
+		if kv == "PATH="+filepath.Join(corexDir, "bin")+":"+oldPath {
+			found = true
+		}
 	}
-	if !strings.Contains(newLibPath, expectedLib64Dir) {
-		t.Errorf("LD_LIBRARY_PATH should contain %s, got %s", expectedLib64Dir, newLibPath)
+	if !found {
+		t.Errorf("ixsmi env has no corex PATH: %v", calls[len(calls)-1].Env)
 	}
 }
 
-func TestSetupIxsmmiEnvPreservesOldValues(t *testing.T) {
-	// 设置已有的环境变量
-	os.Setenv("PATH", "/usr/bin")
-	os.Setenv("LD_LIBRARY_PATH", "/usr/lib")
-
-	ixsmiPath := "/usr/local/corex-4.4.0/bin/ixsmi"
-
-	setupIxsmmiEnv(ixsmiPath)
-
-	// 验证原有值被保留
-	newPath := os.Getenv("PATH")
-	if !strings.Contains(newPath, "/usr/bin") {
-		t.Errorf("PATH should preserve old value /usr/bin, got %s", newPath)
+func TestParseIXSMIMissingValues(t *testing.T) {
+	data := `<ixsmi_log><gpu id="00000000:0C:00.0">
+		<memory_usage><total>N/A</total></memory_usage>
+		<utilization><gpu_util>N/A</gpu_util></utilization>
+	</gpu></ixsmi_log>`
+	info, err := ParseIXSMI(data)
+	if err != nil {
+		t.Fatalf("parseIXSMI error: %v", err)
 	}
-
-	newLibPath := os.Getenv("LD_LIBRARY_PATH")
-	if !strings.Contains(newLibPath, "/usr/lib") {
-		t.Errorf("LD_LIBRARY_PATH should preserve old value /usr/lib, got %s", newLibPath)
+	g := info.GPUInfos[0]
+	if g.VRAMTotalMemory != "N/A" || g.GPUUse != "N/A" || g.TemperatureEdge != "N/A" {
+		t.Errorf("expected N/A for missing values, got %s %s %s", g.VRAMTotalMemory, g.GPUUse, g.TemperatureEdge)
+	}
+	if g.Metrics.VRAMTotalMemory.Status != gpu.MetricUnsupported {
+		t.Errorf("VRAMTotalMemory status = %s", g.Metrics.VRAMTotalMemory.Status)
+	}
+	if g.Metrics.TemperatureEdge.Status != gpu.MetricUnavailable {
+		t.Errorf("TemperatureEdge status = %s", g.Metrics.TemperatureEdge.Status)
+	}
+	if g.ECC != nil {
+		t.Errorf("ECC = %+v", g.ECC)
+	}
+	if g.PState != "" || g.Clocks.Graphics.Status != gpu.MetricUnavailable {
+		t.Errorf("PState = %q, Clocks = %+v", g.PState, g.Clocks)
 	}
 }
 
@@ -93,6 +116,9 @@ func TestParseIXSMI(t *testing.T) {
 	if gpu0.SerialNumber != "23490256585496" {
 		t.Errorf("gpu0.SerialNumber = %s", gpu0.SerialNumber)
 	}
+	if gpu0.UUID != "GPU-1ac807aa-cbcd-5579-8591-d59d436d6eca" {
+		t.Errorf("gpu0.UUID = %s", gpu0.UUID)
+	}
 	if gpu0.VRAMTotalMemory != "34359738368" {
 		t.Errorf("gpu0.VRAMTotalMemory = %s", gpu0.VRAMTotalMemory)
 	}
@@ -105,9 +131,24 @@ func TestParseIXSMI(t *testing.T) {
 	if gpu0.GPUUse != "0" {
 		t.Errorf("gpu0.GPUUse = %s", gpu0.GPUUse)
 	}
-	if gpu0.PCIBus != "0000:0C:00.0" {
+	if gpu0.PCIBus != "0000:0c:00.0" {
 		t.Errorf("gpu0.PCIBus = %s", gpu0.PCIBus)
 	}
+	if gpu0.Metrics.VRAMTotalUsedMemory != gpu.Uint(29129441280) {
+		t.Errorf("gpu0.Metrics.VRAMTotalUsedMemory = %+v", gpu0.Metrics.VRAMTotalUsedMemory)
+	}
+	if gpu0.Metrics.TemperatureEdge != gpu.Float(44) {
+		t.Errorf("gpu0.Metrics.TemperatureEdge = %+v", gpu0.Metrics.TemperatureEdge)
+	}
+	if gpu0.Metrics.TemperatureJunction != gpu.UnsupportedFloat() || gpu0.Metrics.TemperatureMemory != gpu.UnsupportedFloat() {
+		t.Errorf("gpu0 junction/memory temperature = %+v %+v", gpu0.Metrics.TemperatureJunction, gpu0.Metrics.TemperatureMemory)
+	}
+	if gpu0.AverageGraphicsPackagePower != "37" {
+		t.Errorf("gpu0.AverageGraphicsPackagePower = %s", gpu0.AverageGraphicsPackagePower)
+	}
+	if gpu0.Metrics.AverageGraphicsPackagePower != gpu.Float(37) {
+		t.Errorf("gpu0.Metrics.AverageGraphicsPackagePower = %+v", gpu0.Metrics.AverageGraphicsPackagePower)
+	}
 	gpu1 := info.GPUInfos[1]
 	if gpu1.DeviceID != "00000000:0F:00.0" {
 		t.Errorf("gpu1.DeviceID = %s", gpu1.DeviceID)
@@ -127,7 +168,181 @@ func TestParseIXSMI(t *testing.T) {
 	if gpu1.GPUUse != "0" {
 		t.Errorf("gpu1.GPUUse = %s", gpu1.GPUUse)
 	}
-	if gpu1.PCIBus != "0000:0F:00.0" {
+	if gpu1.PCIBus != "0000:0f:00.0" {
 		t.Errorf("gpu1.PCIBus = %s", gpu1.PCIBus)
 	}
+	if gpu0.Health == nil || gpu0.Health.Status != gpu.HealthOK {
+		t.Errorf("gpu0.Health = %+v", gpu0.Health)
+	}
+	wantClocks := gpu.Clocks{Graphics: gpu.Float(1500), Memory: gpu.Float(1600), MaxGraphics: gpu.Float(1500), MaxMemory: gpu.Float(1600)}
+	if gpu0.Clocks == nil || *gpu0.Clocks != wantClocks {
+		t.Errorf("gpu0.Clocks = %+v", gpu0.Clocks)
+	}
+	if gpu0.PState != "P0" {
+		t.Errorf("gpu0.PState = %s", gpu0.PState)
+	}
+	wantECC := gpu.ECCInfo{Mode: "enabled", Volatile: gpu.ECCCounts{Corrected: gpu.Uint(0), Uncorrected: gpu.Uint(0)}}
+	if gpu0.ECC == nil || *gpu0.ECC != wantECC {
+		t.Errorf("gpu0.ECC = %+v", gpu0.ECC)
+	}
+}
+
+func TestParseIXSMIHealth(t *testing.T) {
+	data := `<ixsmi_log><gpu id="00000000:0C:00.0">
+		<ecc_errors><single_bit>12</single_bit><double_bit>1</double_bit></ecc_errors>
+	</gpu><gpu id="00000000:0F:00.0">
+		<ecc_errors><single_bit>12</single_bit><double_bit>0</double_bit></ecc_errors>
+	</gpu></ixsmi_log>`
+	info, err := ParseIXSMI(data)
+	if err != nil {
+		t.Fatalf("parseIXSMI error: %v", err)
+	}
+	h := info.GPUInfos[0].Health
+	if h.Status != gpu.HealthCritical || len(h.Reasons) != 1 || h.Reasons[0] != gpu.ReasonECCUncorrectable {
+		t.Errorf("gpu0.Health = %+v", h)
+	}
+	// 可纠正的单比特错误不影响健康状态
+	if h := info.GPUInfos[1].Health; h.Status != gpu.HealthOK {
+		t.Errorf("gpu1.Health = %+v", h)
+	}
+	if ecc := info.GPUInfos[1].ECC; ecc == nil || ecc.Mode != "" || ecc.Volatile.Corrected != gpu.Uint(12) {
+		t.Errorf("gpu1.ECC = %+v", ecc)
+	}
+}
+
+func TestLoadWithScriptedExecutor(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ixsmi.xml"))
+	if err != nil {
+		t.Fatalf("failed to read xml: %v", err)
+	}
+	oldPaths := smiPaths
+	smiPaths = nil
+	defer func() { smiPaths = oldPaths }()
+
+	fake := gpu.NewScriptedExecutor().
+		Path("ixsmi", "/usr/bin/ixsmi").
+		Path("/usr/bin/ixsmi", "/usr/bin/ixsmi").
+		Stdout(data, "/usr/bin/ixsmi", "-q", "-x")
+	a := &ixGPU{}
+	a.SetExecutor(fake)
+
+	if !a.Available() {
+		t.Fatal("expected ixsmi on PATH to be available")
+	}
+	info, err := a.Load()
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(info.GPUInfos) != 2 {
+		t.Fatalf("expected 2 gpus, got %d", len(info.GPUInfos))
+	}
+	if info.GPUInfos[0].SerialNumber != "23490256585496" {
+		t.Errorf("gpu0.SerialNumber = %s", info.GPUInfos[0].SerialNumber)
+	}
+	// PATH 中找到的 ixsmi 沿用当前环境
+	for _, call := range fake.Calls() {
+		if call.Env != nil {
+			t.Errorf("%s run with env %v", call, call.Env)
+		}
+	}
+}
+
+func TestLoadWithoutIxsmi(t *testing.T) {
+	oldPaths := smiPaths
+	smiPaths = nil
+	defer func() { smiPaths = oldPaths }()
+
+	a := &ixGPU{executor: gpu.NewScriptedExecutor()}
+	if a.Available() {
+		t.Error("expected ixsmi to be unavailable")
+	}
+	if _, err := a.Load(); err == nil {
+		t.Error("expected an error when ixsmi is missing")
+	}
+}
+
+var _ gpu.ContextGPUInfoLoader = &ixGPU{}
+var _ gpu.ExecutorSetter = &ixGPU{}
+
+func TestParseIXSMIProcesses(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ixsmi.xml"))
+	if err != nil {
+		t.Fatalf("failed to read xml: %v", err)
+	}
+	processes, err := ParseIXSMIProcesses(string(data))
+	if err != nil {
+		t.Fatalf("ParseIXSMIProcesses error: %v", err)
+	}
+	if len(processes) != 2 {
+		t.Fatalf("expected 2 processes, got %d", len(processes))
+	}
+	p := processes[1]
+	if p.PID != 22550 || p.DeviceIndex != 1 {
+		t.Errorf("processes[1] = %+v", p)
+	}
+	if p.MemoryUsed != gpu.Uint(27600*1024*1024) {
+		t.Errorf("processes[1].MemoryUsed = %+v", p.MemoryUsed)
+	}
+	if !strings.HasPrefix(p.Name, "/usr/local/bin/python3 -c from multiprocessing.spawn") {
+		t.Errorf("processes[1].Name = %s", p.Name)
+	}
 }
+
+func TestProcessesWithScriptedExecutor(t *testing.T) {
+	oldPaths := smiPaths
+	smiPaths = nil
+	defer func() { smiPaths = oldPaths }()
+
+	fake := gpu.NewScriptedExecutor().
+		Path("ixsmi", "/usr/bin/ixsmi").
+		Stdout([]byte(`<ixsmi_log><gpu id="00000000:0C:00.0"><processes></processes></gpu></ixsmi_log>`), "/usr/bin/ixsmi", "-q", "-x")
+	a := &ixGPU{executor: fake}
+
+	processes, err := a.Processes(context.Background())
+	if err != nil {
+		t.Fatalf("Processes error: %v", err)
+	}
+	if processes == nil || len(processes) != 0 {
+		t.Errorf("expected an empty process list, got %+v", processes)
+	}
+}
+
+var _ gpu.ProcessLister = &ixGPU{}
+
+func TestDriverInfoWithScriptedExecutor(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ixsmi.xml"))
+	if err != nil {
+		t.Fatalf("failed to read xml: %v", err)
+	}
+	oldPaths := smiPaths
+	smiPaths = nil
+	defer func() { smiPaths = oldPaths }()
+
+	fake := gpu.NewScriptedExecutor().
+		Path("ixsmi", "/usr/bin/ixsmi").
+		Stdout(data, "/usr/bin/ixsmi", "-q", "-x")
+	a := &ixGPU{executor: fake}
+
+	info, err := a.DriverInfo()
+	if err != nil {
+		t.Fatalf("DriverInfo error: %v", err)
+	}
+	want := gpu.GPUDriverInfo{
+		Vendor:      "Iluvatar",
+		Version:     "4.2.0",
+		Installed:   true,
+		InstallPath: "/usr/bin/ixsmi",
+		LibVersion:  "10.2",
+	}
+	if info != want {
+		t.Errorf("DriverInfo() = %+v, want %+v", info, want)
+	}
+}
+
+func TestParseIXSMIVersionMissing(t *testing.T) {
+	if _, err := ParseIXSMIVersion(`<ixsmi_log><attached_gpus>1</attached_gpus></ixsmi_log>`); err == nil {
+		t.Error("expected an error when driver_version is missing")
+	}
+}
+
+var _ gpu.ContextDriverGetter = &ixGPU{}
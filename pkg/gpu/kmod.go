@@ -0,0 +1,181 @@
+package gpu
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Values of GPUDriverInfo.DriverType.
+const (
+	DriverTypeOpenSource  = "open-source"
+	DriverTypeProprietary = "proprietary"
+)
+
+// kernelDriver describes the kernel side of a vendor's driver stack.
+type kernelDriver struct {
+	// modules are candidate module names in order of preference. A name
+	// also matches modules it is a prefix of, e.g. "drv_davinci" matches
+	// "drv_davinci_intf".
+	modules []string
+	// openSource lists the modules that are open source; any other module
+	// is proprietary.
+	openSource []string
+}
+
+// kernelDrivers is keyed by the Vendor() of the loaders.
+var kernelDrivers = map[string]kernelDriver{
+	"NVIDIA":   {modules: []string{"nvidia", "nouveau"}, openSource: []string{"nouveau"}},
+	"AMD":      {modules: []string{"amdgpu"}, openSource: []string{"amdgpu"}},
+	"Huawei":   {modules: []string{"drv_davinci", "ascend"}},
+	"Enflame":  {modules: []string{"enflame"}},
+	"mx":       {modules: []string{"metax"}},
+	"Iluvatar": {modules: []string{"iluvatar", "bi_driver"}},
+	"Denglin":  {modules: []string{"denglin"}},
+}
+
+// KernelModuleDetector fills the kernel module fields of GPUDriverInfo from
+// /proc/modules, /sys/module and the driver bound to each PCI device in
+// /sys/bus/pci/devices. It only finds anything on Linux.
+type KernelModuleDetector struct {
+	// Root is prepended to every path read, so tests can point the
+	// detector at a fake /proc and /sys tree.
+	Root string
+}
+
+// DefaultKernelModuleDetector reads the running system.
+var DefaultKernelModuleDetector = &KernelModuleDetector{Root: "/"}
+
+// Fill sets KernelModule, ModuleLoaded and DriverType of info for
+// info.Vendor, and Version from /sys/module/<name>/version if info has none.
+// Fields stay empty for vendors without a known module, or when no module
+// is loaded or bound to a device.
+func (d *KernelModuleDetector) Fill(info *GPUDriverInfo) {
+	driver, ok := kernelDrivers[info.Vendor]
+	if !ok {
+		return
+	}
+	loaded := d.loadedModules()
+	module := d.boundModule(info.Vendor)
+	if matchModule(driver.modules, map[string]bool{module: true}) == "" {
+		// Bound to another driver, such as vfio-pci for passthrough.
+		module = ""
+	}
+	if module == "" {
+		module = matchModule(driver.modules, loaded)
+	}
+	if module == "" {
+		return
+	}
+
+	info.KernelModule = module
+	info.ModuleLoaded = loaded[module] || d.exists("sys", "module", module)
+	info.DriverType = d.driverType(driver, module)
+	if info.Version == "" {
+		info.Version = d.readTrimmed("sys", "module", module, "version")
+	}
+}
+
+// loadedModules returns the module names listed in /proc/modules.
+func (d *KernelModuleDetector) loadedModules() map[string]bool {
+	loaded := make(map[string]bool)
+	data, err := os.ReadFile(d.path("proc", "modules"))
+	if err != nil {
+		return loaded
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			loaded[fields[0]] = true
+		}
+	}
+	return loaded
+}
+
+// boundModule returns the module of the driver bound to the first display
+// or accelerator device of vendor, so that e.g. the HDMI audio function of
+// a graphics card does not report snd_hda_intel.
+func (d *KernelModuleDetector) boundModule(vendor string) string {
+	devices, err := (&PCIEnumerator{Root: d.Root}).Devices()
+	if err != nil {
+		return ""
+	}
+	for _, dev := range devices {
+		if dev.Vendor == vendor && dev.Driven() {
+			return d.driverModule(dev.Driver)
+		}
+	}
+	return ""
+}
+
+// driverModule returns the module providing a PCI driver. Driver names may
+// differ from module names, e.g. the vfio-pci driver of module vfio_pci;
+// the kernel links the two in /sys/bus/pci/drivers/<name>/module. Without
+// that link, as for built-in drivers, the name is converted the way the
+// kernel converts module names.
+func (d *KernelModuleDetector) driverModule(name string) string {
+	if target, err := os.Readlink(d.path("sys", "bus", "pci", "drivers", name, "module")); err == nil {
+		return filepath.Base(target)
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// driverType reports whether module is open source. The NVIDIA open kernel
+// modules are named like the proprietary ones and are told apart by the
+// banner in /proc/driver/nvidia/version.
+func (d *KernelModuleDetector) driverType(driver kernelDriver, module string) string {
+	if module == "nvidia" && strings.Contains(d.readTrimmed("proc", "driver", "nvidia", "version"), "Open Kernel Module") {
+		return DriverTypeOpenSource
+	}
+	for _, name := range driver.openSource {
+		if name == module {
+			return DriverTypeOpenSource
+		}
+	}
+	return DriverTypeProprietary
+}
+
+// matchModule returns the first loaded module matching candidates, exact
+// names first.
+func matchModule(candidates []string, loaded map[string]bool) string {
+	for _, name := range candidates {
+		if loaded[name] {
+			return name
+		}
+	}
+	var matches []string
+	for name := range loaded {
+		for _, candidate := range candidates {
+			if strings.HasPrefix(name, candidate) {
+				matches = append(matches, name)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	// Map iteration order is random; pick the shortest name, then the
+	// alphabetically first, so the result is stable.
+	best := matches[0]
+	for _, name := range matches[1:] {
+		if len(name) < len(best) || (len(name) == len(best) && name < best) {
+			best = name
+		}
+	}
+	return best
+}
+
+func (d *KernelModuleDetector) path(elem ...string) string {
+	return filepath.Join(append([]string{d.Root}, elem...)...)
+}
+
+func (d *KernelModuleDetector) exists(elem ...string) bool {
+	_, err := os.Stat(d.path(elem...))
+	return err == nil
+}
+
+func (d *KernelModuleDetector) readTrimmed(elem ...string) string {
+	return readSysfs(d.path(elem...))
+}
@@ -0,0 +1,145 @@
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSysfs builds a /proc and /sys tree below a temporary root.
+type fakeSysfs struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeSysfs(t *testing.T) *fakeSysfs {
+	return &fakeSysfs{t: t, root: t.TempDir()}
+}
+
+func (f *fakeSysfs) file(path, content string) {
+	f.t.Helper()
+	full := filepath.Join(f.root, path)
+	assert.NoError(f.t, os.MkdirAll(filepath.Dir(full), 0o755))
+	assert.NoError(f.t, os.WriteFile(full, []byte(content), 0o644))
+}
+
+// pciDevice adds a PCI device, bound to driver unless driver is empty.
+func (f *fakeSysfs) pciDevice(addr, vendor, class, driver string) {
+	f.t.Helper()
+	dir := filepath.Join("sys", "bus", "pci", "devices", addr)
+	f.file(filepath.Join(dir, "vendor"), vendor+"\n")
+	f.file(filepath.Join(dir, "class"), class+"\n")
+	if driver != "" {
+		target := filepath.Join("..", "..", "..", "bus", "pci", "drivers", driver)
+		assert.NoError(f.t, os.Symlink(target, filepath.Join(f.root, dir, "driver")))
+	}
+}
+
+// driverModule links a PCI driver to the module providing it.
+func (f *fakeSysfs) driverModule(driver, module string) {
+	f.t.Helper()
+	dir := filepath.Join(f.root, "sys", "bus", "pci", "drivers", driver)
+	assert.NoError(f.t, os.MkdirAll(dir, 0o755))
+	target := filepath.Join("..", "..", "..", "..", "module", module)
+	assert.NoError(f.t, os.Symlink(target, filepath.Join(dir, "module")))
+}
+
+func (f *fakeSysfs) detector() *KernelModuleDetector {
+	return &KernelModuleDetector{Root: f.root}
+}
+
+const nvidiaModules = `nvidia_uvm 1806336 0 - Live 0x0000000000000000 (POE)
+nvidia_drm 122880 2 - Live 0x0000000000000000 (POE)
+nvidia 54337536 15 nvidia_uvm,nvidia_drm, Live 0x0000000000000000 (POE)
+snd_hda_intel 61440 0 - Live 0x0000000000000000
+`
+
+func TestKernelModuleDetectorNVIDIA(t *testing.T) {
+	fs := newFakeSysfs(t)
+	fs.file("proc/modules", nvidiaModules)
+	fs.file("sys/module/nvidia/version", "550.54.15\n")
+	// The HDMI audio function sorts first and must not be mistaken for the GPU.
+	fs.pciDevice("0000:01:00.1", "0x10de", "0x040300", "snd_hda_intel")
+	fs.pciDevice("0000:17:00.0", "0x10de", "0x030200", "nvidia")
+
+	info := GPUDriverInfo{Vendor: "NVIDIA", Version: "550.127.05"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, "nvidia", info.KernelModule)
+	assert.True(t, info.ModuleLoaded)
+	assert.Equal(t, DriverTypeProprietary, info.DriverType)
+	assert.Equal(t, "550.127.05", info.Version, "the tool's version is kept")
+
+	fs.file("proc/driver/nvidia/version", "NVRM version: NVIDIA UNIX Open Kernel Module for x86_64  550.54.15  Release Build\n")
+	info = GPUDriverInfo{Vendor: "NVIDIA"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, DriverTypeOpenSource, info.DriverType)
+	assert.Equal(t, "550.54.15", info.Version)
+}
+
+func TestKernelModuleDetectorPrefixMatch(t *testing.T) {
+	fs := newFakeSysfs(t)
+	fs.file("proc/modules", "drv_devdrv 409600 1 - Live 0x0\ndrv_davinci_intf 16384 2 drv_devdrv, Live 0x0\n")
+	fs.file("sys/module/drv_davinci_intf/version", "23.0.rc3\n")
+
+	info := GPUDriverInfo{Vendor: "Huawei"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, GPUDriverInfo{
+		Vendor:       "Huawei",
+		Version:      "23.0.rc3",
+		KernelModule: "drv_davinci_intf",
+		ModuleLoaded: true,
+		DriverType:   DriverTypeProprietary,
+	}, info)
+}
+
+func TestKernelModuleDetectorBuiltinDriver(t *testing.T) {
+	// amdgpu built into the kernel is absent from /proc/modules.
+	fs := newFakeSysfs(t)
+	fs.file("proc/modules", "")
+	fs.file("sys/module/amdgpu/parameters/ppfeaturemask", "0xfff7bfff\n")
+	fs.pciDevice("0000:03:00.0", "0x1002", "0x038000", "amdgpu")
+
+	info := GPUDriverInfo{Vendor: "AMD"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, "amdgpu", info.KernelModule)
+	assert.True(t, info.ModuleLoaded)
+	assert.Equal(t, DriverTypeOpenSource, info.DriverType)
+	assert.Equal(t, "", info.Version)
+}
+
+func TestKernelModuleDetectorPassthrough(t *testing.T) {
+	fs := newFakeSysfs(t)
+	fs.file("proc/modules", nvidiaModules+"vfio_pci 16384 0 - Live 0x0000000000000000\n")
+	fs.pciDevice("0000:17:00.0", "0x10de", "0x030200", "vfio-pci")
+	fs.driverModule("vfio-pci", "vfio_pci")
+
+	// vfio-pci is not an NVIDIA module, so the loaded nvidia module is
+	// reported instead.
+	info := GPUDriverInfo{Vendor: "NVIDIA"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, "nvidia", info.KernelModule)
+	assert.True(t, info.ModuleLoaded)
+	assert.Equal(t, DriverTypeProprietary, info.DriverType)
+
+	fs.file("proc/modules", "vfio_pci 16384 0 - Live 0x0000000000000000\n")
+	info = GPUDriverInfo{Vendor: "NVIDIA"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, GPUDriverInfo{Vendor: "NVIDIA"}, info)
+}
+
+func TestKernelModuleDetectorNothingLoaded(t *testing.T) {
+	fs := newFakeSysfs(t)
+	fs.file("proc/modules", nvidiaModules)
+	// Present but not bound to any driver.
+	fs.pciDevice("0000:1e:00.0", "0x1e27", "0x120000", "")
+
+	info := GPUDriverInfo{Vendor: "Denglin"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, GPUDriverInfo{Vendor: "Denglin"}, info)
+
+	info = GPUDriverInfo{Vendor: "CPU"}
+	fs.detector().Fill(&info)
+	assert.Equal(t, GPUDriverInfo{Vendor: "CPU"}, info)
+}
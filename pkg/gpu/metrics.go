@@ -0,0 +1,174 @@
+package gpu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NotAvailable is written to the string fields of GPUInfo when the vendor
+// tool did not report a reading, so that it is never mistaken for zero.
+const NotAvailable = "N/A"
+
+// MetricStatus tells whether a typed metric carries a reading.
+type MetricStatus int
+
+const (
+	// MetricUnavailable means the loader expected a reading but did not get
+	// one, e.g. a section was missing or a sub-query failed.
+	MetricUnavailable MetricStatus = iota
+	// MetricOK means Value holds a reading reported by the vendor tool.
+	MetricOK
+	// MetricUnsupported means the device or the vendor tool cannot report
+	// the metric at all, either because the tool printed "N/A" for it or
+	// because the loader's query does not include it.
+	MetricUnsupported
+)
+
+var metricStatusNames = map[MetricStatus]string{
+	MetricUnavailable: "unavailable",
+	MetricOK:          "ok",
+	MetricUnsupported: "unsupported",
+}
+
+func (s MetricStatus) String() string {
+	if name, ok := metricStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("MetricStatus(%d)", int(s))
+}
+
+func (s MetricStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *MetricStatus) UnmarshalText(text []byte) error {
+	for status, name := range metricStatusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown metric status %q", text)
+}
+
+// FloatMetric is a floating point reading such as a temperature, a power
+// draw or a utilisation percentage.
+type FloatMetric struct {
+	Value  float64      `json:"value"`
+	Status MetricStatus `json:"status"`
+}
+
+// Float returns an available FloatMetric holding v.
+func Float(v float64) FloatMetric {
+	return FloatMetric{Value: v, Status: MetricOK}
+}
+
+// UnsupportedFloat returns a FloatMetric marked as unsupported.
+func UnsupportedFloat() FloatMetric {
+	return FloatMetric{Status: MetricUnsupported}
+}
+
+// Available reports whether m holds a reading.
+func (m FloatMetric) Available() bool {
+	return m.Status == MetricOK
+}
+
+// UintMetric is an unsigned integer reading such as a memory size in bytes.
+type UintMetric struct {
+	Value  uint64       `json:"value"`
+	Status MetricStatus `json:"status"`
+}
+
+// Uint returns an available UintMetric holding v.
+func Uint(v uint64) UintMetric {
+	return UintMetric{Value: v, Status: MetricOK}
+}
+
+// UnsupportedUint returns a UintMetric marked as unsupported.
+func UnsupportedUint() UintMetric {
+	return UintMetric{Status: MetricUnsupported}
+}
+
+// Available reports whether m holds a reading.
+func (m UintMetric) Available() bool {
+	return m.Status == MetricOK
+}
+
+// IsNotSupported reports whether a vendor tool value is one of the markers
+// the tools print for readings a device does not support, such as "N/A",
+// "NA" or "[Not Supported]".
+func IsNotSupported(s string) bool {
+	s = strings.Trim(strings.TrimSpace(s), "[]")
+	switch strings.ToLower(s) {
+	case "n/a", "na", "not supported", "unsupported":
+		return true
+	}
+	return false
+}
+
+// ParseFloatMetric parses the leading number of a vendor tool value such as
+// "41.0", "39 ℃" or "6.29 W". Values rejected by IsNotSupported yield an
+// unsupported metric; empty and malformed values yield an unavailable one.
+func ParseFloatMetric(s string) FloatMetric {
+	if IsNotSupported(s) {
+		return UnsupportedFloat()
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return FloatMetric{}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(fields[0], "%"), 64)
+	if err != nil {
+		return FloatMetric{}
+	}
+	return Float(v)
+}
+
+// ParseUintMetric parses the leading unsigned integer of a vendor tool value,
+// with the same status rules as ParseFloatMetric.
+func ParseUintMetric(s string) UintMetric {
+	if IsNotSupported(s) {
+		return UnsupportedUint()
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return UintMetric{}
+	}
+	v, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return UintMetric{}
+	}
+	return Uint(v)
+}
+
+// GPUMetrics holds the typed counterparts of the string metrics in GPUInfo.
+type GPUMetrics struct {
+	TemperatureEdge             FloatMetric `json:"temperature_edge_c"`
+	TemperatureJunction         FloatMetric `json:"temperature_junction_c"`
+	TemperatureMemory           FloatMetric `json:"temperature_memory_c"`
+	AverageGraphicsPackagePower FloatMetric `json:"average_graphics_package_power_w"`
+	GPUUse                      FloatMetric `json:"gpu_use_percent"`
+	VRAMTotalMemory             UintMetric  `json:"vram_total_memory_bytes"`
+	VRAMTotalUsedMemory         UintMetric  `json:"vram_total_used_memory_bytes"`
+	PowerLimit                  FloatMetric `json:"power_limit_w"`
+	FanSpeed                    FloatMetric `json:"fan_speed_percent"`
+}
+
+// String formats m for the legacy string fields of GPUInfo, returning
+// NotAvailable when m holds no reading.
+func (m FloatMetric) String() string {
+	if !m.Available() {
+		return NotAvailable
+	}
+	return strconv.FormatFloat(m.Value, 'f', -1, 64)
+}
+
+// String formats m for the legacy string fields of GPUInfo, returning
+// NotAvailable when m holds no reading.
+func (m UintMetric) String() string {
+	if !m.Available() {
+		return NotAvailable
+	}
+	return strconv.FormatUint(m.Value, 10)
+}
@@ -0,0 +1,73 @@
+package gpu
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFloatMetric(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected FloatMetric
+	}{
+		{"41.0", Float(41)},
+		{"39 ℃", Float(39)},
+		{" 6.29 W ", Float(6.29)},
+		{"85 %", Float(85)},
+		{"12%", Float(12)},
+		{"", FloatMetric{}},
+		{"garbage", FloatMetric{}},
+		{"N/A", UnsupportedFloat()},
+		{"NA", UnsupportedFloat()},
+		{"[Not Supported]", UnsupportedFloat()},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, ParseFloatMetric(tt.input), "input %q", tt.input)
+	}
+}
+
+func TestParseUintMetric(t *testing.T) {
+	assert.Equal(t, Uint(17163091968), ParseUintMetric("17163091968"))
+	assert.Equal(t, Uint(0), ParseUintMetric("0"))
+	assert.Equal(t, UnsupportedUint(), ParseUintMetric("N/A"))
+	assert.Equal(t, UintMetric{}, ParseUintMetric("-1"))
+}
+
+func TestMetricString(t *testing.T) {
+	assert.Equal(t, "41.5", Float(41.5).String())
+	assert.Equal(t, "N/A", UnsupportedFloat().String())
+	assert.Equal(t, "N/A", FloatMetric{}.String())
+	assert.Equal(t, "1024", Uint(1024).String())
+	assert.Equal(t, "N/A", UintMetric{}.String())
+}
+
+func TestMetricJSON(t *testing.T) {
+	data, err := json.Marshal(GPUMetrics{TemperatureEdge: Float(41.5)})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"temperature_edge_c":{"value":41.5,"status":"ok"}`)
+	assert.Contains(t, string(data), `"vram_total_memory_bytes":{"value":0,"status":"unavailable"}`)
+
+	var decoded GPUMetrics
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, Float(41.5), decoded.TemperatureEdge)
+	assert.False(t, decoded.VRAMTotalMemory.Available())
+
+	var status MetricStatus
+	assert.Error(t, status.UnmarshalText([]byte("bogus")))
+}
+
+func TestGPUInfoLegacyJSON(t *testing.T) {
+	var info GPUInfo
+	err := json.Unmarshal([]byte(`{"Temperature (Sensor edge) (C)":"36.0","VRAM Total Memory (B)":"17163091968"}`), &info)
+	assert.NoError(t, err)
+	assert.Equal(t, "36.0", info.TemperatureEdge)
+	assert.Equal(t, "17163091968", info.VRAMTotalMemory)
+}
+
+func TestParseMode(t *testing.T) {
+	assert.Equal(t, "enabled", ParseMode(" Enabled\n"))
+	assert.Equal(t, "disabled", ParseMode("DISABLED"))
+	assert.Equal(t, "", ParseMode("N/A"))
+}
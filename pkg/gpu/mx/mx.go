@@ -1,8 +1,8 @@
 package mx
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 
@@ -16,10 +16,21 @@ func init() {
 var mxPath = "/usr/bin/mx-smi"
 
 type mxCommand struct {
+	executor gpu.Executor
+}
+
+func (m *mxCommand) SetExecutor(e gpu.Executor) {
+	m.executor = e
 }
 
 func (m *mxCommand) Load() (*gpu.GPUInfoList, error) {
-	output, err := mxCmd()
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return m.LoadContext(ctx)
+}
+
+func (m *mxCommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	output, err := mxCmd(ctx, m.executor)
 	if err != nil {
 		return nil, err
 	}
@@ -28,21 +39,90 @@ func (m *mxCommand) Load() (*gpu.GPUInfoList, error) {
 }
 
 func (m *mxCommand) Available() bool {
-	cmd := exec.Command(mxPath)
-	if err := cmd.Run(); err == nil {
-		return true
-	}
-	return false
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return m.AvailableContext(ctx)
+}
+
+// AvailableContext only looks mx-smi up; a broken mx-smi fails Load.
+func (m *mxCommand) AvailableContext(ctx context.Context) bool {
+	_, err := gpu.ExecutorOrDefault(m.executor).LookPath(mxPath)
+	return err == nil
 }
 
 func (m *mxCommand) Vendor() string {
 	return "mx"
 }
 
-func mxCmd() (string, error) {
+func (m *mxCommand) DriverInfo() (gpu.GPUDriverInfo, error) {
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return m.DriverInfoContext(ctx)
+}
+
+func (m *mxCommand) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	output, err := gpu.CombinedOutput(ctx, m.executor, mxPath, "--show-version")
+	if err != nil {
+		return gpu.GPUDriverInfo{}, fmt.Errorf("failed to execute mx-smi command: %v", err)
+	}
+	info, err := parseMxVersion(string(output))
+	if err != nil {
+		return gpu.GPUDriverInfo{}, err
+	}
+	info.Vendor = m.Vendor()
+	info.Installed = true
+	info.InstallPath = mxPath
+	return info, nil
+}
+
+// parseMxVersion 解析 mx-smi --show-version 的输出
+// 格式:
+//
+//	mx-smi  version: 2.2.6
+//	GPU#0  MXN260  0000:0f:00.0
+//	    MACA Version                                  : 2.31.0.6
+//	    BIOS Version                                  : 1.22.3.0
+//	    Kernel Mode Driver Version                    : 2.12.13
+//
+// 多卡时只取第一张卡的版本
+func parseMxVersion(output string) (gpu.GPUDriverInfo, error) {
+	info := gpu.GPUDriverInfo{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		// mx-smi 在 "mx-smi" 和 "version" 之间输出两个空格
+		key := strings.Join(strings.Fields(parts[0]), " ")
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "mx-smi version":
+			info.ClientVersion = value
+		case "Kernel Mode Driver Version":
+			if info.Version == "" {
+				info.Version = value
+			}
+		case "MACA Version":
+			if info.LibVersion == "" {
+				info.LibVersion = value
+			}
+		case "BIOS Version":
+			if info.FirmwareVersion == "" {
+				info.FirmwareVersion = value
+			}
+		}
+	}
+
+	if info.Version == "" {
+		return info, fmt.Errorf("failed to parse mx-smi version info: missing driver version")
+	}
+	return info, nil
+}
+
+func mxCmd(ctx context.Context, e gpu.Executor) (string, error) {
 	mx := mxPath
-	cmd := exec.Command(mx, "--show-temperature", "--show-usage", "--show-memory")
-	output, err := cmd.CombinedOutput()
+	output, err := gpu.CombinedOutput(ctx, e, mx, "--show-temperature", "--show-usage", "--show-memory")
 	if err != nil {
 		return "", fmt.Errorf("failed to execute mx-smi command: %v", err)
 	}
@@ -80,8 +160,25 @@ func parseMxOutput(output string) (*gpu.GPUInfoList, error) {
 				currentGPU = nil
 			}
 
-			// 创建新的GPU信息对象
-			gpuInfo := gpu.GPUInfo{}
+			// 创建新的GPU信息对象，未上报的指标标记为 N/A
+			gpuInfo := gpu.GPUInfo{
+				TemperatureEdge:             gpu.NotAvailable,
+				TemperatureJunction:         gpu.NotAvailable,
+				TemperatureMemory:           gpu.NotAvailable,
+				AverageGraphicsPackagePower: gpu.NotAvailable,
+				GPUUse:                      gpu.NotAvailable,
+				VRAMTotalMemory:             gpu.NotAvailable,
+				VRAMTotalUsedMemory:         gpu.NotAvailable,
+				Metrics: gpu.GPUMetrics{
+					// mx-smi 只提供 hotspot（结温）温度，没有 edge 与显存温度；
+					// 查询参数不包含功耗
+					TemperatureEdge:             gpu.UnsupportedFloat(),
+					TemperatureMemory:           gpu.UnsupportedFloat(),
+					AverageGraphicsPackagePower: gpu.UnsupportedFloat(),
+					PowerLimit:                  gpu.UnsupportedFloat(),
+					FanSpeed:                    gpu.UnsupportedFloat(),
+				},
+			}
 
 			// 解析GPU编号和型号
 			// 格式: GPU#0  MXN260  0000:0f:00.0
@@ -101,7 +198,7 @@ func parseMxOutput(output string) (*gpu.GPUInfoList, error) {
 
 			// 提取PCI Bus信息（最后一个字段）
 			if len(fields) > 2 {
-				gpuInfo.PCIBus = fields[len(fields)-1]
+				gpuInfo.PCIBus = gpu.CanonicalPCIAddress(fields[len(fields)-1])
 			}
 
 			currentGPU = &gpuInfo
@@ -115,8 +212,11 @@ func parseMxOutput(output string) (*gpu.GPUInfoList, error) {
 				parts := strings.Split(line, ":")
 				if len(parts) == 2 {
 					temp := strings.TrimSpace(parts[1])
-					temp = strings.TrimSuffix(temp, "°C")
-					currentGPU.TemperatureEdge = strings.TrimSpace(temp)
+					temp = strings.TrimSpace(strings.TrimSuffix(temp, "°C"))
+					// hotspot 即结温，与 amd-smi 的 hotspot 一致
+					currentGPU.TemperatureJunction = temp
+					currentGPU.TemperatureEdge = temp // 字符串字段沿用旧行为，见 GPUInfo.Metrics
+					currentGPU.Metrics.TemperatureJunction = gpu.ParseFloatMetric(temp)
 				}
 			}
 
@@ -129,6 +229,7 @@ func parseMxOutput(output string) (*gpu.GPUInfoList, error) {
 					if kb, err := strconv.ParseInt(strings.TrimSpace(mem), 10, 64); err == nil {
 						// 转换为字节
 						currentGPU.VRAMTotalMemory = strconv.FormatInt(kb*1024, 10)
+						currentGPU.Metrics.VRAMTotalMemory = gpu.Uint(uint64(kb * 1024))
 					}
 				}
 			}
@@ -141,6 +242,7 @@ func parseMxOutput(output string) (*gpu.GPUInfoList, error) {
 					if kb, err := strconv.ParseInt(strings.TrimSpace(mem), 10, 64); err == nil {
 						// 转换为字节
 						currentGPU.VRAMTotalUsedMemory = strconv.FormatInt(kb*1024, 10)
+						currentGPU.Metrics.VRAMTotalUsedMemory = gpu.Uint(uint64(kb * 1024))
 					}
 				}
 			}
@@ -154,6 +256,7 @@ func parseMxOutput(output string) (*gpu.GPUInfoList, error) {
 					usage := strings.TrimSpace(parts[1])
 					usage = strings.TrimSuffix(usage, "%")
 					currentGPU.GPUUse = strings.TrimSpace(usage)
+					currentGPU.Metrics.GPUUse = gpu.ParseFloatMetric(usage)
 				}
 			}
 		}
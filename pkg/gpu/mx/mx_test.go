@@ -1,8 +1,10 @@
 package mx
 
 import (
-	"testing"
 	_ "embed"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 )
 
 //go:embed testdata/output.txt
@@ -33,8 +35,8 @@ func TestParseMxOutput(t *testing.T) {
 		t.Errorf("Expected CardModel MXN260, got %s", gpuList.GPUInfos[0].CardModel)
 	}
 
-	if gpuList.GPUInfos[0].TemperatureEdge != "44.00" {
-		t.Errorf("Expected TemperatureEdge 44.00, got %s", gpuList.GPUInfos[0].TemperatureEdge)
+	if gpuList.GPUInfos[0].TemperatureJunction != "44.00" {
+		t.Errorf("Expected TemperatureJunction 44.00, got %s", gpuList.GPUInfos[0].TemperatureJunction)
 	}
 
 	if gpuList.GPUInfos[0].VRAMTotalMemory != "68719476736" { // 67108864 KB = 68719476736 bytes
@@ -52,6 +54,23 @@ func TestParseMxOutput(t *testing.T) {
 	if gpuList.GPUInfos[0].PCIBus != "0000:0f:00.0" {
 		t.Errorf("Expected PCIBus 0000:0f:00.0, got %s", gpuList.GPUInfos[0].PCIBus)
 	}
+
+	metrics := gpuList.GPUInfos[0].Metrics
+	if metrics.TemperatureJunction != gpu.Float(44) {
+		t.Errorf("Expected typed TemperatureJunction 44, got %+v", metrics.TemperatureJunction)
+	}
+	if metrics.TemperatureEdge.Status != gpu.MetricUnsupported {
+		t.Errorf("Expected typed TemperatureEdge unsupported, got %+v", metrics.TemperatureEdge)
+	}
+	if metrics.VRAMTotalUsedMemory != gpu.Uint(62684897280) {
+		t.Errorf("Expected typed VRAMTotalUsedMemory 62684897280, got %+v", metrics.VRAMTotalUsedMemory)
+	}
+	if metrics.AverageGraphicsPackagePower.Status != gpu.MetricUnsupported {
+		t.Errorf("Expected power to be unsupported, got %+v", metrics.AverageGraphicsPackagePower)
+	}
+	if gpuList.GPUInfos[0].AverageGraphicsPackagePower != gpu.NotAvailable {
+		t.Errorf("Expected AverageGraphicsPackagePower N/A, got %s", gpuList.GPUInfos[0].AverageGraphicsPackagePower)
+	}
 }
 
 func TestParseMxOutputEmpty(t *testing.T) {
@@ -77,4 +96,94 @@ func TestParseMxOutputInvalid(t *testing.T) {
 	if len(gpuList.GPUInfos) != 0 {
 		t.Errorf("Expected 0 GPUs for invalid input, got %d", len(gpuList.GPUInfos))
 	}
-}
\ No newline at end of file
+}
+func TestLoadWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout([]byte(output), mxPath, "--show-temperature", "--show-usage", "--show-memory")
+	m := &mxCommand{}
+	m.SetExecutor(fake)
+
+	if !m.Available() {
+		t.Fatal("expected mx-smi to be available")
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("expected Available to run nothing, ran %v", calls)
+	}
+	gpuList, err := m.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(gpuList.GPUInfos) != 1 || gpuList.GPUInfos[0].CardModel != "MXN260" {
+		t.Errorf("unexpected GPUs: %+v", gpuList.GPUInfos)
+	}
+}
+
+func TestLoadMxSMIFails(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Script(gpu.Result{Stdout: []byte("mx-smi: no devices found"), ExitCode: 1}, mxPath, "--show-temperature", "--show-usage", "--show-memory")
+	m := &mxCommand{executor: fake}
+
+	if _, err := m.Load(); err == nil {
+		t.Error("expected an error when mx-smi exits non-zero")
+	}
+
+	m.SetExecutor(gpu.NewScriptedExecutor())
+	if m.Available() {
+		t.Error("expected mx-smi to be unavailable when it is not installed")
+	}
+}
+
+func TestLoadFromReplayBundle(t *testing.T) {
+	replay, err := gpu.OpenReplayExecutor("testdata/mx-smi.bundle.json")
+	if err != nil {
+		t.Fatalf("OpenReplayExecutor failed: %v", err)
+	}
+	m := &mxCommand{executor: replay}
+
+	if !m.Available() {
+		t.Fatal("expected recorded mx-smi to be available")
+	}
+	gpuList, err := m.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(gpuList.GPUInfos) != 1 || gpuList.GPUInfos[0].VRAMTotalMemory != "68719476736" {
+		t.Errorf("unexpected GPUs: %+v", gpuList.GPUInfos)
+	}
+}
+
+//go:embed testdata/version.txt
+var versionOutput string
+
+func TestDriverInfoWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Stdout([]byte(versionOutput), mxPath, "--show-version")
+	m := &mxCommand{executor: fake}
+
+	info, err := m.DriverInfo()
+	if err != nil {
+		t.Fatalf("DriverInfo failed: %v", err)
+	}
+	want := gpu.GPUDriverInfo{
+		Vendor:          "mx",
+		Version:         "2.12.13",
+		Installed:       true,
+		InstallPath:     mxPath,
+		ClientVersion:   "2.2.6",
+		LibVersion:      "2.31.0.6",
+		FirmwareVersion: "1.22.3.0",
+	}
+	if info != want {
+		t.Errorf("DriverInfo() = %+v, want %+v", info, want)
+	}
+}
+
+func TestParseMxVersionWithoutDriver(t *testing.T) {
+	// 旧版本 mx-smi 只打印自身版本
+	if _, err := parseMxVersion("mx-smi  version: 2.2.6\n"); err == nil {
+		t.Error("expected an error when the driver version is missing")
+	}
+}
+
+var _ gpu.ContextGPUInfoLoader = &mxCommand{}
+var _ gpu.ContextDriverGetter = &mxCommand{}
+var _ gpu.ExecutorSetter = &mxCommand{}
@@ -0,0 +1,114 @@
+package nvidia
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+type nvidiaSMIMIGMode struct {
+	Current string `xml:"current_mig"`
+	Pending string `xml:"pending_mig"`
+}
+
+type nvidiaSMIMIGDevice struct {
+	Index             string          `xml:"index"`
+	GPUInstanceID     string          `xml:"gpu_instance_id"`
+	ComputeInstanceID string          `xml:"compute_instance_id"`
+	FBMemoryUsage     nvidiaSMIMemory `xml:"fb_memory_usage"`
+}
+
+// xmlMIG returns the MIG state of g, or nil for GPUs without MIG support,
+// for which nvidia-smi prints N/A. The -q -x output has neither the profile
+// nor the UUID of MIG devices; addMIGProfiles fills them in from -L.
+func xmlMIG(g nvidiaSMIGPU) *gpu.MIG {
+	mode := gpu.ParseMode(g.MIGMode.Current)
+	if mode == "" {
+		return nil
+	}
+	mig := &gpu.MIG{Mode: mode, PendingMode: gpu.ParseMode(g.MIGMode.Pending)}
+	for _, d := range g.MIGDevices {
+		index, err := strconv.Atoi(strings.TrimSpace(d.Index))
+		if err != nil {
+			continue
+		}
+		gi, _ := strconv.Atoi(strings.TrimSpace(d.GPUInstanceID))
+		ci, _ := strconv.Atoi(strings.TrimSpace(d.ComputeInstanceID))
+		mig.Devices = append(mig.Devices, gpu.MIGDevice{
+			Index:             index,
+			GPUInstanceID:     gi,
+			ComputeInstanceID: ci,
+			MemoryTotal:       parseMiB(d.FBMemoryUsage.Total),
+			MemoryUsed:        parseMiB(d.FBMemoryUsage.Used),
+		})
+	}
+	return mig
+}
+
+// migEnabled reports whether any GPU in list has MIG enabled.
+func migEnabled(list *gpu.GPUInfoList) bool {
+	for _, info := range list.GPUInfos {
+		if info.MIG != nil && info.MIG.Mode == "enabled" {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	listGPU = regexp.MustCompile(`^GPU\s+(\d+):`)
+	listMIG = regexp.MustCompile(`^\s+MIG\s+(\S+)\s+Device\s+(\d+):\s*\(UUID:\s*([^)]+)\)`)
+)
+
+// migProfile is a MIG device line of "nvidia-smi -L".
+type migProfile struct {
+	profile string
+	uuid    string
+}
+
+// parseMIGList parses "nvidia-smi -L", keyed by GPU index and MIG device
+// index:
+//
+//	GPU 0: NVIDIA A100-SXM4-80GB (UUID: GPU-4a6c3d5e-...)
+//	  MIG 3g.40gb     Device  0: (UUID: MIG-7c1e5f0a-...)
+func parseMIGList(output []byte) map[int]map[int]migProfile {
+	profiles := make(map[int]map[int]migProfile)
+	gpuIndex := -1
+	for _, line := range strings.Split(string(output), "\n") {
+		if m := listGPU.FindStringSubmatch(line); m != nil {
+			gpuIndex, _ = strconv.Atoi(m[1])
+			continue
+		}
+		m := listMIG.FindStringSubmatch(line)
+		if m == nil || gpuIndex < 0 {
+			continue
+		}
+		index, _ := strconv.Atoi(m[2])
+		if profiles[gpuIndex] == nil {
+			profiles[gpuIndex] = make(map[int]migProfile)
+		}
+		profiles[gpuIndex][index] = migProfile{profile: m[1], uuid: strings.TrimSpace(m[3])}
+	}
+	return profiles
+}
+
+// addMIGProfiles sets the profile and UUID of the MIG devices in list from
+// the output of "nvidia-smi -L".
+func addMIGProfiles(list *gpu.GPUInfoList, output []byte) {
+	profiles := parseMIGList(output)
+	for i := range list.GPUInfos {
+		info := &list.GPUInfos[i]
+		if info.MIG == nil {
+			continue
+		}
+		for j := range info.MIG.Devices {
+			dev := &info.MIG.Devices[j]
+			if p, ok := profiles[info.Num][dev.Index]; ok {
+				dev.Profile = p.profile
+				dev.UUID = p.uuid
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package nvidia
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	//go:embed testdata/nvidia-smi-q-mig.xml
+	migXMLOutput []byte
+	//go:embed testdata/nvidia-smi-L.txt
+	listOutput []byte
+)
+
+func TestLoadMIG(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(migXMLOutput, "nvidia-smi", "-q", "-x").
+		Stdout(listOutput, "nvidia-smi", "-L")
+	n := New()
+	n.SetExecutor(fake)
+
+	list, err := n.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+
+	mig := list.GPUInfos[0].MIG
+	assert.Equal(t, "enabled", mig.Mode)
+	assert.Equal(t, "enabled", mig.PendingMode)
+	assert.Equal(t, []gpu.MIGDevice{
+		{Index: 0, GPUInstanceID: 2, ComputeInstanceID: 0, Profile: "3g.40gb", UUID: "MIG-7c1e5f0a-3b2d-5e8f-9a41-62d3c8b7e0f1", MemoryTotal: gpu.Uint(40192 << 20), MemoryUsed: gpu.Uint(30217 << 20)},
+		{Index: 1, GPUInstanceID: 3, ComputeInstanceID: 0, Profile: "2g.20gb", UUID: "MIG-2d9b4a6c-8e1f-5a3b-b7c2-0f4e6d8a1b3c", MemoryTotal: gpu.Uint(19968 << 20), MemoryUsed: gpu.Uint(13 << 20)},
+		{Index: 2, GPUInstanceID: 9, ComputeInstanceID: 0, Profile: "1g.10gb", UUID: "MIG-e5f7a9c1-4b3d-5f6e-8a2c-1d3b5e7f9a0c", MemoryTotal: gpu.Uint(9728 << 20), MemoryUsed: gpu.Uint(13 << 20)},
+	}, mig.Devices)
+	assert.Equal(t, gpu.MetricUnsupported, list.GPUInfos[0].Metrics.GPUUse.Status, "no utilization in MIG mode")
+
+	assert.Equal(t, &gpu.MIG{Mode: "disabled", PendingMode: "disabled"}, list.GPUInfos[1].MIG)
+}
+
+func TestLoadMIGWithoutList(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout(migXMLOutput, "nvidia-smi", "-q", "-x").
+		Script(gpu.Result{ExitCode: 1}, "nvidia-smi", "-L")
+	n := &nvidiaSMICommand{executor: fake}
+
+	list, err := n.Load()
+	assert.NoError(t, err)
+	devices := list.GPUInfos[0].MIG.Devices
+	assert.Len(t, devices, 3)
+	assert.Equal(t, 3, devices[1].GPUInstanceID)
+	assert.Equal(t, "", devices[1].UUID)
+}
+
+func TestLoadSkipsListWithoutMIG(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Stdout(xmlOutput, "nvidia-smi", "-q", "-x")
+	n := &nvidiaSMICommand{executor: fake}
+
+	_, err := n.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"nvidia-smi", "-q", "-x"}}, argvs(fake.Calls()))
+}
+
+func TestParseMIGList(t *testing.T) {
+	// Drivers before R470 print the GPU UUID inside MIG UUIDs.
+	output := []byte("GPU 0: A100-SXM4-40GB (UUID: GPU-5d5ba0d6-d33d-2b2c-524d-9e3d8d2b8a77)\n" +
+		"  MIG 1g.5gb Device 0: (UUID: MIG-GPU-5d5ba0d6-d33d-2b2c-524d-9e3d8d2b8a77/7/0)\n")
+	assert.Equal(t, map[int]map[int]migProfile{
+		0: {0: {profile: "1g.5gb", uuid: "MIG-GPU-5d5ba0d6-d33d-2b2c-524d-9e3d8d2b8a77/7/0"}},
+	}, parseMIGList(output))
+}
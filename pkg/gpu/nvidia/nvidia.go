@@ -1,9 +1,9 @@
 package nvidia
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 
@@ -11,27 +11,85 @@ import (
 )
 
 func init() {
-	gpu.Register(&nvidiaSMICommand{})
+	gpu.Register(New())
 }
 
+// New returns the NVIDIA loader. It reads everything "nvidia-smi -q -x"
+// reports: power, serial, UUID, VBIOS, clocks, fan, PCIe link, ECC and
+// throttle reasons.
 func New() *nvidiaSMICommand {
 	return &nvidiaSMICommand{}
 }
 
+// NewLightweight returns an NVIDIA loader that only queries the index,
+// name, memory, utilization, temperature and PCI bus of each GPU as CSV,
+// which is much cheaper than -q -x on hosts with many GPUs.
+func NewLightweight() *nvidiaSMICommand {
+	return &nvidiaSMICommand{lightweight: true}
+}
+
 type nvidiaSMICommand struct {
+	executor    gpu.Executor
+	lightweight bool
+}
+
+func (n *nvidiaSMICommand) SetExecutor(e gpu.Executor) {
+	n.executor = e
 }
 
 func (n *nvidiaSMICommand) Load() (*gpu.GPUInfoList, error) {
-	cmd := exec.Command("nvidia-smi", "--format=csv,noheader", "--query-gpu=index,name,memory.total,memory.used,utilization.gpu,temperature.gpu,pci.bus_id")
-	output, err := cmd.Output()
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return n.LoadContext(ctx)
+}
+
+func (n *nvidiaSMICommand) LoadContext(ctx context.Context) (*gpu.GPUInfoList, error) {
+	if !n.lightweight {
+		return n.loadXML(ctx)
+	}
+	output, err := gpu.Output(ctx, n.executor, "nvidia-smi", "--format=csv,noheader", "--query-gpu=index,name,memory.total,memory.used,utilization.gpu,temperature.gpu,pci.bus_id")
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute nvidia-smi command: %v", err)
 	}
 	return n.parse(output)
 }
 
+// loadXML reads the GPUs from -q -x and, if any GPU is in MIG mode, the
+// profiles and UUIDs of its MIG devices from -L. Without -L the MIG devices
+// are still listed.
+func (n *nvidiaSMICommand) loadXML(ctx context.Context) (*gpu.GPUInfoList, error) {
+	output, err := n.queryXML(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list, err := parseXML(output)
+	if err != nil {
+		return nil, err
+	}
+	if migEnabled(list) {
+		if output, err := gpu.Output(ctx, n.executor, "nvidia-smi", "-L"); err == nil {
+			addMIGProfiles(list, output)
+		}
+	}
+	return list, nil
+}
+
+func (n *nvidiaSMICommand) queryXML(ctx context.Context) ([]byte, error) {
+	output, err := gpu.Output(ctx, n.executor, "nvidia-smi", "-q", "-x")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute nvidia-smi command: %v", err)
+	}
+	return output, nil
+}
+
 func (n *nvidiaSMICommand) Available() bool {
-	_, err := exec.LookPath("nvidia-smi")
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return n.AvailableContext(ctx)
+}
+
+func (n *nvidiaSMICommand) AvailableContext(ctx context.Context) bool {
+	_, err := gpu.ExecutorOrDefault(n.executor).LookPath("nvidia-smi")
 	return err == nil
 }
 
@@ -70,37 +128,17 @@ func (n *nvidiaSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 			continue
 		}
 
-		// Parse memory total (convert MiB to bytes)
-		memoryTotalParts := strings.Fields(memoryTotal)
-		var memoryTotalBytes int64 = 0
-		if len(memoryTotalParts) >= 1 {
-			if memTotal, err := strconv.ParseInt(memoryTotalParts[0], 10, 64); err == nil {
-				memoryTotalBytes = memTotal * 1024 * 1024 // Convert MiB to bytes
-			}
-		}
-
-		// Parse memory used (convert MiB to bytes)
-		memoryUsedParts := strings.Fields(memoryUsed)
-		var memoryUsedBytes int64 = 0
-		if len(memoryUsedParts) >= 1 {
-			if memUsed, err := strconv.ParseInt(memoryUsedParts[0], 10, 64); err == nil {
-				memoryUsedBytes = memUsed * 1024 * 1024 // Convert MiB to bytes
-			}
-		}
-
-		// Parse GPU utilization (remove % sign)
-		utilizationGPUParts := strings.Fields(utilizationGPU)
-		var utilizationGPUFloat float64 = 0
-		if len(utilizationGPUParts) >= 1 {
-			if util, err := strconv.ParseFloat(utilizationGPUParts[0], 64); err == nil {
-				utilizationGPUFloat = util
-			}
-		}
-
-		// Parse temperature
-		var temperatureFloat float64 = 0
-		if temp, err := strconv.ParseFloat(temperatureGPU, 64); err == nil {
-			temperatureFloat = temp
+		metrics := gpu.GPUMetrics{
+			VRAMTotalMemory:     parseMiB(memoryTotal),
+			VRAMTotalUsedMemory: parseMiB(memoryUsed),
+			GPUUse:              gpu.ParseFloatMetric(utilizationGPU),
+			TemperatureEdge:     gpu.ParseFloatMetric(temperatureGPU),
+			// Not provided by basic nvidia-smi query
+			TemperatureJunction:         gpu.UnsupportedFloat(),
+			TemperatureMemory:           gpu.UnsupportedFloat(),
+			AverageGraphicsPackagePower: gpu.UnsupportedFloat(),
+			PowerLimit:                  gpu.UnsupportedFloat(),
+			FanSpeed:                    gpu.UnsupportedFloat(),
 		}
 
 		pciBusID := ""
@@ -114,17 +152,18 @@ func (n *nvidiaSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 			CardModel:                   name,
 			CardVendor:                  "NVIDIA",
 			CardSeries:                  "NVIDIA",
-			VRAMTotalMemory:             fmt.Sprintf("%d", memoryTotalBytes),
-			VRAMTotalUsedMemory:         fmt.Sprintf("%d", memoryUsedBytes),
-			GPUUse:                      fmt.Sprintf("%.1f", utilizationGPUFloat),
-			TemperatureEdge:             fmt.Sprintf("%.1f", temperatureFloat),
-			TemperatureJunction:         fmt.Sprintf("%.1f", temperatureFloat),
-			TemperatureMemory:           fmt.Sprintf("%.1f", temperatureFloat),
-			AverageGraphicsPackagePower: "0", // Not provided by basic nvidia-smi query
-			SerialNumber:                "",  // Not provided by basic nvidia-smi query
-			DeviceRev:                   "",  // Not provided by basic nvidia-smi query
-			CardSKU:                     "",  // Not provided by basic nvidia-smi query
-			PCIBus:                      pciBusID,
+			VRAMTotalMemory:             metrics.VRAMTotalMemory.String(),
+			VRAMTotalUsedMemory:         metrics.VRAMTotalUsedMemory.String(),
+			GPUUse:                      formatFloat(metrics.GPUUse),
+			TemperatureEdge:             formatFloat(metrics.TemperatureEdge),
+			TemperatureJunction:         formatFloat(metrics.TemperatureEdge), // see GPUInfo.Metrics
+			TemperatureMemory:           formatFloat(metrics.TemperatureEdge),
+			AverageGraphicsPackagePower: gpu.NotAvailable, // Not provided by basic nvidia-smi query
+			SerialNumber:                "",               // Not provided by basic nvidia-smi query
+			DeviceRev:                   "",               // Not provided by basic nvidia-smi query
+			CardSKU:                     "",               // Not provided by basic nvidia-smi query
+			PCIBus:                      gpu.CanonicalPCIAddress(pciBusID),
+			Metrics:                     metrics,
 		}
 
 		result.GPUInfos = append(result.GPUInfos, device)
@@ -133,13 +172,35 @@ func (n *nvidiaSMICommand) parse(output []byte) (*gpu.GPUInfoList, error) {
 	return result, nil
 }
 
+// parseMiB converts a "16376 MiB" reading into bytes.
+func parseMiB(value string) gpu.UintMetric {
+	m := gpu.ParseUintMetric(value)
+	if m.Available() {
+		m.Value *= 1024 * 1024
+	}
+	return m
+}
+
+// formatFloat keeps the one-decimal format of the legacy string fields.
+func formatFloat(m gpu.FloatMetric) string {
+	if !m.Available() {
+		return gpu.NotAvailable
+	}
+	return fmt.Sprintf("%.1f", m.Value)
+}
+
 func (n *nvidiaSMICommand) Vendor() string {
 	return "NVIDIA"
 }
 
 func (n *nvidiaSMICommand) DriverInfo() (gpu.GPUDriverInfo, error) {
-	cmd := exec.Command("nvidia-smi", "--version")
-	output, err := cmd.CombinedOutput()
+	ctx, cancel := gpu.DefaultContext()
+	defer cancel()
+	return n.DriverInfoContext(ctx)
+}
+
+func (n *nvidiaSMICommand) DriverInfoContext(ctx context.Context) (gpu.GPUDriverInfo, error) {
+	output, err := gpu.CombinedOutput(ctx, n.executor, "nvidia-smi", "--version")
 	if err != nil {
 		return gpu.GPUDriverInfo{}, fmt.Errorf("failed to execute nvidia-smi command: %v", err)
 	}
@@ -148,8 +209,8 @@ func (n *nvidiaSMICommand) DriverInfo() (gpu.GPUDriverInfo, error) {
 		return gpu.GPUDriverInfo{}, fmt.Errorf("failed to parse version info: %v", err)
 	}
 	info.Vendor = n.Vendor()
-	installPathCmd := exec.Command("which", "nvidia-smi")
-	installPathOutput, err := installPathCmd.CombinedOutput()
+	info.Installed = true
+	installPathOutput, err := gpu.CombinedOutput(ctx, n.executor, "which", "nvidia-smi")
 	if err != nil {
 		return gpu.GPUDriverInfo{}, fmt.Errorf("failed to execute 'which nvidia-smi' command: %v", err)
 	}
@@ -186,3 +247,71 @@ func ParseVersion(versionInfo string) (gpu.GPUDriverInfo, error) {
 
 	return info, nil
 }
+
+// Processes lists the processes holding memory on each GPU. The lightweight
+// loader only lists compute processes.
+func (n *nvidiaSMICommand) Processes(ctx context.Context) ([]gpu.GPUProcess, error) {
+	if !n.lightweight {
+		output, err := n.queryXML(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return parseXMLProcesses(output)
+	}
+	gpus, err := gpu.Output(ctx, n.executor, "nvidia-smi", "--format=csv,noheader", "--query-gpu=index,pci.bus_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute nvidia-smi command: %v", err)
+	}
+	apps, err := gpu.Output(ctx, n.executor, "nvidia-smi", "--format=csv,noheader", "--query-compute-apps=gpu_bus_id,pid,used_memory,process_name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute nvidia-smi command: %v", err)
+	}
+	return parseComputeApps(gpus, apps)
+}
+
+// parseComputeApps joins the compute apps to GPU indexes by PCI bus ID.
+//
+//	gpus: 0, 00000000:16:00.0
+//	apps: 00000000:16:00.0, 4188, 2046 MiB, /usr/bin/python3
+func parseComputeApps(gpus, apps []byte) ([]gpu.GPUProcess, error) {
+	indexByBus := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(string(gpus)), "\n") {
+		fields := strings.SplitN(line, ",", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPU index %q: %v", fields[0], err)
+		}
+		indexByBus[gpu.CanonicalPCIAddress(strings.TrimSpace(fields[1]))] = index
+	}
+
+	processes := []gpu.GPUProcess{}
+	for _, line := range strings.Split(strings.TrimSpace(string(apps)), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		// The process name comes last because it may itself contain commas.
+		fields := strings.SplitN(line, ",", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid compute app line %q", line)
+		}
+		busID := gpu.CanonicalPCIAddress(strings.TrimSpace(fields[0]))
+		index, ok := indexByBus[busID]
+		if !ok {
+			return nil, fmt.Errorf("compute app on unknown GPU %s", busID)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid %q: %v", fields[1], err)
+		}
+		processes = append(processes, gpu.GPUProcess{
+			PID:         pid,
+			Name:        strings.TrimSpace(fields[3]),
+			DeviceIndex: index,
+			MemoryUsed:  parseMiB(fields[2]),
+		})
+	}
+	return processes, nil
+}
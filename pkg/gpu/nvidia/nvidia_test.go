@@ -1,9 +1,11 @@
 package nvidia
 
 import (
+	"context"
 	"testing"
 
 	_ "embed"
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +27,7 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, "NVIDIA GeForce RTX 4080 SUPER", gpu0.CardModel)
 	assert.Equal(t, "NVIDIA", gpu0.CardVendor)
 	assert.Equal(t, "NVIDIA", gpu0.CardSeries)
-	assert.Equal(t, "00000000:50:00.0", gpu0.PCIBus)
+	assert.Equal(t, "0000:50:00.0", gpu0.PCIBus)
 
 	// Memory: 16376 MiB = 16376 * 1024 * 1024 = 17171480576 bytes
 	assert.Equal(t, "17171480576", gpu0.VRAMTotalMemory)
@@ -37,12 +39,21 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, "41.0", gpu0.TemperatureJunction)
 	assert.Equal(t, "41.0", gpu0.TemperatureMemory)
 
+	assert.Equal(t, gpu.Uint(17171480576), gpu0.Metrics.VRAMTotalMemory)
+	assert.Equal(t, gpu.Uint(1372585984), gpu0.Metrics.VRAMTotalUsedMemory)
+	assert.Equal(t, gpu.Float(0), gpu0.Metrics.GPUUse)
+	assert.Equal(t, gpu.Float(41), gpu0.Metrics.TemperatureEdge)
+	assert.Equal(t, gpu.UnsupportedFloat(), gpu0.Metrics.TemperatureJunction)
+	assert.Equal(t, gpu.UnsupportedFloat(), gpu0.Metrics.TemperatureMemory)
+	assert.Equal(t, gpu.MetricUnsupported, gpu0.Metrics.AverageGraphicsPackagePower.Status)
+	assert.Equal(t, "N/A", gpu0.AverageGraphicsPackagePower)
+
 	// Check second GPU
 	gpu1 := gpuInfoList.GPUInfos[1]
 	assert.Equal(t, 1, gpu1.Num)
 	assert.Equal(t, "1", gpu1.DeviceID)
 	assert.Equal(t, "NVIDIA GeForce RTX 4080 SUPER", gpu1.CardModel)
-	assert.Equal(t, "00000000:51:00.0", gpu1.PCIBus)
+	assert.Equal(t, "0000:51:00.0", gpu1.PCIBus)
 
 	// Memory used: 13625 MiB = 13625 * 1024 * 1024 = 14286848000 bytes
 	assert.Equal(t, "14286848000", gpu1.VRAMTotalUsedMemory)
@@ -50,6 +61,21 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, "39.0", gpu1.TemperatureEdge)
 }
 
+func TestParseNotSupported(t *testing.T) {
+	csvData := `0, NVIDIA A100-SXM4-40GB, [N/A], [N/A], [N/A], 35, 00000000:07:00.0`
+
+	nvidia := &nvidiaSMICommand{}
+
+	gpuInfoList, err := nvidia.parse([]byte(csvData))
+	assert.NoError(t, err)
+	gpu0 := gpuInfoList.GPUInfos[0]
+	assert.Equal(t, "N/A", gpu0.VRAMTotalMemory)
+	assert.Equal(t, "N/A", gpu0.GPUUse)
+	assert.Equal(t, "35.0", gpu0.TemperatureEdge)
+	assert.Equal(t, gpu.MetricUnsupported, gpu0.Metrics.VRAMTotalMemory.Status)
+	assert.Equal(t, gpu.MetricUnsupported, gpu0.Metrics.GPUUse.Status)
+}
+
 func TestParseInvalidData(t *testing.T) {
 	nvidia := &nvidiaSMICommand{}
 
@@ -81,3 +107,89 @@ func TestParseVersion(t *testing.T) {
 	assert.Equal(t, "550.54.14", r.Version)
 	assert.Equal(t, "12.4", r.LibVersion)
 }
+
+//go:embed testdata/nvidia.txt
+var csvOutput []byte
+
+var queryArgv = []string{"nvidia-smi", "--format=csv,noheader", "--query-gpu=index,name,memory.total,memory.used,utilization.gpu,temperature.gpu,pci.bus_id"}
+
+func TestLoadWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Stdout(csvOutput, queryArgv...)
+	n := NewLightweight()
+	n.SetExecutor(fake)
+
+	assert.True(t, n.Available())
+	gpuInfoList, err := n.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(gpuInfoList.GPUInfos))
+	assert.Equal(t, "NVIDIA L20", gpuInfoList.GPUInfos[3].CardModel)
+	assert.Equal(t, "0000:1d:00.0", gpuInfoList.GPUInfos[3].PCIBus)
+	assert.Equal(t, gpu.Float(75), gpuInfoList.GPUInfos[3].Metrics.TemperatureEdge)
+	assert.Equal(t, [][]string{queryArgv}, argvs(fake.Calls()))
+}
+
+func TestLoadCommandFails(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Script(gpu.Result{Stderr: []byte("NVIDIA-SMI has failed"), ExitCode: 9}, queryArgv...)
+	n := &nvidiaSMICommand{executor: fake, lightweight: true}
+
+	_, err := n.Load()
+	assert.ErrorContains(t, err, "NVIDIA-SMI has failed")
+}
+
+func TestNotAvailableWithoutBinary(t *testing.T) {
+	n := &nvidiaSMICommand{executor: gpu.NewScriptedExecutor()}
+	assert.False(t, n.Available())
+}
+
+func TestDriverInfoWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout([]byte(versionInfo), "nvidia-smi", "--version").
+		Stdout([]byte("/usr/bin/nvidia-smi\n"), "which", "nvidia-smi")
+	n := &nvidiaSMICommand{executor: fake}
+
+	info, err := n.DriverInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "NVIDIA", info.Vendor)
+	assert.Equal(t, "550.54.14", info.Version)
+	assert.Equal(t, "/usr/bin/nvidia-smi", info.InstallPath)
+}
+
+func argvs(calls []gpu.Command) [][]string {
+	var out [][]string
+	for _, c := range calls {
+		out = append(out, c.Argv())
+	}
+	return out
+}
+
+var _ gpu.ExecutorSetter = &nvidiaSMICommand{}
+
+//go:embed testdata/compute_apps.txt
+var computeApps []byte
+
+func TestProcessesWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().
+		Stdout([]byte("0, 00000000:16:00.0\n1, 00000000:19:00.0\n2, 00000000:1A:00.0\n3, 00000000:1D:00.0\n"),
+			"nvidia-smi", "--format=csv,noheader", "--query-gpu=index,pci.bus_id").
+		Stdout(computeApps, "nvidia-smi", "--format=csv,noheader", "--query-compute-apps=gpu_bus_id,pid,used_memory,process_name")
+	n := &nvidiaSMICommand{executor: fake, lightweight: true}
+
+	processes, err := n.Processes(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []gpu.GPUProcess{
+		{PID: 4188, Name: "/usr/bin/python3", DeviceIndex: 0, MemoryUsed: gpu.Uint(2046 * 1024 * 1024)},
+		{PID: 5121, Name: "python3 train.py --layers=12,24", DeviceIndex: 2, MemoryUsed: gpu.Uint(286 * 1024 * 1024)},
+		{PID: 5122, Name: "tritonserver", DeviceIndex: 3, MemoryUsed: gpu.UnsupportedUint()},
+	}, processes)
+}
+
+func TestParseComputeAppsEmpty(t *testing.T) {
+	processes, err := parseComputeApps([]byte("0, 00000000:16:00.0\n"), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, processes)
+
+	_, err = parseComputeApps([]byte("0, 00000000:16:00.0\n"), []byte("00000000:99:00.0, 1, 1 MiB, x\n"))
+	assert.Error(t, err)
+}
+
+var _ gpu.ProcessLister = &nvidiaSMICommand{}
@@ -0,0 +1,17 @@
+package nvidia
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+// Topology reads the links between the GPUs from "nvidia-smi topo -m".
+func (n *nvidiaSMICommand) Topology(ctx context.Context) (*gpu.Topology, error) {
+	output, err := gpu.Output(ctx, n.executor, "nvidia-smi", "topo", "-m")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute nvidia-smi topo: %v", err)
+	}
+	return gpu.ParseTopologyMatrix(output, "GPU")
+}
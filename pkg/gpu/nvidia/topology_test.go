@@ -0,0 +1,32 @@
+package nvidia
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/nvidia-smi-topo.txt
+var topoOutput []byte
+
+func TestTopology(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Stdout(topoOutput, "nvidia-smi", "topo", "-m")
+	n := New()
+	n.SetExecutor(fake)
+
+	topo, err := n.Topology(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3}, topo.Devices)
+	assert.Equal(t, gpu.Link{Type: gpu.LinkNVLink, Bandwidth: gpu.BandwidthFabric, Count: 18}, topo.Link(0, 3))
+	assert.Equal(t, gpu.LinkSelf, topo.Link(2, 2).Type)
+
+	n.SetExecutor(gpu.NewScriptedExecutor().Fail(errors.New("exit status 9"), "nvidia-smi", "topo", "-m"))
+	_, err = n.Topology(context.Background())
+	assert.Error(t, err)
+}
+
+var _ gpu.TopologyReporter = &nvidiaSMICommand{}
@@ -0,0 +1,403 @@
+package nvidia
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+)
+
+// nvidiaSMILog mirrors the parts of "nvidia-smi -q -x" the loader reads. The
+// ix and dl tools print the same schema under other root elements.
+type nvidiaSMILog struct {
+	XMLName       xml.Name       `xml:"nvidia_smi_log"`
+	DriverVersion string         `xml:"driver_version"`
+	CUDAVersion   string         `xml:"cuda_version"`
+	GPUs          []nvidiaSMIGPU `xml:"gpu"`
+}
+
+type nvidiaSMIGPU struct {
+	ID              string               `xml:"id,attr"`
+	ProductName     string               `xml:"product_name"`
+	ProductBrand    string               `xml:"product_brand"`
+	Serial          string               `xml:"serial"`
+	UUID            string               `xml:"uuid"`
+	VBIOSVersion    string               `xml:"vbios_version"`
+	BoardPartNumber string               `xml:"board_part_number"`
+	MIGMode         nvidiaSMIMIGMode     `xml:"mig_mode"`
+	MIGDevices      []nvidiaSMIMIGDevice `xml:"mig_devices>mig_device"`
+	PCI             nvidiaSMIPCI         `xml:"pci"`
+	FanSpeed        string               `xml:"fan_speed"`
+	PState          string               `xml:"performance_state"`
+	EventReasons    nvidiaSMIReasons     `xml:"clocks_event_reasons"`
+	ThrottleReasons nvidiaSMIReasons     `xml:"clocks_throttle_reasons"` // before driver 535
+	FBMemoryUsage   nvidiaSMIMemory      `xml:"fb_memory_usage"`
+	Utilization     nvidiaSMIUtilization `xml:"utilization"`
+	ECCMode         nvidiaSMIECCMode     `xml:"ecc_mode"`
+	ECCErrors       nvidiaSMIECCErrors   `xml:"ecc_errors"`
+	RetiredPages    nvidiaSMIRetired     `xml:"retired_pages"`
+	RemappedRows    nvidiaSMIRemapped    `xml:"remapped_rows"`
+	RecoveryAction  string               `xml:"gpu_recovery_action"` // driver 550 and later
+	Temperature     nvidiaSMITemperature `xml:"temperature"`
+	GPUPower        nvidiaSMIPower       `xml:"gpu_power_readings"`
+	Power           nvidiaSMIPower       `xml:"power_readings"` // before driver 535
+	Clocks          nvidiaSMIClocks      `xml:"clocks"`
+	MaxClocks       nvidiaSMIClocks      `xml:"max_clocks"`
+	Processes       []nvidiaSMIProcess   `xml:"processes>process_info"`
+}
+
+type nvidiaSMIPCI struct {
+	BusID    string `xml:"pci_bus_id"`
+	DeviceID string `xml:"pci_device_id"`
+	Link     struct {
+		MaxGen       string `xml:"pcie_gen>max_link_gen"`
+		CurrentGen   string `xml:"pcie_gen>current_link_gen"`
+		MaxWidth     string `xml:"link_widths>max_link_width"`
+		CurrentWidth string `xml:"link_widths>current_link_width"`
+	} `xml:"pci_gpu_link_info"`
+}
+
+// nvidiaSMIReasons holds one element per clock event reason, such as
+// <clocks_event_reason_sw_power_cap>Active</...>.
+type nvidiaSMIReasons struct {
+	Reasons []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+type nvidiaSMIMemory struct {
+	Total string `xml:"total"`
+	Used  string `xml:"used"`
+}
+
+type nvidiaSMIUtilization struct {
+	GPU string `xml:"gpu_util"`
+}
+
+type nvidiaSMIECCMode struct {
+	Current string `xml:"current_ecc"`
+	Pending string `xml:"pending_ecc"`
+}
+
+type nvidiaSMIECCErrors struct {
+	Volatile  nvidiaSMIECCCounts `xml:"volatile"`
+	Aggregate nvidiaSMIECCCounts `xml:"aggregate"`
+}
+
+// nvidiaSMIECCCounts reads both the SRAM/DRAM counters of current drivers
+// and the single/double bit totals of older ones.
+type nvidiaSMIECCCounts struct {
+	SRAMCorrectable   string `xml:"sram_correctable"`
+	SRAMUncorrectable string `xml:"sram_uncorrectable"`
+	DRAMCorrectable   string `xml:"dram_correctable"`
+	DRAMUncorrectable string `xml:"dram_uncorrectable"`
+	SingleBitTotal    string `xml:"single_bit>total"`
+	DoubleBitTotal    string `xml:"double_bit>total"`
+}
+
+// nvidiaSMIRetired is the page retirement of GPUs before Ampere.
+type nvidiaSMIRetired struct {
+	SingleBit         string `xml:"multiple_single_bit_retirement>retired_count"`
+	DoubleBit         string `xml:"double_bit_retirement>retired_count"`
+	PendingBlacklist  string `xml:"pending_blacklist"`
+	PendingRetirement string `xml:"pending_retirement"`
+}
+
+// nvidiaSMIRemapped is the row remapping that replaced page retirement on
+// Ampere and later GPUs.
+type nvidiaSMIRemapped struct {
+	Correctable   string `xml:"remapped_row_corr"`
+	Uncorrectable string `xml:"remapped_row_unc"`
+	Pending       string `xml:"remapped_row_pending"`
+}
+
+type nvidiaSMITemperature struct {
+	GPU    string `xml:"gpu_temp"`
+	Memory string `xml:"memory_temp"`
+}
+
+type nvidiaSMIPower struct {
+	PowerDraw          string `xml:"power_draw"`
+	CurrentPowerLimit  string `xml:"current_power_limit"`
+	EnforcedPowerLimit string `xml:"enforced_power_limit"`
+}
+
+type nvidiaSMIClocks struct {
+	Graphics string `xml:"graphics_clock"`
+	Memory   string `xml:"mem_clock"`
+}
+
+type nvidiaSMIProcess struct {
+	PID        string `xml:"pid"`
+	Name       string `xml:"process_name"`
+	UsedMemory string `xml:"used_memory"`
+}
+
+func decodeNvidiaSMIXML(output []byte) (*nvidiaSMILog, error) {
+	var log nvidiaSMILog
+	if err := xml.Unmarshal(output, &log); err != nil {
+		return nil, fmt.Errorf("failed to parse nvidia-smi XML output: %v", err)
+	}
+	return &log, nil
+}
+
+// parseXML parses the output of "nvidia-smi -q -x". GPUs are listed in
+// index order, so Num is the position in the output.
+func parseXML(output []byte) (*gpu.GPUInfoList, error) {
+	log, err := decodeNvidiaSMIXML(output)
+	if err != nil {
+		return nil, err
+	}
+	result := &gpu.GPUInfoList{GPUInfos: make([]gpu.GPUInfo, 0, len(log.GPUs))}
+	for i, g := range log.GPUs {
+		result.GPUInfos = append(result.GPUInfos, xmlGPUInfo(i, g))
+	}
+	return result, nil
+}
+
+func xmlGPUInfo(index int, g nvidiaSMIGPU) gpu.GPUInfo {
+	power := g.GPUPower
+	if power.PowerDraw == "" {
+		power = g.Power
+	}
+	powerLimit := power.CurrentPowerLimit
+	if powerLimit == "" {
+		powerLimit = power.EnforcedPowerLimit
+	}
+	metrics := gpu.GPUMetrics{
+		TemperatureEdge:             gpu.ParseFloatMetric(g.Temperature.GPU),
+		TemperatureMemory:           gpu.ParseFloatMetric(g.Temperature.Memory),
+		AverageGraphicsPackagePower: gpu.ParseFloatMetric(power.PowerDraw),
+		GPUUse:                      gpu.ParseFloatMetric(g.Utilization.GPU),
+		VRAMTotalMemory:             parseMiB(g.FBMemoryUsage.Total),
+		VRAMTotalUsedMemory:         parseMiB(g.FBMemoryUsage.Used),
+		PowerLimit:                  gpu.ParseFloatMetric(powerLimit),
+		FanSpeed:                    gpu.ParseFloatMetric(g.FanSpeed),
+		// nvidia-smi reports a single die temperature, as in the CSV mode.
+		TemperatureJunction: gpu.UnsupportedFloat(),
+	}
+
+	pciBus := strings.TrimSpace(g.PCI.BusID)
+	if pciBus == "" {
+		pciBus = g.ID
+	}
+	vendor := strings.TrimSpace(g.ProductBrand)
+	if vendor == "" || gpu.IsNotSupported(vendor) {
+		vendor = "NVIDIA"
+	}
+
+	info := gpu.GPUInfo{
+		Num:                         index,
+		DeviceID:                    strconv.Itoa(index),
+		TemperatureEdge:             formatFloat(metrics.TemperatureEdge),
+		TemperatureJunction:         formatFloat(metrics.TemperatureEdge), // see GPUInfo.Metrics
+		TemperatureMemory:           formatFloat(metrics.TemperatureMemory),
+		AverageGraphicsPackagePower: formatFloat(metrics.AverageGraphicsPackagePower),
+		GPUUse:                      formatFloat(metrics.GPUUse),
+		SerialNumber:                notSupportedToEmpty(g.Serial),
+		VRAMTotalMemory:             metrics.VRAMTotalMemory.String(),
+		VRAMTotalUsedMemory:         metrics.VRAMTotalUsedMemory.String(),
+		CardSeries:                  "NVIDIA",
+		CardModel:                   strings.TrimSpace(g.ProductName),
+		CardVendor:                  vendor,
+		CardSKU:                     notSupportedToEmpty(g.BoardPartNumber),
+		PCIBus:                      gpu.CanonicalPCIAddress(pciBus),
+		UUID:                        notSupportedToEmpty(g.UUID),
+		VBIOSVersion:                notSupportedToEmpty(g.VBIOSVersion),
+		Metrics:                     metrics,
+		ECC:                         xmlECC(g),
+		Clocks: &gpu.Clocks{
+			Graphics:    gpu.ParseFloatMetric(g.Clocks.Graphics),
+			Memory:      gpu.ParseFloatMetric(g.Clocks.Memory),
+			MaxGraphics: gpu.ParseFloatMetric(g.MaxClocks.Graphics),
+			MaxMemory:   gpu.ParseFloatMetric(g.MaxClocks.Memory),
+		},
+		PCIeLink: &gpu.PCIeLink{
+			Generation:    gpu.ParseUintMetric(g.PCI.Link.CurrentGen),
+			MaxGeneration: gpu.ParseUintMetric(g.PCI.Link.MaxGen),
+			Width:         parseLinkWidth(g.PCI.Link.CurrentWidth),
+			MaxWidth:      parseLinkWidth(g.PCI.Link.MaxWidth),
+		},
+		PState:          notSupportedToEmpty(g.PState),
+		ThrottleReasons: activeReasons(g.EventReasons, "clocks_event_reason_"),
+		MIG:             xmlMIG(g),
+	}
+	if len(info.ThrottleReasons) == 0 {
+		info.ThrottleReasons = activeReasons(g.ThrottleReasons, "clocks_throttle_reason_")
+	}
+	info.Health = xmlHealth(g, info)
+	return info
+}
+
+// xmlHealth adds to gpu.CheckHealth what only the XML tells: pages or rows
+// waiting for a reset to be retired, the recovery action the driver asks
+// for, and GPUs that have fallen off the bus, whose readings nvidia-smi
+// replaces with "GPU is lost". It returns nil if none of these is known.
+func xmlHealth(g nvidiaSMIGPU, info gpu.GPUInfo) *gpu.Health {
+	var reasons []gpu.HealthReason
+	for _, pending := range []string{g.RetiredPages.PendingBlacklist, g.RetiredPages.PendingRetirement, g.RemappedRows.Pending} {
+		if strings.EqualFold(strings.TrimSpace(pending), "Yes") {
+			reasons = append(reasons, gpu.ReasonRetiredPagesPending)
+		}
+	}
+	// A recovery action of "None" is the driver saying the GPU is fine.
+	reported := false
+	switch action := strings.TrimSpace(g.RecoveryAction); {
+	case action == "", gpu.IsNotSupported(action):
+	case strings.EqualFold(action, "None"):
+		reported = true
+	default:
+		// "Reset", "Reboot", "Drain P2P" or "Drain and Reset".
+		reasons = append(reasons, gpu.ReasonResetRequired)
+	}
+	for _, reading := range []string{g.ProductName, g.UUID, g.FBMemoryUsage.Total, g.Temperature.GPU} {
+		if strings.Contains(strings.ToLower(reading), "gpu is lost") {
+			reasons = append(reasons, gpu.ReasonDeviceLost)
+			break
+		}
+	}
+
+	h := gpu.CheckHealth(info)
+	if h == nil {
+		if !reported && len(reasons) == 0 {
+			return nil
+		}
+		h = gpu.NewHealth()
+	}
+	for _, reason := range reasons {
+		h.Add(reason)
+	}
+	return h
+}
+
+// xmlECC returns the ECC state, or nil for GPUs without ECC memory, for
+// which nvidia-smi prints N/A.
+func xmlECC(g nvidiaSMIGPU) *gpu.ECCInfo {
+	mode := gpu.ParseMode(g.ECCMode.Current)
+	if mode == "" {
+		return nil
+	}
+	return &gpu.ECCInfo{
+		Mode:         mode,
+		PendingMode:  gpu.ParseMode(g.ECCMode.Pending),
+		Volatile:     eccCounts(g.ECCErrors.Volatile),
+		Aggregate:    xmlAggregate(g),
+		RetiredPages: xmlRetiredPages(g),
+	}
+}
+
+// xmlAggregate returns the lifetime ECC counts, which nvidia-smi omits for
+// some GPUs.
+func xmlAggregate(g nvidiaSMIGPU) *gpu.ECCCounts {
+	counts := eccCounts(g.ECCErrors.Aggregate)
+	if !counts.Corrected.Available() && !counts.Uncorrected.Available() {
+		return nil
+	}
+	return &counts
+}
+
+// xmlRetiredPages returns the remapped rows of GPUs that remap them, else
+// the retired pages, or nil when nvidia-smi reports neither.
+func xmlRetiredPages(g nvidiaSMIGPU) *gpu.RetiredPages {
+	pages := &gpu.RetiredPages{
+		Correctable:   gpu.ParseUintMetric(g.RemappedRows.Correctable),
+		Uncorrectable: gpu.ParseUintMetric(g.RemappedRows.Uncorrectable),
+	}
+	if !pages.Correctable.Available() && !pages.Uncorrectable.Available() {
+		pages.Correctable = gpu.ParseUintMetric(g.RetiredPages.SingleBit)
+		pages.Uncorrectable = gpu.ParseUintMetric(g.RetiredPages.DoubleBit)
+	}
+	if !pages.Correctable.Available() && !pages.Uncorrectable.Available() {
+		return nil
+	}
+	for _, pending := range []string{g.RetiredPages.PendingBlacklist, g.RetiredPages.PendingRetirement, g.RemappedRows.Pending} {
+		if strings.EqualFold(strings.TrimSpace(pending), "Yes") {
+			pages.Pending = true
+		}
+	}
+	return pages
+}
+
+func eccCounts(c nvidiaSMIECCCounts) gpu.ECCCounts {
+	if c.SingleBitTotal != "" || c.DoubleBitTotal != "" {
+		return gpu.ECCCounts{
+			Corrected:   gpu.ParseUintMetric(c.SingleBitTotal),
+			Uncorrected: gpu.ParseUintMetric(c.DoubleBitTotal),
+		}
+	}
+	return gpu.ECCCounts{
+		Corrected:   sumCounts(c.SRAMCorrectable, c.DRAMCorrectable),
+		Uncorrected: sumCounts(c.SRAMUncorrectable, c.DRAMUncorrectable),
+	}
+}
+
+// sumCounts adds the counters nvidia-smi reports, skipping N/A ones. The sum
+// is unsupported only if every counter is.
+func sumCounts(values ...string) gpu.UintMetric {
+	sum := gpu.UnsupportedUint()
+	for _, v := range values {
+		m := gpu.ParseUintMetric(v)
+		if !m.Available() {
+			continue
+		}
+		if !sum.Available() {
+			sum = gpu.Uint(0)
+		}
+		sum.Value += m.Value
+	}
+	return sum
+}
+
+// activeReasons returns the reasons reported "Active", without prefix, e.g.
+// "sw_power_cap". The GPU being idle is not throttling and is skipped.
+func activeReasons(r nvidiaSMIReasons, prefix string) []string {
+	var active []string
+	for _, reason := range r.Reasons {
+		name := strings.TrimPrefix(reason.XMLName.Local, prefix)
+		if name == "gpu_idle" || strings.TrimSpace(reason.Value) != "Active" {
+			continue
+		}
+		active = append(active, name)
+	}
+	return active
+}
+
+// parseLinkWidth parses a PCIe link width such as "16x".
+func parseLinkWidth(value string) gpu.UintMetric {
+	return gpu.ParseUintMetric(strings.TrimSuffix(strings.TrimSpace(value), "x"))
+}
+
+func notSupportedToEmpty(value string) string {
+	value = strings.TrimSpace(value)
+	if gpu.IsNotSupported(value) {
+		return ""
+	}
+	return value
+}
+
+// parseXMLProcesses returns the processes of every GPU in "nvidia-smi -q -x".
+func parseXMLProcesses(output []byte) ([]gpu.GPUProcess, error) {
+	log, err := decodeNvidiaSMIXML(output)
+	if err != nil {
+		return nil, err
+	}
+	processes := []gpu.GPUProcess{}
+	for i, g := range log.GPUs {
+		for _, p := range g.Processes {
+			pid, err := strconv.Atoi(strings.TrimSpace(p.PID))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pid %q: %v", p.PID, err)
+			}
+			processes = append(processes, gpu.GPUProcess{
+				PID:         pid,
+				Name:        strings.TrimSpace(p.Name),
+				DeviceIndex: i,
+				MemoryUsed:  parseMiB(p.UsedMemory),
+			})
+		}
+	}
+	return processes, nil
+}
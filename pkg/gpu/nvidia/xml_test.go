@@ -0,0 +1,181 @@
+package nvidia
+
+import (
+	"context"
+	_ "embed"
+	"testing"
+
+	"github.com/hawkli-1994/gpu_tools/pkg/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/nvidia-smi-q.xml
+var xmlOutput []byte
+
+func TestParseXML(t *testing.T) {
+	list, err := parseXML(xmlOutput)
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+
+	gpu0 := list.GPUInfos[0]
+	assert.Equal(t, 0, gpu0.Num)
+	assert.Equal(t, "NVIDIA A100-SXM4-80GB", gpu0.CardModel)
+	assert.Equal(t, "NVIDIA", gpu0.CardVendor)
+	assert.Equal(t, "1323021010463", gpu0.SerialNumber)
+	assert.Equal(t, "GPU-4a6c3d5e-8f1b-2c7d-9e0a-1b2c3d4e5f60", gpu0.UUID)
+	assert.Equal(t, "92.00.45.00.03", gpu0.VBIOSVersion)
+	assert.Equal(t, "692-2G506-0210-002", gpu0.CardSKU)
+	assert.Equal(t, "0000:07:00.0", gpu0.PCIBus)
+	assert.Equal(t, "398.3", gpu0.AverageGraphicsPackagePower)
+	assert.Equal(t, gpu.GPUMetrics{
+		TemperatureEdge:             gpu.Float(67),
+		TemperatureJunction:         gpu.UnsupportedFloat(),
+		TemperatureMemory:           gpu.Float(74),
+		AverageGraphicsPackagePower: gpu.Float(398.27),
+		GPUUse:                      gpu.Float(98),
+		VRAMTotalMemory:             gpu.Uint(81920 << 20),
+		VRAMTotalUsedMemory:         gpu.Uint(40532 << 20),
+		PowerLimit:                  gpu.Float(400),
+		FanSpeed:                    gpu.UnsupportedFloat(),
+	}, gpu0.Metrics)
+	assert.Equal(t, &gpu.ECCInfo{
+		Mode:         "enabled",
+		PendingMode:  "enabled",
+		Volatile:     gpu.ECCCounts{Corrected: gpu.Uint(2), Uncorrected: gpu.Uint(0)},
+		Aggregate:    &gpu.ECCCounts{Corrected: gpu.Uint(14), Uncorrected: gpu.Uint(1)},
+		RetiredPages: &gpu.RetiredPages{Correctable: gpu.Uint(0), Uncorrectable: gpu.Uint(1)},
+	}, gpu0.ECC, "A100 reports remapped rows, not retired pages")
+	assert.Equal(t, &gpu.Clocks{
+		Graphics:    gpu.Float(1275),
+		Memory:      gpu.Float(1593),
+		MaxGraphics: gpu.Float(1410),
+		MaxMemory:   gpu.Float(1593),
+	}, gpu0.Clocks)
+	assert.Equal(t, &gpu.PCIeLink{
+		Generation:    gpu.Uint(4),
+		MaxGeneration: gpu.Uint(4),
+		Width:         gpu.Uint(16),
+		MaxWidth:      gpu.Uint(16),
+	}, gpu0.PCIeLink)
+	assert.Equal(t, "P0", gpu0.PState)
+	assert.Equal(t, []string{"sw_power_cap"}, gpu0.ThrottleReasons)
+	assert.Equal(t, &gpu.Health{Status: gpu.HealthOK}, gpu0.Health, "power capping is not a fault")
+
+	gpu1 := list.GPUInfos[1]
+	assert.Equal(t, 1, gpu1.Num)
+	assert.Equal(t, "0000:0f:00.0", gpu1.PCIBus)
+	assert.Equal(t, gpu.Uint(1), gpu1.PCIeLink.Generation, "link trained down while idle")
+	assert.Empty(t, gpu1.ThrottleReasons, "idle is not throttling")
+}
+
+func TestParseXMLOlderDriver(t *testing.T) {
+	// Drivers before 535 use power_readings, clocks_throttle_reasons and
+	// single/double bit ECC totals. GeForce cards have no ECC.
+	output := `<nvidia_smi_log>
+	<gpu id="00000000:01:00.0">
+		<product_name>Tesla V100-PCIE-32GB</product_name>
+		<serial>0323718002113</serial>
+		<uuid>GPU-0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d</uuid>
+		<fan_speed>N/A</fan_speed>
+		<clocks_throttle_reasons>
+			<clocks_throttle_reason_gpu_idle>Not Active</clocks_throttle_reason_gpu_idle>
+			<clocks_throttle_reason_hw_thermal_slowdown>Active</clocks_throttle_reason_hw_thermal_slowdown>
+		</clocks_throttle_reasons>
+		<ecc_mode><current_ecc>Enabled</current_ecc></ecc_mode>
+		<ecc_errors>
+			<volatile>
+				<single_bit><device_memory>3</device_memory><total>3</total></single_bit>
+				<double_bit><device_memory>0</device_memory><total>0</total></double_bit>
+			</volatile>
+			<aggregate>
+				<single_bit><device_memory>118</device_memory><total>118</total></single_bit>
+				<double_bit><device_memory>2</device_memory><total>2</total></double_bit>
+			</aggregate>
+		</ecc_errors>
+		<retired_pages>
+			<multiple_single_bit_retirement><retired_count>1</retired_count></multiple_single_bit_retirement>
+			<double_bit_retirement><retired_count>2</retired_count></double_bit_retirement>
+			<pending_retirement>No</pending_retirement>
+		</retired_pages>
+		<power_readings>
+			<power_draw>37.28 W</power_draw>
+			<power_limit>250.00 W</power_limit>
+			<enforced_power_limit>250.00 W</enforced_power_limit>
+		</power_readings>
+	</gpu>
+	<gpu id="00000000:02:00.0">
+		<product_name>NVIDIA GeForce RTX 3090</product_name>
+		<fan_speed>41 %</fan_speed>
+		<ecc_mode><current_ecc>N/A</current_ecc></ecc_mode>
+	</gpu>
+</nvidia_smi_log>`
+
+	list, err := parseXML([]byte(output))
+	assert.NoError(t, err)
+	v100 := list.GPUInfos[0]
+	assert.Equal(t, "0000:01:00.0", v100.PCIBus)
+	assert.Equal(t, gpu.Float(37.28), v100.Metrics.AverageGraphicsPackagePower)
+	assert.Equal(t, gpu.Float(250), v100.Metrics.PowerLimit)
+	assert.Equal(t, []string{"hw_thermal_slowdown"}, v100.ThrottleReasons)
+	assert.Equal(t, gpu.ECCCounts{Corrected: gpu.Uint(3), Uncorrected: gpu.Uint(0)}, v100.ECC.Volatile)
+	assert.Equal(t, &gpu.ECCCounts{Corrected: gpu.Uint(118), Uncorrected: gpu.Uint(2)}, v100.ECC.Aggregate)
+	assert.Equal(t, &gpu.RetiredPages{Correctable: gpu.Uint(1), Uncorrectable: gpu.Uint(2)}, v100.ECC.RetiredPages)
+	assert.Equal(t, gpu.NewHealth(gpu.ReasonThermalThrottle), v100.Health)
+
+	rtx := list.GPUInfos[1]
+	assert.Nil(t, rtx.ECC)
+	assert.Equal(t, gpu.Float(41), rtx.Metrics.FanSpeed)
+	assert.Equal(t, "", rtx.SerialNumber)
+
+	_, err = parseXML([]byte("NVIDIA-SMI has failed"))
+	assert.Error(t, err)
+}
+
+func TestParseXMLHealth(t *testing.T) {
+	output := `<nvidia_smi_log>
+	<gpu id="00000000:07:00.0">
+		<product_name>NVIDIA A100-SXM4-80GB</product_name>
+		<ecc_mode><current_ecc>Enabled</current_ecc></ecc_mode>
+		<ecc_errors><volatile><dram_uncorrectable>1</dram_uncorrectable></volatile></ecc_errors>
+		<remapped_rows><remapped_row_pending>Yes</remapped_row_pending></remapped_rows>
+		<gpu_recovery_action>Reset</gpu_recovery_action>
+	</gpu>
+	<gpu id="00000000:0F:00.0">
+		<product_name>GPU is lost</product_name>
+		<uuid>GPU is lost</uuid>
+		<gpu_recovery_action>None</gpu_recovery_action>
+	</gpu>
+	<gpu id="00000000:10:00.0">
+		<product_name>Tesla V100-SXM2-32GB</product_name>
+		<retired_pages><pending_retirement>Yes</pending_retirement></retired_pages>
+	</gpu>
+</nvidia_smi_log>`
+
+	list, err := parseXML([]byte(output))
+	assert.NoError(t, err)
+	assert.Equal(t, &gpu.Health{
+		Status:  gpu.HealthCritical,
+		Reasons: []gpu.HealthReason{gpu.ReasonECCUncorrectable, gpu.ReasonRetiredPagesPending, gpu.ReasonResetRequired},
+	}, list.GPUInfos[0].Health)
+	assert.Equal(t, gpu.NewHealth(gpu.ReasonDeviceLost), list.GPUInfos[1].Health)
+	assert.Equal(t, &gpu.Health{
+		Status:  gpu.HealthWarning,
+		Reasons: []gpu.HealthReason{gpu.ReasonRetiredPagesPending},
+	}, list.GPUInfos[2].Health)
+}
+
+func TestLoadXMLWithScriptedExecutor(t *testing.T) {
+	fake := gpu.NewScriptedExecutor().Stdout(xmlOutput, "nvidia-smi", "-q", "-x")
+	n := New()
+	n.SetExecutor(fake)
+
+	list, err := n.Load()
+	assert.NoError(t, err)
+	assert.Len(t, list.GPUInfos, 2)
+
+	processes, err := n.Processes(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []gpu.GPUProcess{
+		{PID: 31415, Name: "python3 train.py", DeviceIndex: 0, MemoryUsed: gpu.Uint(40512 << 20)},
+	}, processes)
+}
@@ -0,0 +1,198 @@
+package gpu
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PCIAddress is the domain, bus, device and function of a PCI function.
+// Vendor tools print it in different forms, e.g. "00000000:07:00.0" from
+// nvidia-smi and "0000:0C:00.0" from npu-smi; String gives the form sysfs
+// uses, so addresses from any loader can be compared with each other and
+// with /sys/bus/pci/devices.
+type PCIAddress struct {
+	Domain   uint32
+	Bus      uint8
+	Device   uint8
+	Function uint8
+}
+
+// ParsePCIAddress parses an address of the form "domain:bus:device.function"
+// in hexadecimal, in either case. The domain may have up to 8 digits, or be
+// left out for domain 0, as in "0c:00.0".
+func ParsePCIAddress(s string) (PCIAddress, error) {
+	var a PCIAddress
+	rest, function, ok := strings.Cut(strings.TrimSpace(s), ".")
+	if !ok {
+		return a, fmt.Errorf("invalid PCI address %q", s)
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+	if len(parts) != 3 {
+		return a, fmt.Errorf("invalid PCI address %q", s)
+	}
+	domain, err := parsePCIField(parts[0], 8, 32)
+	if err != nil {
+		return a, fmt.Errorf("invalid PCI domain in %q", s)
+	}
+	bus, err := parsePCIField(parts[1], 2, 8)
+	if err != nil {
+		return a, fmt.Errorf("invalid PCI bus in %q", s)
+	}
+	device, err := parsePCIField(parts[2], 2, 5)
+	if err != nil {
+		return a, fmt.Errorf("invalid PCI device in %q", s)
+	}
+	fn, err := parsePCIField(function, 1, 3)
+	if err != nil {
+		return a, fmt.Errorf("invalid PCI function in %q", s)
+	}
+	return PCIAddress{Domain: uint32(domain), Bus: uint8(bus), Device: uint8(device), Function: uint8(fn)}, nil
+}
+
+// parsePCIField parses a hexadecimal field of at most digits digits whose
+// value fits in bits bits.
+func parsePCIField(s string, digits, bits int) (uint64, error) {
+	if s == "" || len(s) > digits {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseUint(s, 16, bits)
+}
+
+// String returns the address as "dddd:bb:dd.f" in lower case, as in the
+// names of /sys/bus/pci/devices.
+func (a PCIAddress) String() string {
+	return fmt.Sprintf("%04x:%02x:%02x.%x", a.Domain, a.Bus, a.Device, a.Function)
+}
+
+// Compare returns -1, 0 or +1 depending on whether a sorts before, the same
+// as or after b, in the order of the PCI hierarchy.
+func (a PCIAddress) Compare(b PCIAddress) int {
+	if c := cmp.Compare(a.Domain, b.Domain); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Bus, b.Bus); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Device, b.Device); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Function, b.Function)
+}
+
+// CanonicalPCIAddress returns s in the canonical form of PCIAddress.String.
+// Loaders use it for GPUInfo.PCIBus; values that are not a PCI address,
+// such as "" or "N/A", are returned unchanged.
+func CanonicalPCIAddress(s string) string {
+	a, err := ParsePCIAddress(s)
+	if err != nil {
+		return s
+	}
+	return a.String()
+}
+
+// pciVendors maps PCI vendor IDs, as written in sysfs, to the Vendor() of
+// the loaders.
+var pciVendors = map[string]string{
+	"0x10de": "NVIDIA",
+	"0x1002": "AMD",
+	"0x19e5": "Huawei",
+	"0x1e36": "Enflame",
+	"0x9999": "mx",
+	"0x1e3e": "Iluvatar",
+	"0x1e27": "Denglin",
+}
+
+// PCIDevice is a display controller or processing accelerator found on the
+// PCI bus, whether or not a driver is bound to it.
+type PCIDevice struct {
+	Address  string `json:"address"`   // e.g. "0000:17:00.0"
+	Vendor   string `json:"vendor"`    // loader vendor name; empty for vendors without a loader
+	VendorID string `json:"vendor_id"` // e.g. "0x10de"
+	DeviceID string `json:"device_id"` // e.g. "0x26b9"
+	Class    string `json:"class"`     // e.g. "0x030200"
+	Driver   string `json:"driver"`    // bound kernel driver; empty when none is
+}
+
+// Driven reports whether a kernel driver is bound to the device.
+func (d PCIDevice) Driven() bool {
+	return d.Driver != ""
+}
+
+// PCIEnumerator lists GPUs and accelerators from /sys/bus/pci/devices
+// without running any vendor tool, so devices are found even when their
+// driver or SMI is missing or broken. It only finds anything on Linux.
+type PCIEnumerator struct {
+	// Root is prepended to every path read, so tests can point the
+	// enumerator at a fake sysfs tree.
+	Root string
+}
+
+// DefaultPCIEnumerator reads the running system.
+var DefaultPCIEnumerator = &PCIEnumerator{Root: "/"}
+
+// Devices returns the display and processing accelerator devices ordered by
+// PCI address. Other functions of the same cards, such as HDMI audio, are
+// skipped. A missing /sys/bus/pci/devices is an error.
+func (e *PCIEnumerator) Devices() ([]PCIDevice, error) {
+	dir := filepath.Join(e.Root, "sys", "bus", "pci", "devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []PCIDevice{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		class := readSysfs(path, "class")
+		if !isAcceleratorClass(class) {
+			continue
+		}
+		dev := PCIDevice{
+			Address:  entry.Name(),
+			VendorID: readSysfs(path, "vendor"),
+			DeviceID: readSysfs(path, "device"),
+			Class:    class,
+		}
+		dev.Vendor = pciVendors[dev.VendorID]
+		if target, err := os.Readlink(filepath.Join(path, "driver")); err == nil {
+			dev.Driver = filepath.Base(target)
+		}
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return comparePCIAddresses(devices[i].Address, devices[j].Address) < 0 })
+	return devices, nil
+}
+
+// isAcceleratorClass reports whether a sysfs PCI class such as "0x030000"
+// is a display controller (0x03, which includes 3D controllers) or a
+// processing accelerator (0x12).
+func isAcceleratorClass(class string) bool {
+	return strings.HasPrefix(class, "0x03") || strings.HasPrefix(class, "0x12")
+}
+
+func readSysfs(elem ...string) string {
+	data, err := os.ReadFile(filepath.Join(elem...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// comparePCIAddresses compares two addresses with PCIAddress.Compare,
+// falling back to string order if either cannot be parsed.
+func comparePCIAddresses(a, b string) int {
+	pa, errA := ParsePCIAddress(a)
+	pb, errB := ParsePCIAddress(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return pa.Compare(pb)
+}
@@ -0,0 +1,82 @@
+package gpu
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPCIEnumeratorDevices(t *testing.T) {
+	e := &PCIEnumerator{Root: "testdata/pcitree"}
+	devices, err := e.Devices()
+	assert.NoError(t, err)
+	assert.Equal(t, []PCIDevice{
+		{Address: "0000:02:00.0", VendorID: "0x1a03", DeviceID: "0x2000", Class: "0x030000", Driver: "ast"},
+		{Address: "0000:0c:00.0", Vendor: "Enflame", VendorID: "0x1e36", DeviceID: "0xc035", Class: "0x120000", Driver: "enflame"},
+		{Address: "0000:17:00.0", Vendor: "NVIDIA", VendorID: "0x10de", DeviceID: "0x26b9", Class: "0x030200", Driver: "nvidia"},
+		{Address: "0000:1e:00.0", Vendor: "Denglin", VendorID: "0x1e27", DeviceID: "0x0006", Class: "0x030200"},
+		{Address: "0000:3d:00.0", Vendor: "Huawei", VendorID: "0x19e5", DeviceID: "0xd500", Class: "0x120001"},
+	}, devices)
+}
+
+func TestPCIEnumeratorWithoutSysfs(t *testing.T) {
+	_, err := (&PCIEnumerator{Root: t.TempDir()}).Devices()
+	assert.Error(t, err)
+}
+
+func TestDetectAllListsPCIDevices(t *testing.T) {
+	inv := DetectAll(context.Background(),
+		WithLoaders(&stubLoader{vendor: "Huawei"}),
+		WithPCIEnumerator(&PCIEnumerator{Root: "testdata/pcitree"}))
+
+	assert.Empty(t, inv.GPUs)
+	assert.Len(t, inv.PCIDevices, 5)
+	undriven := inv.Undriven()
+	assert.Len(t, undriven, 2)
+	assert.Equal(t, "Denglin", undriven[0].Vendor)
+	assert.Equal(t, "0000:3d:00.0", undriven[1].Address)
+
+	inv = DetectAll(context.Background(), WithLoaders(), WithPCIEnumerator(nil))
+	assert.Empty(t, inv.PCIDevices)
+	assert.NotNil(t, inv.PCIDevices)
+}
+
+func TestParsePCIAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0000:0c:00.0", "0000:0c:00.0"},
+		{"0000:0C:00.0", "0000:0c:00.0"},
+		{"00000000:1D:00.0", "0000:1d:00.0"},
+		{"00010000:81:1f.7", "10000:81:1f.7"},
+		{" 3b:00.1\n", "0000:3b:00.1"},
+	}
+	for _, tt := range tests {
+		a, err := ParsePCIAddress(tt.in)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, a.String(), tt.in)
+	}
+
+	for _, in := range []string{"", "N/A", "0000:0c:00", "0c.0", "0000:0c:20.0", "0000:0c:00.8", "0000:100:00.0", "1:2:3:4.0", "zz:00.0"} {
+		_, err := ParsePCIAddress(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestPCIAddressCompare(t *testing.T) {
+	a, _ := ParsePCIAddress("0000:1e:00.0")
+	b, _ := ParsePCIAddress("00000000:1E:00.0")
+	c, _ := ParsePCIAddress("0000:9a:00.0")
+	d, _ := ParsePCIAddress("0001:00:00.0")
+	assert.Equal(t, 0, a.Compare(b))
+	assert.Equal(t, -1, a.Compare(c))
+	assert.Equal(t, 1, d.Compare(c), "domain sorts before bus")
+}
+
+func TestCanonicalPCIAddress(t *testing.T) {
+	assert.Equal(t, "0000:01:00.0", CanonicalPCIAddress("00000000:01:00.0"))
+	assert.Equal(t, "N/A", CanonicalPCIAddress("N/A"))
+	assert.Equal(t, "", CanonicalPCIAddress(""))
+}
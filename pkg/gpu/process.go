@@ -0,0 +1,32 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// GPUProcess is a process holding memory on a device.
+type GPUProcess struct {
+	PID         int        `json:"pid"`
+	Name        string     `json:"name"`
+	DeviceIndex int        `json:"device_index"` // GPUInfo.Num of the device
+	MemoryUsed  UintMetric `json:"memory_used_bytes"`
+}
+
+// ProcessLister is implemented by loaders that can list the processes using
+// their devices. A loader whose tool reports no processes returns an empty
+// slice and no error.
+type ProcessLister interface {
+	Processes(ctx context.Context) ([]GPUProcess, error)
+}
+
+// ListProcesses returns the processes using loader's devices, or
+// errors.ErrUnsupported if loader cannot list them.
+func ListProcesses(ctx context.Context, loader GPUInfoLoader) ([]GPUProcess, error) {
+	l, ok := loader.(ProcessLister)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot list processes: %w", loader.Vendor(), errors.ErrUnsupported)
+	}
+	return l.Processes(ctx)
+}
@@ -0,0 +1,29 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type processStubLoader struct {
+	stubLoader
+	processes []GPUProcess
+}
+
+func (p *processStubLoader) Processes(ctx context.Context) ([]GPUProcess, error) {
+	return p.processes, nil
+}
+
+func TestListProcesses(t *testing.T) {
+	want := []GPUProcess{{PID: 42, Name: "python3", DeviceIndex: 1, MemoryUsed: Uint(1 << 30)}}
+	got, err := ListProcesses(context.Background(), &processStubLoader{processes: want})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = ListProcesses(context.Background(), &stubLoader{vendor: "AMD"})
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	assert.ErrorContains(t, err, "AMD")
+}
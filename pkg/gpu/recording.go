@@ -0,0 +1,235 @@
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// RecordedCall is one command captured by a RecordingExecutor.
+type RecordedCall struct {
+	Argv     []string      `json:"argv"`
+	Env      []string      `json:"env,omitempty"` // variables of the command that differ from the recorder's environment
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"` // set when the command failed other than by exiting non-zero
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// RecordedLookup is one LookPath call captured by a RecordingExecutor.
+type RecordedLookup struct {
+	File  string `json:"file"`
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Bundle is the on-disk form of a recording. It is plain JSON so that it
+// can be attached to bug reports and checked in as testdata.
+type Bundle struct {
+	Calls   []RecordedCall   `json:"calls"`
+	Lookups []RecordedLookup `json:"lookups,omitempty"`
+}
+
+// ReadBundle reads a bundle written by RecordingExecutor.WriteFile.
+func ReadBundle(path string) (Bundle, error) {
+	var b Bundle
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("failed to parse bundle %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// RecordingExecutor runs commands through another Executor and captures
+// every call, so a field issue can be reproduced offline with a
+// ReplayExecutor.
+type RecordingExecutor struct {
+	next Executor
+
+	mu     sync.Mutex
+	bundle Bundle
+}
+
+// NewRecordingExecutor records the commands run through next. A nil next
+// records real commands run with OSExecutor.
+func NewRecordingExecutor(next Executor) *RecordingExecutor {
+	if next == nil {
+		next = OSExecutor{}
+	}
+	return &RecordingExecutor{next: next}
+}
+
+func (r *RecordingExecutor) Run(ctx context.Context, cmd Command) (*Result, error) {
+	start := time.Now()
+	result, err := r.next.Run(ctx, cmd)
+	call := RecordedCall{
+		Argv:     cmd.Argv(),
+		Env:      changedEnv(cmd.Env, os.Environ()),
+		Duration: time.Since(start),
+	}
+	if result != nil {
+		call.Stdout = string(result.Stdout)
+		call.Stderr = string(result.Stderr)
+		call.ExitCode = result.ExitCode
+	}
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		// Replayed as an *ExitError again from the exit code.
+		call.ExitCode = exitErr.ExitCode
+		if result == nil {
+			call.Stderr = string(exitErr.Stderr)
+		}
+	case err != nil:
+		call.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.bundle.Calls = append(r.bundle.Calls, call)
+	r.mu.Unlock()
+	return result, err
+}
+
+// changedEnv returns the variables of env that are not in base. Commands
+// usually run with the inherited environment plus a few variables, and
+// only those are worth keeping: the rest is the host's, secrets included.
+func changedEnv(env, base []string) []string {
+	inherited := make(map[string]bool, len(base))
+	for _, kv := range base {
+		inherited[kv] = true
+	}
+	var changed []string
+	for _, kv := range env {
+		if !inherited[kv] {
+			changed = append(changed, kv)
+		}
+	}
+	return changed
+}
+
+func (r *RecordingExecutor) LookPath(file string) (string, error) {
+	path, err := r.next.LookPath(file)
+	lookup := RecordedLookup{File: file, Path: path}
+	if err != nil {
+		lookup.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.bundle.Lookups = append(r.bundle.Lookups, lookup)
+	r.mu.Unlock()
+	return path, err
+}
+
+// Bundle returns a copy of everything recorded so far.
+func (r *RecordingExecutor) Bundle() Bundle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Bundle{
+		Calls:   append([]RecordedCall(nil), r.bundle.Calls...),
+		Lookups: append([]RecordedLookup(nil), r.bundle.Lookups...),
+	}
+}
+
+// WriteFile writes the recording to path as indented JSON.
+func (r *RecordingExecutor) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r.Bundle(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ReplayExecutor answers commands from a Bundle. A command recorded several
+// times is answered in recording order, and the last answer is repeated once
+// the recording runs out. Commands and lookups that were never recorded fail
+// as if the program did not exist.
+type ReplayExecutor struct {
+	mu      sync.Mutex
+	calls   map[string][]RecordedCall
+	lookups map[string][]RecordedLookup
+}
+
+// NewReplayExecutor returns a ReplayExecutor for b.
+func NewReplayExecutor(b Bundle) *ReplayExecutor {
+	r := &ReplayExecutor{
+		calls:   make(map[string][]RecordedCall),
+		lookups: make(map[string][]RecordedLookup),
+	}
+	for _, call := range b.Calls {
+		key := argvKey(call.Argv)
+		r.calls[key] = append(r.calls[key], call)
+	}
+	for _, lookup := range b.Lookups {
+		r.lookups[lookup.File] = append(r.lookups[lookup.File], lookup)
+	}
+	return r
+}
+
+// OpenReplayExecutor returns a ReplayExecutor for the bundle at path.
+func OpenReplayExecutor(path string) (*ReplayExecutor, error) {
+	b, err := ReadBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayExecutor(b), nil
+}
+
+// next pops the first entry of queue, keeping the last one for reuse.
+func next[T any](queue []T) (T, []T) {
+	if len(queue) == 1 {
+		return queue[0], queue
+	}
+	return queue[0], queue[1:]
+}
+
+func (r *ReplayExecutor) Run(ctx context.Context, cmd Command) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd.Path, err)
+	}
+
+	r.mu.Lock()
+	key := argvKey(cmd.Argv())
+	queue, ok := r.calls[key]
+	if !ok {
+		r.mu.Unlock()
+		return nil, &exec.Error{Name: cmd.String(), Err: exec.ErrNotFound}
+	}
+	var call RecordedCall
+	call, r.calls[key] = next(queue)
+	r.mu.Unlock()
+
+	if call.Error != "" {
+		return nil, errors.New(call.Error)
+	}
+	result := &Result{Stdout: []byte(call.Stdout), Stderr: []byte(call.Stderr), ExitCode: call.ExitCode}
+	if result.ExitCode != 0 {
+		return result, &ExitError{Command: cmd, ExitCode: result.ExitCode, Stderr: result.Stderr}
+	}
+	return result, nil
+}
+
+func (r *ReplayExecutor) LookPath(file string) (string, error) {
+	r.mu.Lock()
+	queue, ok := r.lookups[file]
+	if !ok {
+		r.mu.Unlock()
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	var lookup RecordedLookup
+	lookup, r.lookups[file] = next(queue)
+	r.mu.Unlock()
+
+	if lookup.Error != "" {
+		return "", errors.New(lookup.Error)
+	}
+	return lookup.Path, nil
+}
@@ -0,0 +1,122 @@
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	fake := NewScriptedExecutor().
+		Stdout([]byte("card0\n"), "smi", "-q").
+		Script(Result{Stdout: []byte("partial"), Stderr: []byte("no device 1"), ExitCode: 2}, "smi", "-i", "1").
+		Fail(errors.New("permission denied"), "locked-smi").
+		Path("smi", "/usr/bin/smi")
+	recorder := NewRecordingExecutor(fake)
+	ctx := context.Background()
+
+	_, _ = recorder.Run(ctx, Command{Path: "smi", Args: []string{"-q"}, Env: []string{"PATH=/opt/smi/bin"}})
+	_, _ = recorder.Run(ctx, Command{Path: "smi", Args: []string{"-i", "1"}})
+	_, _ = recorder.Run(ctx, Command{Path: "locked-smi"})
+	_, _ = recorder.LookPath("smi")
+	_, _ = recorder.LookPath("missing-smi")
+
+	bundle := recorder.Bundle()
+	assert.Len(t, bundle.Calls, 3)
+	assert.Equal(t, []string{"smi", "-q"}, bundle.Calls[0].Argv)
+	assert.Equal(t, []string{"PATH=/opt/smi/bin"}, bundle.Calls[0].Env)
+	assert.Equal(t, 2, bundle.Calls[1].ExitCode)
+	assert.Equal(t, "no device 1", bundle.Calls[1].Stderr)
+	assert.Equal(t, "permission denied", bundle.Calls[2].Error)
+	assert.Len(t, bundle.Lookups, 2)
+
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	assert.NoError(t, recorder.WriteFile(path))
+	replay, err := OpenReplayExecutor(path)
+	assert.NoError(t, err)
+
+	out, err := Output(ctx, replay, "smi", "-q")
+	assert.NoError(t, err)
+	assert.Equal(t, "card0\n", string(out))
+
+	result, err := replay.Run(ctx, Command{Path: "smi", Args: []string{"-i", "1"}})
+	var exitErr *ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, "partial", string(result.Stdout))
+	assert.Equal(t, "no device 1", string(exitErr.Stderr))
+
+	_, err = Output(ctx, replay, "locked-smi")
+	assert.EqualError(t, err, "permission denied")
+
+	_, err = Output(ctx, replay, "smi", "--unrecorded")
+	assert.True(t, errors.Is(err, exec.ErrNotFound))
+
+	found, err := replay.LookPath("smi")
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr/bin/smi", found)
+	_, err = replay.LookPath("missing-smi")
+	assert.Error(t, err)
+}
+
+func TestRecordOnlyChangedEnv(t *testing.T) {
+	t.Setenv("GPU_TOOLS_TEST_TOKEN", "s3cret")
+	recorder := NewRecordingExecutor(NewScriptedExecutor().Stdout(nil, "smi"))
+
+	env := append(os.Environ(), "LD_LIBRARY_PATH=/opt/smi/lib")
+	_, err := recorder.Run(context.Background(), Command{Path: "smi", Env: env})
+	assert.NoError(t, err)
+	_, err = recorder.Run(context.Background(), Command{Path: "smi"})
+	assert.NoError(t, err)
+
+	bundle := recorder.Bundle()
+	assert.Equal(t, []string{"LD_LIBRARY_PATH=/opt/smi/lib"}, bundle.Calls[0].Env)
+	assert.Empty(t, bundle.Calls[1].Env)
+	data, err := json.Marshal(bundle)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+}
+
+func TestReplayExitErrorWithoutResult(t *testing.T) {
+	// An Executor may report a non-zero exit without a Result.
+	cmd := Command{Path: "smi", Args: []string{"-q"}}
+	fake := NewScriptedExecutor().Fail(&ExitError{Command: cmd, ExitCode: 3, Stderr: []byte("no device")}, "smi", "-q")
+	recorder := NewRecordingExecutor(fake)
+	_, _ = recorder.Run(context.Background(), cmd)
+
+	call := recorder.Bundle().Calls[0]
+	assert.Equal(t, 3, call.ExitCode)
+	assert.Empty(t, call.Error)
+
+	_, err := NewReplayExecutor(recorder.Bundle()).Run(context.Background(), cmd)
+	var exitErr *ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode)
+	assert.Equal(t, "no device", string(exitErr.Stderr))
+}
+
+func TestReplayInRecordingOrder(t *testing.T) {
+	replay := NewReplayExecutor(Bundle{Calls: []RecordedCall{
+		{Argv: []string{"smi"}, Stdout: "first"},
+		{Argv: []string{"smi"}, Stdout: "second"},
+	}})
+	ctx := context.Background()
+
+	for _, want := range []string{"first", "second", "second"} {
+		out, err := Output(ctx, replay, "smi")
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(out))
+	}
+}
+
+func TestReadBundleInvalid(t *testing.T) {
+	_, err := ReadBundle(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+	_, err = OpenReplayExecutor(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
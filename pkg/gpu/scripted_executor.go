@@ -0,0 +1,117 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ScriptedExecutor is an Executor that answers commands from a script
+// instead of running them, so loaders can be tested without the vendor
+// tools installed. Commands are matched on their exact argv; the
+// environment is ignored. Unscripted commands fail as if the program did
+// not exist.
+type ScriptedExecutor struct {
+	mu      sync.Mutex
+	results map[string]Result
+	errs    map[string]error
+	paths   map[string]string
+	calls   []Command
+}
+
+// NewScriptedExecutor returns an empty ScriptedExecutor.
+func NewScriptedExecutor() *ScriptedExecutor {
+	return &ScriptedExecutor{
+		results: make(map[string]Result),
+		errs:    make(map[string]error),
+		paths:   make(map[string]string),
+	}
+}
+
+func argvKey(argv []string) string {
+	return strings.Join(argv, "\x00")
+}
+
+// Script makes argv produce result. A non-zero ExitCode makes Run return an
+// *ExitError alongside the result.
+func (s *ScriptedExecutor) Script(result Result, argv ...string) *ScriptedExecutor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[argvKey(argv)] = result
+	s.registerPath(argv)
+	return s
+}
+
+// Stdout makes argv exit zero printing stdout.
+func (s *ScriptedExecutor) Stdout(stdout []byte, argv ...string) *ScriptedExecutor {
+	return s.Script(Result{Stdout: stdout}, argv...)
+}
+
+// Fail makes argv fail to start with err.
+func (s *ScriptedExecutor) Fail(err error, argv ...string) *ScriptedExecutor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs[argvKey(argv)] = err
+	s.registerPath(argv)
+	return s
+}
+
+// Path makes LookPath(file) return path.
+func (s *ScriptedExecutor) Path(file, path string) *ScriptedExecutor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paths[file] = path
+	return s
+}
+
+// registerPath lets LookPath find any program that has been scripted.
+func (s *ScriptedExecutor) registerPath(argv []string) {
+	if len(argv) == 0 {
+		return
+	}
+	if _, ok := s.paths[argv[0]]; !ok {
+		s.paths[argv[0]] = argv[0]
+	}
+}
+
+// Calls returns the commands run so far, in order.
+func (s *ScriptedExecutor) Calls() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Command(nil), s.calls...)
+}
+
+func (s *ScriptedExecutor) Run(ctx context.Context, cmd Command) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd.Path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, cmd)
+
+	key := argvKey(cmd.Argv())
+	if err, ok := s.errs[key]; ok {
+		return nil, err
+	}
+	scripted, ok := s.results[key]
+	if !ok {
+		return nil, &exec.Error{Name: cmd.String(), Err: exec.ErrNotFound}
+	}
+	result := scripted
+	if result.ExitCode != 0 {
+		return &result, &ExitError{Command: cmd, ExitCode: result.ExitCode, Stderr: result.Stderr}
+	}
+	return &result, nil
+}
+
+func (s *ScriptedExecutor) LookPath(file string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path, ok := s.paths[file]; ok {
+		return path, nil
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
@@ -0,0 +1,314 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LinkType is how the traffic between two devices travels. The PCIe types
+// are the categories of "nvidia-smi topo -m", which npu-smi shares.
+type LinkType string
+
+const (
+	LinkUnknown LinkType = ""
+	LinkSelf    LinkType = "X"
+	LinkNVLink  LinkType = "NVLink"
+	LinkXGMI    LinkType = "XGMI"
+	LinkHCCS    LinkType = "HCCS"
+	LinkPIX     LinkType = "PIX"  // through at most a single PCIe switch
+	LinkPXB     LinkType = "PXB"  // through several PCIe switches, without a host bridge
+	LinkPHB     LinkType = "PHB"  // through the PCIe host bridge of a CPU
+	LinkNode    LinkType = "NODE" // between PCIe host bridges of one NUMA node
+	LinkSys     LinkType = "SYS"  // between NUMA nodes, over QPI, UPI or the like
+	LinkPCIe    LinkType = "PCIe" // over PCIe, by an unreported path
+)
+
+// BandwidthClass ranks links by the bandwidth they can be expected to give,
+// so that devices can be grouped without knowing every vendor's link types.
+// Higher classes are faster.
+type BandwidthClass int
+
+const (
+	BandwidthUnknown    BandwidthClass = iota
+	BandwidthSystem                    // crosses the CPU interconnect
+	BandwidthHostBridge                // goes through a CPU
+	BandwidthPCIeSwitch                // stays below a PCIe switch
+	BandwidthFabric                    // a direct device-to-device link
+)
+
+var bandwidthNames = []string{"unknown", "system", "host_bridge", "pcie_switch", "fabric"}
+
+func (c BandwidthClass) String() string {
+	if c < 0 || int(c) >= len(bandwidthNames) {
+		return fmt.Sprintf("BandwidthClass(%d)", int(c))
+	}
+	return bandwidthNames[c]
+}
+
+func (c BandwidthClass) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *BandwidthClass) UnmarshalText(text []byte) error {
+	for i, name := range bandwidthNames {
+		if string(text) == name {
+			*c = BandwidthClass(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown bandwidth class %q", text)
+}
+
+// Bandwidth returns the bandwidth class of links of type t.
+func (t LinkType) Bandwidth() BandwidthClass {
+	switch t {
+	case LinkNVLink, LinkXGMI, LinkHCCS:
+		return BandwidthFabric
+	case LinkPIX, LinkPXB:
+		return BandwidthPCIeSwitch
+	case LinkPHB, LinkNode:
+		return BandwidthHostBridge
+	case LinkSys:
+		return BandwidthSystem
+	}
+	return BandwidthUnknown
+}
+
+// Link is the connection between two devices.
+type Link struct {
+	Type      LinkType       `json:"type"`
+	Bandwidth BandwidthClass `json:"bandwidth"`
+	Count     int            `json:"count,omitempty"` // bonded links, e.g. 12 for NV12
+}
+
+func newLink(t LinkType) Link {
+	return Link{Type: t, Bandwidth: t.Bandwidth()}
+}
+
+// Topology is the link matrix between the devices of one loader.
+// Links[i][j] is the link between Devices[i] and Devices[j], and
+// Links[i][i] has type LinkSelf.
+type Topology struct {
+	Devices []int    `json:"devices"` // GPUInfo.Num of each row and column
+	Links   [][]Link `json:"links"`
+}
+
+// Link returns the link between the devices numbered a and b, or a link of
+// type LinkUnknown if either is not in t.
+func (t *Topology) Link(a, b int) Link {
+	i, j := t.index(a), t.index(b)
+	if i < 0 || j < 0 {
+		return Link{}
+	}
+	return t.Links[i][j]
+}
+
+func (t *Topology) index(num int) int {
+	for i, d := range t.Devices {
+		if d == num {
+			return i
+		}
+	}
+	return -1
+}
+
+// TopologyReporter is implemented by loaders whose vendor tool reports how
+// their devices are linked.
+type TopologyReporter interface {
+	Topology(ctx context.Context) (*Topology, error)
+}
+
+// GetTopology returns the links between loader's devices. For loaders that
+// are not a TopologyReporter it is the PCIe hierarchy of their devices, from
+// DefaultPCIEnumerator.
+func GetTopology(ctx context.Context, loader GPUInfoLoader) (*Topology, error) {
+	if r, ok := loader.(TopologyReporter); ok {
+		return r.Topology(ctx)
+	}
+	list, err := LoadWithContext(ctx, loader)
+	if err != nil {
+		return nil, err
+	}
+	return DefaultPCIEnumerator.Topology(list.GPUInfos)
+}
+
+var nvLinkCell = regexp.MustCompile(`^NV(\d+)$`)
+
+// ParseTopologyMatrix parses the device matrix printed by "nvidia-smi topo
+// -m", "npu-smi info -t topo" and, per section, "rocm-smi --showtopo".
+// prefix is the label of the devices, "GPU" or "NPU"; the number after it is
+// taken as GPUInfo.Num. Columns after the devices, such as NICs and CPU
+// affinity, and the legend are ignored.
+//
+//	        GPU0    GPU1    NIC0    CPU Affinity
+//	GPU0     X      NV12    SYS     0-63
+//	GPU1    NV12     X      SYS     0-63
+func ParseTopologyMatrix(output []byte, prefix string) (*Topology, error) {
+	var t *Topology
+	rows := map[int][]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		num, ok := deviceLabel(fields[0], prefix)
+		if !ok {
+			continue
+		}
+		if t == nil {
+			t = &Topology{}
+			for _, f := range fields {
+				n, ok := deviceLabel(f, prefix)
+				if !ok {
+					break
+				}
+				t.Devices = append(t.Devices, n)
+			}
+			continue
+		}
+		rows[num] = fields[1:]
+	}
+	if t == nil {
+		return nil, fmt.Errorf("no %s topology matrix found", prefix)
+	}
+
+	t.Links = make([][]Link, len(t.Devices))
+	for i, a := range t.Devices {
+		cells, ok := rows[a]
+		if !ok {
+			return nil, fmt.Errorf("topology matrix has no row for %s%d", prefix, a)
+		}
+		t.Links[i] = make([]Link, len(t.Devices))
+		for j, b := range t.Devices {
+			switch {
+			case a == b:
+				t.Links[i][j] = newLink(LinkSelf)
+			case j < len(cells):
+				t.Links[i][j] = parseLinkCell(cells[j])
+			}
+		}
+	}
+	return t, nil
+}
+
+func deviceLabel(field, prefix string) (int, bool) {
+	rest, ok := strings.CutPrefix(field, prefix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	return n, err == nil
+}
+
+// parseLinkCell parses one cell of a topology matrix. Cells such as "NA"
+// give a link of type LinkUnknown.
+func parseLinkCell(cell string) Link {
+	cell = strings.ToUpper(cell)
+	if m := nvLinkCell.FindStringSubmatch(cell); m != nil {
+		link := newLink(LinkNVLink)
+		link.Count, _ = strconv.Atoi(m[1])
+		return link
+	}
+	switch {
+	case cell == "X":
+		return newLink(LinkSelf)
+	case strings.HasPrefix(cell, "HCCS"):
+		// Also "HCCS_SW" for NPUs joined through an HCCS switch.
+		return newLink(LinkHCCS)
+	case cell == "XGMI":
+		return newLink(LinkXGMI)
+	case cell == "PCIE":
+		return newLink(LinkPCIe)
+	}
+	for _, t := range []LinkType{LinkPIX, LinkPXB, LinkPHB, LinkNode, LinkSys} {
+		if cell == string(t) {
+			return newLink(t)
+		}
+	}
+	return Link{}
+}
+
+// Topology classifies the links between gpus from where they sit in the
+// PCIe hierarchy, the way "nvidia-smi topo -m" does for PCIe paths. It
+// cannot see NVLink or other fabrics. Links to GPUs whose PCIBus is not
+// found under /sys/bus/pci/devices have type LinkUnknown.
+func (e *PCIEnumerator) Topology(gpus []GPUInfo) (*Topology, error) {
+	dir := filepath.Join(e.Root, "sys", "bus", "pci", "devices")
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	paths := make([]pciPath, len(gpus))
+	t := &Topology{Devices: make([]int, len(gpus)), Links: make([][]Link, len(gpus))}
+	for i, info := range gpus {
+		t.Devices[i] = info.Num
+		paths[i] = e.pciPath(dir, info.PCIBus)
+	}
+	for i := range gpus {
+		t.Links[i] = make([]Link, len(gpus))
+		for j := range gpus {
+			if i == j {
+				t.Links[i][j] = newLink(LinkSelf)
+				continue
+			}
+			t.Links[i][j] = newLink(pciLinkType(paths[i], paths[j]))
+		}
+	}
+	return t, nil
+}
+
+// pciPath is where a device sits in the PCIe hierarchy.
+type pciPath struct {
+	// bridges runs from the host bridge, e.g. "pci0000:00", down to the
+	// bridge the device is attached to.
+	bridges []string
+	numa    string
+}
+
+func (e *PCIEnumerator) pciPath(dir, address string) pciPath {
+	a, err := ParsePCIAddress(address)
+	if err != nil {
+		return pciPath{}
+	}
+	device := filepath.Join(dir, a.String())
+	target, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return pciPath{}
+	}
+	// /sys/bus/pci/devices/0000:03:00.0 links to
+	// /sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/0000:02:08.0/0000:03:00.0
+	_, rest, ok := strings.Cut(filepath.ToSlash(target), "/sys/devices/")
+	if !ok {
+		return pciPath{}
+	}
+	parts := strings.Split(rest, "/")
+	return pciPath{bridges: parts[:len(parts)-1], numa: readSysfs(device, "numa_node")}
+}
+
+// pciLinkType classifies the path between two devices.
+func pciLinkType(a, b pciPath) LinkType {
+	if len(a.bridges) == 0 || len(b.bridges) == 0 {
+		return LinkUnknown
+	}
+	common := 0
+	for common < len(a.bridges) && common < len(b.bridges) && a.bridges[common] == b.bridges[common] {
+		common++
+	}
+	switch {
+	case common == 0 && a.numa == b.numa:
+		return LinkNode
+	case common == 0:
+		return LinkSys
+	case common == 1:
+		// Below the same host bridge but on different root ports.
+		return LinkPHB
+	case len(a.bridges)-common <= 1 && len(b.bridges)-common <= 1:
+		return LinkPIX
+	}
+	return LinkPXB
+}
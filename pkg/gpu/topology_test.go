@@ -0,0 +1,125 @@
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopologyMatrix(t *testing.T) {
+	output := `	GPU0	GPU1	GPU2	NIC0	CPU Affinity	NUMA Affinity	GPU NUMA ID
+GPU0	 X 	NV12	SYS	PXB	0-31	0		N/A
+GPU1	NV12	 X 	PHB	SYS	0-31	0		N/A
+GPU2	SYS	PHB	 X 	NODE	32-63	1		N/A
+NIC0	PXB	SYS	NODE	 X
+
+Legend:
+
+  X    = Self
+  SYS  = Connection traversing PCIe as well as the SMP interconnect between NUMA nodes (e.g., QPI/UPI)
+  NV#  = Connection traversing a bonded set of # NVLinks
+
+NIC Legend:
+
+  NIC0: mlx5_0
+`
+	topo, err := ParseTopologyMatrix([]byte(output), "GPU")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, topo.Devices)
+	assert.Equal(t, Link{Type: LinkNVLink, Bandwidth: BandwidthFabric, Count: 12}, topo.Link(0, 1))
+	assert.Equal(t, Link{Type: LinkSys, Bandwidth: BandwidthSystem}, topo.Link(2, 0))
+	assert.Equal(t, Link{Type: LinkPHB, Bandwidth: BandwidthHostBridge}, topo.Link(1, 2))
+	assert.Equal(t, LinkSelf, topo.Link(2, 2).Type)
+	assert.Equal(t, Link{}, topo.Link(0, 7), "not in the matrix")
+
+	_, err = ParseTopologyMatrix([]byte("NVIDIA-SMI has failed"), "GPU")
+	assert.Error(t, err)
+	_, err = ParseTopologyMatrix([]byte("\tGPU0\tGPU1\nGPU0\t X \tNV4\n"), "GPU")
+	assert.Error(t, err, "missing row")
+}
+
+func TestParseLinkCell(t *testing.T) {
+	assert.Equal(t, Link{Type: LinkHCCS, Bandwidth: BandwidthFabric}, parseLinkCell("HCCS_SW"))
+	assert.Equal(t, Link{Type: LinkXGMI, Bandwidth: BandwidthFabric}, parseLinkCell("XGMI"))
+	assert.Equal(t, Link{Type: LinkPCIe, Bandwidth: BandwidthUnknown}, parseLinkCell("PCIE"))
+	assert.Equal(t, Link{Type: LinkPIX, Bandwidth: BandwidthPCIeSwitch}, parseLinkCell("PIX"))
+	assert.Equal(t, Link{}, parseLinkCell("NA"))
+}
+
+func TestBandwidthClassJSON(t *testing.T) {
+	data, err := json.Marshal(Link{Type: LinkPXB, Bandwidth: BandwidthPCIeSwitch})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "PXB", "bandwidth": "pcie_switch"}`, string(data))
+
+	var link Link
+	assert.NoError(t, json.Unmarshal(data, &link))
+	assert.Equal(t, BandwidthPCIeSwitch, link.Bandwidth)
+	assert.Error(t, json.Unmarshal([]byte(`{"bandwidth": "warp"}`), &link))
+}
+
+func TestPCIEnumeratorTopology(t *testing.T) {
+	e := &PCIEnumerator{Root: "testdata/pcitopo"}
+	gpus := []GPUInfo{
+		{Num: 0, PCIBus: "0000:03:00.0"},
+		{Num: 1, PCIBus: "0000:04:00.0"},
+		{Num: 2, PCIBus: "0000:08:00.0"},
+		{Num: 3, PCIBus: "0000:05:00.0"},
+		{Num: 4, PCIBus: "0000:41:00.0"},
+		{Num: 5, PCIBus: "0000:81:00.0"},
+		{Num: 6, PCIBus: "N/A"},
+	}
+	topo, err := e.Topology(gpus)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6}, topo.Devices)
+
+	want := map[[2]int]LinkType{
+		{0, 0}: LinkSelf,
+		{0, 1}: LinkPIX,
+		{0, 2}: LinkPXB,
+		{0, 3}: LinkPHB,
+		{0, 4}: LinkNode,
+		{0, 5}: LinkSys,
+		{4, 5}: LinkSys,
+		{0, 6}: LinkUnknown,
+	}
+	for pair, typ := range want {
+		assert.Equal(t, typ, topo.Link(pair[0], pair[1]).Type, "%v", pair)
+		assert.Equal(t, typ, topo.Link(pair[1], pair[0]).Type, "%v", pair)
+	}
+
+	_, err = (&PCIEnumerator{Root: t.TempDir()}).Topology(gpus)
+	assert.Error(t, err)
+}
+
+type topologyLoader struct {
+	stubLoader
+}
+
+func (l *topologyLoader) Topology(ctx context.Context) (*Topology, error) {
+	return &Topology{Devices: []int{0}, Links: [][]Link{{newLink(LinkSelf)}}}, nil
+}
+
+func TestGetTopology(t *testing.T) {
+	topo, err := GetTopology(context.Background(), &topologyLoader{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0}, topo.Devices)
+
+	// Loaders that cannot report links get the PCIe hierarchy.
+	defer func(e *PCIEnumerator) { DefaultPCIEnumerator = e }(DefaultPCIEnumerator)
+	DefaultPCIEnumerator = &PCIEnumerator{Root: "testdata/pcitopo"}
+	loader := &stubLoader{list: &GPUInfoList{GPUInfos: []GPUInfo{
+		{Num: 0, PCIBus: "0000:03:00.0"},
+		{Num: 1, PCIBus: "0000:04:00.0"},
+	}}}
+	topo, err = GetTopology(context.Background(), loader)
+	assert.NoError(t, err)
+	assert.Equal(t, LinkPIX, topo.Link(0, 1).Type)
+
+	_, err = GetTopology(context.Background(), &stubLoader{err: errors.New("nvidia-smi crashed")})
+	assert.Error(t, err)
+}
+
+var _ TopologyReporter = &topologyLoader{}
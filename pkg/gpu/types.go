@@ -1,5 +1,10 @@
 package gpu
 
+import (
+	"context"
+	"strings"
+)
+
 type GPUInfo struct {
 	Num                         int    `json:"num"`
 	DeviceID                    string `json:"Device ID"`
@@ -17,6 +22,124 @@ type GPUInfo struct {
 	CardVendor                  string `json:"Card vendor"`
 	CardSKU                     string `json:"Card SKU"`
 	PCIBus                      string `json:"PCI Bus"`
+	UUID                        string `json:"uuid,omitempty"`
+	VBIOSVersion                string `json:"vbios_version,omitempty"`
+
+	// Metrics carries the same readings as the string fields above, typed and
+	// with an explicit status for values the vendor tool did not report.
+	// The one exception is temperature: where the tool reads a single
+	// sensor, the three temperature strings all repeat it, as they always
+	// have, while Metrics sets only the matching field and marks the other
+	// two unsupported.
+	Metrics GPUMetrics `json:"metrics"`
+
+	// The following are nil when the loader does not report them.
+	ECC          *ECCInfo      `json:"ecc,omitempty"`
+	Partition    *Partition    `json:"partition,omitempty"`
+	Interconnect *Interconnect `json:"interconnect,omitempty"`
+	Clocks       *Clocks       `json:"clocks,omitempty"`
+	PCIeLink     *PCIeLink     `json:"pcie_link,omitempty"`
+	MIG          *MIG          `json:"mig,omitempty"`
+	Health       *Health       `json:"health,omitempty"`
+
+	// Affinity is read from sysfs rather than the vendor tool; DetectAll
+	// fills it in, and PCIEnumerator.FillAffinity does for other callers.
+	Affinity *Affinity `json:"affinity,omitempty"`
+
+	// PState is the performance state, from "P0" for the highest
+	// performance down to "P15". Empty when not reported.
+	PState string `json:"pstate,omitempty"`
+
+	// ThrottleReasons lists why the clocks are currently held down, e.g.
+	// "sw_power_cap" or "hw_thermal_slowdown". Empty when not throttled or
+	// not reported.
+	ThrottleReasons []string `json:"throttle_reasons,omitempty"`
+}
+
+// ECCCounts are memory error counts.
+type ECCCounts struct {
+	Corrected   UintMetric `json:"corrected"`
+	Uncorrected UintMetric `json:"uncorrected"`
+}
+
+// ECCInfo is the ECC state of a device's memory.
+type ECCInfo struct {
+	Mode        string    `json:"mode"`                   // "enabled", "disabled", or empty when unknown
+	PendingMode string    `json:"pending_mode,omitempty"` // mode after the next reboot, where reported
+	Volatile    ECCCounts `json:"volatile"`               // errors since the driver was loaded
+
+	// nil when the vendor tool does not report them
+	Aggregate    *ECCCounts    `json:"aggregate,omitempty"` // errors over the lifetime of the device
+	RetiredPages *RetiredPages `json:"retired_pages,omitempty"`
+}
+
+// RetiredPages counts the memory pages a device has taken out of use after
+// ECC errors. NVIDIA GPUs from Ampere on remap rows instead of retiring
+// pages; their remapped rows are counted here.
+type RetiredPages struct {
+	Correctable   UintMetric `json:"correctable"`   // retired after repeated corrected errors
+	Uncorrectable UintMetric `json:"uncorrectable"` // retired after an uncorrected error
+	Pending       bool       `json:"pending"`       // retirement takes effect on the next reset
+}
+
+// ParseMode returns "enabled" or "disabled" for an ECC or MIG mode as the
+// vendor tools print it, e.g. "Enabled", and "" for anything else, such as
+// "N/A".
+func ParseMode(s string) string {
+	switch mode := strings.ToLower(strings.TrimSpace(s)); mode {
+	case "enabled", "disabled":
+		return mode
+	}
+	return ""
+}
+
+// Partition describes a device that is one partition of a physical card,
+// e.g. an AMD Instinct GPU in CPX mode.
+type Partition struct {
+	ID      int    `json:"id"`
+	Compute string `json:"compute"` // compute partition mode, e.g. "CPX"
+	Memory  string `json:"memory"`  // memory partition mode, e.g. "NPS4"
+}
+
+// Interconnect is the state of a device's peer-to-peer links.
+type Interconnect struct {
+	Type   string `json:"type"`   // e.g. "XGMI", "NVLink" or "HCCS"
+	Status string `json:"status"` // as reported by the vendor tool, e.g. "NO_ERROR"
+}
+
+// Clocks are clock frequencies in MHz.
+type Clocks struct {
+	Graphics    FloatMetric `json:"graphics_mhz"`
+	Memory      FloatMetric `json:"memory_mhz"`
+	MaxGraphics FloatMetric `json:"max_graphics_mhz"`
+	MaxMemory   FloatMetric `json:"max_memory_mhz"`
+}
+
+// PCIeLink is the PCIe link of a device to its upstream port.
+type PCIeLink struct {
+	Generation    UintMetric `json:"generation"`
+	MaxGeneration UintMetric `json:"max_generation"`
+	Width         UintMetric `json:"width"` // lanes
+	MaxWidth      UintMetric `json:"max_width"`
+}
+
+// MIG is the Multi-Instance GPU state of an NVIDIA GPU. Workloads on a GPU
+// in MIG mode see its MIG devices rather than the whole GPU.
+type MIG struct {
+	Mode        string      `json:"mode"`         // "enabled" or "disabled"
+	PendingMode string      `json:"pending_mode"` // mode after the next GPU reset
+	Devices     []MIGDevice `json:"devices,omitempty"`
+}
+
+// MIGDevice is a compute instance of a GPU instance of a GPU in MIG mode.
+type MIGDevice struct {
+	Index             int        `json:"index"` // device index within the parent GPU
+	GPUInstanceID     int        `json:"gpu_instance_id"`
+	ComputeInstanceID int        `json:"compute_instance_id"`
+	Profile           string     `json:"profile"` // e.g. "3g.40gb": compute and memory slices
+	UUID              string     `json:"uuid"`    // e.g. "MIG-7c1e5f0a-..."
+	MemoryTotal       UintMetric `json:"memory_total_bytes"`
+	MemoryUsed        UintMetric `json:"memory_used_bytes"`
 }
 
 type GPUInfoList struct {
@@ -25,16 +148,17 @@ type GPUInfoList struct {
 
 // DriverInfo stores information about a GPU driver installed on a Linux system.
 type GPUDriverInfo struct {
-	Vendor        string `json:"vendor"`         // GPU vendor (e.g., NVIDIA, AMD, Intel)
-	Version       string `json:"version"`        // Driver version (e.g., "535.113.01")
-	Installed     bool   `json:"installed"`      // Whether the driver is installed
-	InstallPath   string `json:"install_path"`   // Path to driver installation (empty if not installed)
-	ClientVersion string `json:"client_version"` // Version of the client utility (e.g., nvidia-smi version)
-	LibVersion    string `json:"lib_version"`    // Version of the driver library (e.g., CUDA library version)
-	DriverDate    string `json:"driver_date"`    // Release date of the driver (e.g., "2025-01-20")
-	KernelModule  string `json:"kernel_module"`  // Loaded kernel module (e.g., "nvidia")
-	ModuleLoaded  bool   `json:"module_loaded"`  // Whether the kernel module is loaded
-	DriverType    string `json:"driver_type"`    // Type of driver (e.g., "proprietary", "open-source")
+	Vendor          string `json:"vendor"`           // GPU vendor (e.g., NVIDIA, AMD, Intel)
+	Version         string `json:"version"`          // Driver version (e.g., "535.113.01")
+	Installed       bool   `json:"installed"`        // Whether the driver is installed
+	InstallPath     string `json:"install_path"`     // Path to driver installation (empty if not installed)
+	ClientVersion   string `json:"client_version"`   // Version of the client utility (e.g., nvidia-smi version)
+	LibVersion      string `json:"lib_version"`      // Version of the driver library (e.g., CUDA library version)
+	FirmwareVersion string `json:"firmware_version"` // Firmware/VBIOS version of the first device, where the tool reports one
+	DriverDate      string `json:"driver_date"`      // Release date of the driver (e.g., "2025-01-20")
+	KernelModule    string `json:"kernel_module"`    // Loaded kernel module (e.g., "nvidia")
+	ModuleLoaded    bool   `json:"module_loaded"`    // Whether the kernel module is loaded
+	DriverType      string `json:"driver_type"`      // Type of driver (e.g., "proprietary", "open-source")
 	// Architecture   string   `json:"architecture"`    // System architecture (e.g., "x86_64")
 	// LastError      string   `json:"last_error"`      // Error during data collection, if any
 	// CudaVersion    string   `json:"cuda_version"`    // CUDA version supported (e.g., "12.2")
@@ -48,3 +172,26 @@ type GPUInfoLoader interface {
 	Available() bool
 	Vendor() string
 }
+
+// ContextGPUInfoLoader is a GPUInfoLoader whose vendor tool invocations
+// honour the deadline and cancellation of ctx. Loaders implementing it keep
+// Load and Available as wrappers bounded by DefaultTimeout.
+type ContextGPUInfoLoader interface {
+	GPUInfoLoader
+	LoadContext(ctx context.Context) (*GPUInfoList, error)
+	AvailableContext(ctx context.Context) bool
+}
+
+// DriverGetter is implemented by loaders that can describe the installed
+// driver stack.
+type DriverGetter interface {
+	GPUInfoLoader
+	DriverInfo() (GPUDriverInfo, error)
+}
+
+// ContextDriverGetter is a DriverGetter whose vendor tool invocations honour
+// the deadline and cancellation of ctx.
+type ContextDriverGetter interface {
+	DriverGetter
+	DriverInfoContext(ctx context.Context) (GPUDriverInfo, error)
+}